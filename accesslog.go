@@ -0,0 +1,192 @@
+package gemproto
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemtext"
+)
+
+// AccessLogEntry describes a served request.
+type AccessLogEntry struct {
+	// Time is the time the request was received.
+	Time time.Time
+
+	// RemoteAddr is the network address of the client.
+	RemoteAddr string
+
+	// URL is the requested URL.
+	URL string
+
+	// ResponseInfo describes the response.
+	ResponseInfo
+}
+
+// AccessLogFormatter formats an AccessLogEntry as a single line
+// without the trailing newline.
+type AccessLogFormatter func(e AccessLogEntry) string
+
+// AccessLogOptions configures AccessLog.
+type AccessLogOptions struct {
+	// Output is where log lines are written to.
+	// Defaults to os.Stderr.
+	Output io.Writer
+
+	// Format formats the log lines.
+	// Defaults to CommonLogFormat.
+	Format AccessLogFormatter
+}
+
+// CommonLogFormat formats an entry similar to the Common Log Format
+// used by web servers, with the meta and duration appended:
+//
+//	127.0.0.1 - - [10/Oct/2022:13:55:36 +0000] "gemini://example.com/" 20 1024 "text/gemini" 1.2ms
+func CommonLogFormat(e AccessLogEntry) string {
+	host, _ := splitHostPort(e.RemoteAddr)
+	if host == "" {
+		host = "-"
+	}
+
+	return fmt.Sprintf("%s - - [%s] %q %d %d %q %s",
+		host,
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.URL,
+		e.StatusCode,
+		e.BytesWritten,
+		e.Meta,
+		e.Duration,
+	)
+}
+
+// JSONLogFormat formats an entry as a JSON object.
+func JSONLogFormat(e AccessLogEntry) string {
+	v := struct {
+		Time         time.Time `json:"time"`
+		RemoteAddr   string    `json:"remote_addr"`
+		URL          string    `json:"url"`
+		StatusCode   int       `json:"status"`
+		Meta         string    `json:"meta"`
+		BytesWritten int64     `json:"bytes"`
+		Duration     float64   `json:"duration_ms"`
+		Err          string    `json:"error,omitempty"`
+	}{
+		Time:         e.Time,
+		RemoteAddr:   e.RemoteAddr,
+		URL:          e.URL,
+		StatusCode:   e.StatusCode,
+		Meta:         e.Meta,
+		BytesWritten: e.BytesWritten,
+		Duration:     float64(e.Duration) / float64(time.Millisecond),
+	}
+
+	if e.Err != nil {
+		v.Err = e.Err.Error()
+	}
+
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// accessLogWriter records the response of handlers
+// that are not served by a Server.
+type accessLogWriter struct {
+	ResponseWriter
+	info ResponseInfo
+}
+
+func (w *accessLogWriter) WriteHeader(statusCode int, meta string) {
+	w.info.StatusCode, w.info.Meta = statusCode, meta
+	w.ResponseWriter.WriteHeader(statusCode, meta)
+}
+
+func (w *accessLogWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.info.BytesWritten += int64(n)
+	if err != nil && w.info.Err == nil {
+		w.info.Err = err
+	}
+	return n, err
+}
+
+// Status implements ResponseStats.
+func (w *accessLogWriter) Status() (int, string) {
+	return w.info.StatusCode, w.info.Meta
+}
+
+// BytesWritten implements ResponseStats.
+func (w *accessLogWriter) BytesWritten() int64 {
+	return w.info.BytesWritten
+}
+
+// Flush implements Flusher.
+func (w *accessLogWriter) Flush() {
+	flush(w.ResponseWriter)
+}
+
+// Hijack implements Hijacker.
+func (w *accessLogWriter) Hijack() (net.Conn, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// AccessLog returns a handler that writes a line to the access log
+// for every request served by h.
+//
+// Requests received by a Server are logged using AfterResponse,
+// so that the entry reflects the response as it was sent.
+// Otherwise the response is recorded by wrapping the ResponseWriter.
+func AccessLog(h Handler, opts AccessLogOptions) Handler {
+	output := opts.Output
+	if output == nil {
+		output = os.Stderr
+	}
+
+	format := opts.Format
+	if format == nil {
+		format = CommonLogFormat
+	}
+
+	var mu sync.Mutex
+
+	log := func(e AccessLogEntry) {
+		line := strings.ReplaceAll(format(e), "\n", " ") + "\n"
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = io.WriteString(output, line)
+	}
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		e := AccessLogEntry{
+			Time:       time.Now(),
+			RemoteAddr: r.RemoteAddr,
+			URL:        r.URL.String(),
+		}
+
+		if AfterResponse(r, func(info ResponseInfo) {
+			e.ResponseInfo = info
+			log(e)
+		}) {
+			h.ServeGemini(w, r)
+			return
+		}
+
+		aw := accessLogWriter{
+			ResponseWriter: w,
+			info: ResponseInfo{
+				StatusCode: StatusOK,
+				Meta:       gemtext.MIMEType,
+			},
+		}
+
+		h.ServeGemini(&aw, r)
+
+		e.ResponseInfo = aw.info
+		e.Duration = time.Since(e.Time)
+		log(e)
+	})
+}
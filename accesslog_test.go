@@ -0,0 +1,95 @@
+package gemproto_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+type syncBuffer struct {
+	bytes.Buffer
+	mu sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Buffer.String()
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	h := gemproto.AccessLog(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		w.WriteHeader(gemproto.StatusOK, "text/plain")
+		_, _ = w.Write([]byte("hello world"))
+	}), gemproto.AccessLogOptions{
+		Output: &buf,
+		Format: gemproto.JSONLogFormat,
+	})
+
+	r := gemtest.NewRequest("/hello")
+	r.RemoteAddr = "127.0.0.1:1234"
+	h.ServeGemini(gemtest.NewRecorder(), r)
+
+	var entry struct {
+		RemoteAddr string `json:"remote_addr"`
+		URL        string `json:"url"`
+		Status     int    `json:"status"`
+		Meta       string `json:"meta"`
+		Bytes      int64  `json:"bytes"`
+	}
+
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "127.0.0.1:1234", entry.RemoteAddr)
+	require.Equal(t, "gemini:///hello", entry.URL)
+	require.Equal(t, gemproto.StatusOK, entry.Status)
+	require.Equal(t, "text/plain", entry.Meta)
+	require.Equal(t, int64(11), entry.Bytes)
+}
+
+func TestAccessLogServer(t *testing.T) {
+	t.Parallel()
+
+	var buf syncBuffer
+	done := make(chan struct{})
+
+	h := gemproto.AccessLog(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		w.WriteHeader(gemproto.StatusNotFound, "Not Found")
+	}), gemproto.AccessLogOptions{
+		Output: &buf,
+	})
+
+	// hooks run in reverse order so this one runs after the access log
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		gemproto.AfterResponse(r, func(gemproto.ResponseInfo) { close(done) })
+		h.ServeGemini(w, r)
+	}))
+	defer server.Close()
+
+	client := gemproto.Client{}
+	res, err := client.Get(server.URL + "/missing")
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	<-done
+
+	line := buf.String()
+	require.True(t, strings.HasPrefix(line, "127.0.0.1 - - ["), line)
+	require.True(t, strings.Contains(line, `"`+server.URL+`/missing" 51 0 "Not Found" `), line)
+	require.True(t, strings.HasSuffix(line, "\n"), line)
+}
@@ -0,0 +1,329 @@
+package gemproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemtext"
+)
+
+// GeoIP maps IP addresses to countries.
+type GeoIP interface {
+	// Country returns the country code of ip or the empty string if unknown.
+	Country(ip net.IP) string
+}
+
+// AnalyticsReport contains aggregated request counters.
+type AnalyticsReport struct {
+	// Paths counts the requests per URL path.
+	Paths map[string]int64 `json:"paths"`
+
+	// Countries counts the requests per country code.
+	Countries map[string]int64 `json:"countries"`
+
+	// Statuses counts the responses per status code.
+	Statuses map[int]int64 `json:"statuses"`
+}
+
+// AnalyticsOther is the key under which counters
+// that do not meet the reporting threshold are summed.
+const AnalyticsOther = "(other)"
+
+// Analytics collects privacy-preserving capsule statistics.
+// It keeps only aggregated counters and never records
+// individual visitors, addresses or certificates.
+//
+// Use Middleware to count requests:
+//
+//	analytics := &gemproto.Analytics{MinCount: 5}
+//	mux.Handle("/", analytics.Middleware(handler))
+//	mux.Handle("/stats.gmi", analytics.ReportHandler(adminFingerprint))
+//
+// Analytics is safe to use concurrently.
+type Analytics struct {
+	// GeoIP optionally maps client addresses to countries.
+	// Countries are not counted if it is nil.
+	GeoIP GeoIP
+
+	// MinCount is the k-anonymity threshold.
+	// Counters with fewer hits are summed under AnalyticsOther
+	// in reports so that rare visits cannot be singled out.
+	MinCount int64
+
+	// MaxPaths limits the number of distinct paths that are counted.
+	// Requests for additional paths are counted under AnalyticsOther.
+	// Defaults to 1000.
+	MaxPaths int
+
+	// Logger receives the errors of periodic saves made by Persist.
+	// Defaults to the Logger stored under LoggerKey in the context
+	// passed to Persist. Nothing is logged if neither is set.
+	Logger Logger
+
+	mu     sync.Mutex
+	report AnalyticsReport
+}
+
+func (a *Analytics) init() {
+	if a.report.Paths == nil {
+		a.report = AnalyticsReport{
+			Paths:     make(map[string]int64),
+			Countries: make(map[string]int64),
+			Statuses:  make(map[int]int64),
+		}
+	}
+}
+
+func (a *Analytics) count(r *Request, statusCode int) {
+	var country string
+	if a.GeoIP != nil {
+		host, _ := splitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); ip != nil {
+			country = a.GeoIP.Country(ip)
+		}
+	}
+
+	maxPaths := a.MaxPaths
+	if maxPaths <= 0 {
+		maxPaths = 1000
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+
+	upath := r.URL.Path
+	if _, exists := a.report.Paths[upath]; !exists && len(a.report.Paths) >= maxPaths {
+		upath = AnalyticsOther
+	}
+
+	a.report.Paths[upath]++
+	a.report.Statuses[statusCode]++
+	if country != "" {
+		a.report.Countries[country]++
+	}
+}
+
+type statusRecorder struct {
+	ResponseWriter
+	statusCode int
+}
+
+func (w *statusRecorder) WriteHeader(statusCode int, meta string) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode, meta)
+}
+
+// Status implements ResponseStats.
+func (w *statusRecorder) Status() (int, string) {
+	if rs, ok := w.ResponseWriter.(ResponseStats); ok {
+		return rs.Status()
+	}
+	return w.statusCode, ""
+}
+
+// BytesWritten implements ResponseStats.
+func (w *statusRecorder) BytesWritten() int64 {
+	if rs, ok := w.ResponseWriter.(ResponseStats); ok {
+		return rs.BytesWritten()
+	}
+	return 0
+}
+
+// Flush implements Flusher.
+func (w *statusRecorder) Flush() {
+	flush(w.ResponseWriter)
+}
+
+// Hijack implements Hijacker.
+func (w *statusRecorder) Hijack() (net.Conn, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// Middleware counts the requests served by next.
+//
+// Requests received by a Server are counted using AfterResponse,
+// so that the status code is the one that was sent, including
+// Server.DefaultStatus and the status written after a recovered panic.
+// Otherwise the status code is recorded by wrapping the ResponseWriter.
+func (a *Analytics) Middleware(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if AfterResponse(r, func(info ResponseInfo) { a.count(r, info.StatusCode) }) {
+			next.ServeGemini(w, r)
+			return
+		}
+
+		sw := statusRecorder{w, StatusOK}
+		if rs, ok := w.(ResponseStats); ok {
+			sw.statusCode, _ = rs.Status()
+		}
+		defer func() { a.count(r, sw.statusCode) }()
+		next.ServeGemini(&sw, r)
+	})
+}
+
+// Report returns the counters with the k-anonymity threshold applied.
+func (a *Analytics) Report() AnalyticsReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.reportLocked()
+}
+
+func (a *Analytics) reportLocked() AnalyticsReport {
+	a.init()
+
+	report := AnalyticsReport{
+		Paths:     anonymize(a.report.Paths, a.MinCount),
+		Countries: anonymize(a.report.Countries, a.MinCount),
+		Statuses:  make(map[int]int64, len(a.report.Statuses)),
+	}
+
+	for k, v := range a.report.Statuses {
+		report.Statuses[k] = v
+	}
+
+	return report
+}
+
+func anonymize(counters map[string]int64, k int64) map[string]int64 {
+	res := make(map[string]int64, len(counters))
+	for key, n := range counters {
+		if n < k {
+			key = AnalyticsOther
+		}
+		res[key] += n
+	}
+	return res
+}
+
+// Save writes the counters to the named file as JSON
+// with the k-anonymity threshold applied, so that rare visits
+// are not singled out on disk either. The file is replaced atomically.
+func (a *Analytics) Save(name string) error {
+	a.mu.Lock()
+	data, err := json.Marshal(a.reportLocked())
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// Load reads the counters from the named file and adds them
+// to the current counters. It is not an error if the file does not exist.
+func (a *Analytics) Load(name string) error {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var report AnalyticsReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+
+	for k, v := range report.Paths {
+		a.report.Paths[k] += v
+	}
+	for k, v := range report.Countries {
+		a.report.Countries[k] += v
+	}
+	for k, v := range report.Statuses {
+		a.report.Statuses[k] += v
+	}
+
+	return nil
+}
+
+// Persist saves the counters to the named file every interval
+// until ctx is cancelled, after which the counters are saved a final time.
+// Periodic saves that fail are logged and retried at the next interval.
+// The error of the final save is returned.
+func (a *Analytics) Persist(ctx context.Context, name string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	return a.persist(ctx, name, ticker.C, contextLogger(ctx, a.Logger))
+}
+
+// persist saves the counters to the named file on every tick.
+func (a *Analytics) persist(ctx context.Context, name string, tick <-chan time.Time, logger Logger) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return a.Save(name)
+		case <-tick:
+			if err := a.Save(name); err != nil && logger != nil {
+				logger.Printf("gemproto: analytics: %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// ReportHandler returns a Handler that renders the report as gemtext.
+// Access is restricted with RequireFingerprints.
+func (a *Analytics) ReportHandler(fingerprints ...string) Handler {
+	return RequireFingerprints(fingerprints...)(HandlerFunc(func(w ResponseWriter, r *Request) {
+		report := a.Report()
+
+		b := gemtext.NewBuilder(make([]byte, 0, 1024))
+		b.Heading("Analytics")
+		writeCounters(b, "Paths", report.Paths)
+		writeCounters(b, "Countries", report.Countries)
+
+		statuses := make(map[string]int64, len(report.Statuses))
+		for k, v := range report.Statuses {
+			statuses[fmt.Sprint(k)] = v
+		}
+		writeCounters(b, "Statuses", statuses)
+
+		_, _ = w.Write(b.Bytes())
+	}))
+}
+
+func writeCounters(b *gemtext.Builder, title string, counters map[string]int64) {
+	keys := make([]string, 0, len(counters))
+	for k := range counters {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counters[keys[i]] != counters[keys[j]] {
+			return counters[keys[i]] > counters[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	b.Newline()
+	b.SubHeading(title)
+	for _, k := range keys {
+		b.Point(fmt.Sprintf("%s: %d", k, counters[k]))
+	}
+}
@@ -0,0 +1,105 @@
+package gemproto_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+type mockGeoIP struct{}
+
+func (mockGeoIP) Country(ip net.IP) string { return "NL" }
+
+func TestAnalytics(t *testing.T) {
+	t.Parallel()
+
+	analytics := gemproto.Analytics{
+		GeoIP:    mockGeoIP{},
+		MinCount: 2,
+	}
+
+	mux := gemproto.NewServeMux()
+	mux.Handle("/index.gmi", gemproto.MapHandler(map[string]gemproto.Page{
+		"/index.gmi": {Data: []byte("hello")},
+	}))
+	h := analytics.Middleware(mux)
+
+	for _, path := range []string{"/index.gmi", "/index.gmi", "/missing"} {
+		r := gemtest.NewRequest(path)
+		r.RemoteAddr = "127.0.0.1:1234"
+		h.ServeGemini(gemtest.NewRecorder(), r)
+	}
+
+	report := analytics.Report()
+	require.Equal(t, map[string]int64{"/index.gmi": 2, gemproto.AnalyticsOther: 1}, report.Paths)
+	require.Equal(t, map[string]int64{"NL": 3}, report.Countries)
+	require.Equal(t, map[int]int64{gemproto.StatusOK: 2, gemproto.StatusNotFound: 1}, report.Statuses)
+
+	name := filepath.Join(t.TempDir(), "analytics.json")
+	require.NoError(t, analytics.Save(name))
+
+	var loaded gemproto.Analytics
+	require.NoError(t, loaded.Load(name))
+	require.Equal(t, map[string]int64{"/index.gmi": 2, gemproto.AnalyticsOther: 1}, loaded.Report().Paths)
+}
+
+func TestAnalyticsReportHandler(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+	require.NoError(t, err)
+
+	var analytics gemproto.Analytics
+	h := analytics.ReportHandler(gemcert.Fingerprint(cert.Leaf))
+
+	w := gemtest.NewRecorder()
+	h.ServeGemini(w, gemtest.NewRequest("/stats.gmi"))
+	require.Equal(t, gemproto.StatusClientCertificateRequired, w.Code)
+
+	r := gemtest.NewRequest("/stats.gmi")
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+	w = gemtest.NewRecorder()
+	h.ServeGemini(w, r)
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.True(t, strings.HasPrefix(w.Body.String(), "# Analytics\n"))
+}
+
+func TestAnalyticsServerStatus(t *testing.T) {
+	t.Parallel()
+
+	var analytics gemproto.Analytics
+
+	mux := gemproto.NewServeMux()
+	mux.HandleFunc("/empty", func(w gemproto.ResponseWriter, r *gemproto.Request) {})
+	mux.HandleFunc("/panic", func(w gemproto.ResponseWriter, r *gemproto.Request) { panic("boom") })
+
+	s := gemproto.Server{
+		Insecure:      true,
+		DefaultStatus: gemproto.StatusTemporaryFailure,
+		DefaultMeta:   "Unavailable",
+		Handler:       analytics.Middleware(mux),
+		Logger:        &mockLogger{},
+	}
+
+	responses := make(chan struct{}, 2)
+	s.OnResponse = func(*gemproto.Request, gemproto.ResponseInfo) { responses <- struct{}{} }
+
+	addr, _ := serveLoopback(t, &s)
+	require.Equal(t, "40 Unavailable\r\n", request(t, addr, "/empty"))
+	require.Equal(t, "42 Internal Server Error\r\n", request(t, addr, "/panic"))
+	<-responses
+	<-responses
+
+	require.Equal(t, map[int]int64{
+		gemproto.StatusTemporaryFailure: 1,
+		gemproto.StatusCGIError:         1,
+	}, analytics.Report().Statuses)
+}
@@ -0,0 +1,90 @@
+package gemproto
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// ArchiveHandler returns a Handler that streams a tar.gz snapshot of
+// the file system rooted at root, so that a capsule can be backed up
+// over Gemini itself. Files are included under the same conditions as
+// they are served by FileServerWithOptions with the same options:
+// denied files are left out, as are hidden files unless
+// opts.Flags has ShowHiddenFiles. Files hidden by HideScheduled are
+// included because the archive is meant as a backup. Symbolic links
+// and other irregular files are not included.
+//
+// Access is restricted with RequireFingerprints.
+// The response is sent with the application/gzip mimetype.
+// Errors that occur while streaming truncate the archive,
+// which clients detect as a corrupt gzip stream.
+func ArchiveHandler(root fs.FS, opts FileServerOptions, fingerprints ...string) Handler {
+	fsrv := FileServerWithOptions(root, opts).(fileServer)
+
+	return RequireFingerprints(fingerprints...)(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(StatusOK, "application/gzip")
+
+		_ = fsrv.writeArchive(w)
+	}))
+}
+
+// writeArchive writes the served files of fsrv to w as a tar.gz stream.
+func (fsrv fileServer) writeArchive(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	err := fs.WalkDir(fsrv.Root, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if name == "." {
+			return nil
+		}
+
+		if fsrv.Flags&ShowHiddenFiles == 0 && strings.Contains("/"+name, "/.") || fsrv.denied(name, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		} else if !fi.IsDir() && !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil || fi.IsDir() {
+			return err
+		}
+
+		f, err := fsrv.Root.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+
+	if err != nil {
+		return err
+	} else if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,79 @@
+package gemproto_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestArchiveHandler(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+	require.NoError(t, err)
+
+	other, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"index.gmi":       {Data: []byte("# Hello")},
+		"posts/a.gmi":     {Data: []byte("a")},
+		"posts/a.gmi~":    {Data: []byte("backup")},
+		"server.key":      {Data: []byte("secret")},
+		".git/config":     {Data: []byte("git")},
+		".hidden/b.gmi":   {Data: []byte("b")},
+		"docs/.env":       {Data: []byte("env")},
+		"docs/readme.txt": {Data: []byte("readme")},
+	}
+
+	h := gemproto.ArchiveHandler(fsys, gemproto.FileServerOptions{}, gemcert.Fingerprint(cert.Leaf))
+
+	serve := func(c *tls.Certificate) *gemtest.ResponseRecorder {
+		r := gemtest.NewRequest("/admin/archive")
+		if c != nil {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{c.Leaf}}
+		}
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	require.Equal(t, gemproto.StatusClientCertificateRequired, serve(nil).Code)
+	require.Equal(t, gemproto.StatusClientCertificateNotAuthorized, serve(&other).Code)
+
+	w := serve(&cert)
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "application/gzip", w.Meta)
+
+	zr, err := gzip.NewReader(&w.Body)
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	var names []string
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		files[hdr.Name] = string(b)
+	}
+
+	sort.Strings(names)
+	require.Equal(t, []string{"docs/", "docs/readme.txt", "index.gmi", "posts/", "posts/a.gmi"}, names)
+	require.Equal(t, "# Hello", files["index.gmi"])
+}
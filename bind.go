@@ -0,0 +1,150 @@
+package gemproto
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// BindError is returned by Bind if a query parameter
+// cannot be decoded into its struct field.
+type BindError struct {
+	// Field is the name of the query parameter.
+	Field string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *BindError) Error() string {
+	return "gemproto: bind " + e.Field + ": " + e.Err.Error()
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+var errBindTarget = errors.New("gemproto: Bind target must be a non-nil pointer to a struct")
+
+// Bind decodes the query string of r into the struct pointed to by v.
+// The query is parsed as URL-encoded key=value pairs and each key
+// is stored in the exported field with the matching gemini tag,
+// or the field whose lowercase name matches if it has no tag.
+// Fields tagged with "-" are ignored. A string field tagged with ",input"
+// receives the whole unescaped query as returned by Request.GetInput,
+// and is left unchanged if the request has no query.
+//
+// Supported field types are strings, bools, signed and unsigned integers
+// and floats. Missing parameters leave their fields unchanged.
+//
+//	type Search struct {
+//	  Query string `gemini:"q"`
+//	  Page  int    `gemini:"page"`
+//	  Exact bool   `gemini:"exact"`
+//	}
+func Bind(r *Request, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errBindTarget
+	}
+
+	values, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return &BindError{Field: "query", Err: err}
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opt, _ := strings.Cut(field.Tag.Get("gemini"), ",")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		var value string
+		if opt == "input" {
+			if r.URL.RawQuery == "" {
+				continue
+			}
+			input, ok := r.GetInput()
+			if !ok {
+				return &BindError{Field: name, Err: errors.New("invalid input")}
+			}
+			value = input
+		} else if vs, ok := values[name]; ok && len(vs) != 0 {
+			value = vs[0]
+		} else {
+			continue
+		}
+
+		if err := setField(rv.Field(i), value); err != nil {
+			return &BindError{Field: name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func setField(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errors.New("unsupported type " + v.Type().String())
+	}
+	return nil
+}
+
+// BindHandler returns a Handler that decodes the query string
+// of each request into a new T using Bind and calls fn with it.
+// Requests whose query cannot be decoded are answered with 59 BAD REQUEST.
+// T must be a struct type.
+func BindHandler[T any](fn func(w ResponseWriter, r *Request, v T)) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		var v T
+		if err := Bind(r, &v); err != nil {
+			meta := "Bad Request"
+			var be *BindError
+			if errors.As(err, &be) {
+				meta = "Invalid " + be.Field
+			}
+			w.WriteHeader(StatusBadRequest, meta)
+			return
+		}
+		fn(w, r, v)
+	})
+}
@@ -0,0 +1,87 @@
+package gemproto_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestBind(t *testing.T) {
+	t.Parallel()
+
+	type search struct {
+		Query   string  `gemini:"q"`
+		Page    int     `gemini:"page"`
+		Exact   bool    `gemini:"exact"`
+		Limit   uint8   `gemini:"limit"`
+		Score   float64 `gemini:"score"`
+		Author  string
+		Ignored string `gemini:"-"`
+	}
+
+	var s search
+	r := gemtest.NewRequest("/?q=hello+world&page=2&exact=true&limit=10&score=0.5&author=me&ignored=x")
+	require.NoError(t, gemproto.Bind(r, &s))
+	require.Equal(t, search{"hello world", 2, true, 10, 0.5, "me", ""}, s)
+
+	var be *gemproto.BindError
+	err := gemproto.Bind(gemtest.NewRequest("/?limit=300"), &s)
+	require.True(t, errors.As(err, &be))
+	require.Equal(t, "limit", be.Field)
+
+	require.True(t, gemproto.Bind(r, s) != nil, "non-pointer target")
+
+	var input struct {
+		Text string `gemini:",input"`
+	}
+	require.NoError(t, gemproto.Bind(gemtest.NewRequest("/?a%20b=c"), &input))
+	require.Equal(t, "a b=c", input.Text)
+}
+
+func TestBindHandler(t *testing.T) {
+	t.Parallel()
+
+	h := gemproto.BindHandler(func(w gemproto.ResponseWriter, r *gemproto.Request, v struct {
+		Page int `gemini:"page"`
+	}) {
+		fmt.Fprint(w, v.Page)
+	})
+
+	w := gemtest.NewRecorder()
+	h.ServeGemini(w, gemtest.NewRequest("/?page=3"))
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "3", w.Body.String())
+
+	w = gemtest.NewRecorder()
+	h.ServeGemini(w, gemtest.NewRequest("/?page=three"))
+	require.Equal(t, gemproto.StatusBadRequest, w.Code)
+	require.Equal(t, "Invalid page", w.Meta)
+}
+
+func TestBindHandlerInput(t *testing.T) {
+	t.Parallel()
+
+	h := gemproto.BindHandler(func(w gemproto.ResponseWriter, r *gemproto.Request, v struct {
+		Name string `gemini:",input"`
+	}) {
+		if v.Name == "" {
+			w.WriteHeader(gemproto.StatusInput, "Name")
+			return
+		}
+		fmt.Fprint(w, "hello ", v.Name)
+	})
+
+	w := gemtest.NewRecorder()
+	h.ServeGemini(w, gemtest.NewRequest("/greet"))
+	require.Equal(t, gemproto.StatusInput, w.Code)
+	require.Equal(t, "Name", w.Meta)
+
+	w = gemtest.NewRecorder()
+	h.ServeGemini(w, gemtest.NewRequest("/greet?alice"))
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "hello alice", w.Body.String())
+}
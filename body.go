@@ -0,0 +1,85 @@
+package gemproto
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/askeladdk/gemproto/internal/leakcheck"
+)
+
+// responseBody wraps the connection of a response so that
+// closing the body deterministically closes the connection.
+type responseBody struct {
+	read     int64 // first for 64-bit alignment of atomic operations
+	conn     net.Conn
+	url      string
+	res      *Response
+	start    time.Time
+	once     sync.Once
+	closeErr error
+}
+
+func newResponseBody(conn net.Conn, url string, res *Response) *responseBody {
+	b := &responseBody{
+		conn:  conn,
+		url:   url,
+		res:   res,
+		start: time.Now(),
+	}
+	leakcheck.AcquireBody()
+	trackBody(b)
+	return b
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	n, err := b.conn.Read(p)
+	if n > 0 {
+		atomic.AddInt64(&b.read, int64(n))
+		if b.res.OnProgress != nil {
+			b.res.OnProgress(b.progress())
+		}
+	}
+	return n, err
+}
+
+func (b *responseBody) progress() Progress {
+	return Progress{
+		BytesRead: atomic.LoadInt64(&b.read),
+		Elapsed:   time.Since(b.start),
+	}
+}
+
+// Close closes the underlying connection.
+// It is safe to call Close more than once.
+func (b *responseBody) Close() error {
+	b.once.Do(func() {
+		untrackBody(b)
+		leakcheck.ReleaseBody()
+		b.closeErr = b.conn.Close()
+	})
+	return b.closeErr
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection.
+func (b *responseBody) SetReadDeadline(t time.Time) error {
+	return b.conn.SetReadDeadline(t)
+}
+
+// Progress reports the transfer progress of a response body.
+type Progress struct {
+	// BytesRead is the number of body bytes read so far.
+	BytesRead int64
+
+	// Elapsed is the time elapsed since the response header was received.
+	Elapsed time.Duration
+}
+
+// BytesPerSecond returns the average transfer speed.
+func (p Progress) BytesPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesRead) / p.Elapsed.Seconds()
+}
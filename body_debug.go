@@ -0,0 +1,22 @@
+//go:build gemprotodebug
+
+package gemproto
+
+import (
+	"log"
+	"runtime"
+)
+
+// trackBody installs a finalizer that reports response bodies
+// that are garbage collected without having been closed.
+// It is only enabled with the gemprotodebug build tag.
+func trackBody(b *responseBody) {
+	runtime.SetFinalizer(b, func(b *responseBody) {
+		log.Printf("gemproto: response body leaked without Close: %s", b.url)
+		_ = b.conn.Close()
+	})
+}
+
+func untrackBody(b *responseBody) {
+	runtime.SetFinalizer(b, nil)
+}
@@ -0,0 +1,7 @@
+//go:build !gemprotodebug
+
+package gemproto
+
+func trackBody(*responseBody) {}
+
+func untrackBody(*responseBody) {}
@@ -0,0 +1,147 @@
+package gemproto
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by CircuitBreaker.Allow
+// while calls to the upstream are being refused.
+var ErrCircuitOpen = errors.New("gemproto: circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+// Circuit breaker states.
+const (
+	// CircuitClosed allows all calls.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen refuses all calls until the cool-down has passed.
+	CircuitOpen
+
+	// CircuitHalfOpen allows a limited number of probe calls that
+	// close the circuit if they succeed and open it again if they fail.
+	CircuitHalfOpen
+)
+
+var circuitStateNames = map[CircuitState]string{
+	CircuitClosed:   "closed",
+	CircuitOpen:     "open",
+	CircuitHalfOpen: "half-open",
+}
+
+func (s CircuitState) String() string {
+	return circuitStateNames[s]
+}
+
+// CircuitBreaker stops calling an upstream that keeps failing,
+// so that clients are answered quickly during an outage rather than
+// waiting for one slow failure after another. The circuit opens after
+// Threshold consecutive failures, and after CoolDown it lets
+// HalfOpenProbes calls through to test whether the upstream recovered.
+//
+// CircuitBreaker is safe to use concurrently.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive failures that open the circuit.
+	// Defaults to 5.
+	Threshold int
+
+	// CoolDown is how long the circuit stays open before probing.
+	// Defaults to 30 seconds.
+	CoolDown time.Duration
+
+	// HalfOpenProbes is the number of concurrent probe calls
+	// allowed while half-open. Defaults to 1.
+	HalfOpenProbes int
+
+	state    CircuitState
+	failures int
+	probes   int
+	openedAt time.Time
+	mu       sync.Mutex
+}
+
+func (cb *CircuitBreaker) threshold() int {
+	if cb.Threshold <= 0 {
+		return 5
+	}
+	return cb.Threshold
+}
+
+func (cb *CircuitBreaker) coolDown() time.Duration {
+	if cb.CoolDown <= 0 {
+		return 30 * time.Second
+	}
+	return cb.CoolDown
+}
+
+func (cb *CircuitBreaker) halfOpenProbes() int {
+	if cb.HalfOpenProbes <= 0 {
+		return 1
+	}
+	return cb.HalfOpenProbes
+}
+
+// State returns the current state of the circuit.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.coolDown() {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// Allow reports whether a call to the upstream may proceed.
+// It returns ErrCircuitOpen if the call is refused. Otherwise the
+// caller must report the outcome of the call by calling done.
+func (cb *CircuitBreaker) Allow() (done func(success bool), err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.coolDown() {
+		cb.state, cb.probes = CircuitHalfOpen, 0
+	}
+
+	switch cb.state {
+	case CircuitOpen:
+		return nil, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if cb.probes >= cb.halfOpenProbes() {
+			return nil, ErrCircuitOpen
+		}
+		cb.probes++
+		return cb.done(true), nil
+	default:
+		return cb.done(false), nil
+	}
+}
+
+// done returns the function that reports the outcome of a call.
+func (cb *CircuitBreaker) done(probe bool) func(bool) {
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			cb.mu.Lock()
+			defer cb.mu.Unlock()
+
+			if probe && cb.state == CircuitHalfOpen {
+				cb.probes--
+			}
+
+			switch {
+			case success && (cb.state == CircuitClosed || probe):
+				cb.state, cb.failures = CircuitClosed, 0
+			case success:
+			case cb.state == CircuitHalfOpen && probe:
+				cb.state, cb.openedAt = CircuitOpen, time.Now()
+			case cb.state == CircuitClosed:
+				if cb.failures++; cb.failures >= cb.threshold() {
+					cb.state, cb.openedAt = CircuitOpen, time.Now()
+				}
+			}
+		})
+	}
+}
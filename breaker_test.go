@@ -0,0 +1,76 @@
+package gemproto_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	cb := gemproto.CircuitBreaker{
+		Threshold: 2,
+		CoolDown:  50 * time.Millisecond,
+	}
+
+	fail := func() {
+		done, err := cb.Allow()
+		require.NoError(t, err)
+		done(false)
+	}
+
+	fail()
+	require.Equal(t, gemproto.CircuitClosed, cb.State())
+	fail()
+	require.Equal(t, gemproto.CircuitOpen, cb.State())
+
+	_, err := cb.Allow()
+	require.ErrorIs(t, err, gemproto.ErrCircuitOpen)
+
+	time.Sleep(cb.CoolDown)
+	require.Equal(t, gemproto.CircuitHalfOpen, cb.State())
+
+	// a single probe is let through and failing it opens the circuit again
+	done, err := cb.Allow()
+	require.NoError(t, err)
+	_, err = cb.Allow()
+	require.ErrorIs(t, err, gemproto.ErrCircuitOpen)
+	done(false)
+	require.Equal(t, gemproto.CircuitOpen, cb.State())
+
+	time.Sleep(cb.CoolDown)
+	done, err = cb.Allow()
+	require.NoError(t, err)
+	done(true)
+	require.Equal(t, gemproto.CircuitClosed, cb.State())
+	require.Equal(t, "closed", cb.State().String())
+}
+
+func TestReverseProxyBreaker(t *testing.T) {
+	t.Parallel()
+
+	proxy := gemproto.NewSingleHostReverseProxy(&url.URL{Host: "127.0.0.1:1"})
+	proxy.Breaker = &gemproto.CircuitBreaker{Threshold: 1, CoolDown: time.Hour}
+
+	var errs []error
+	proxy.ErrorHandler = func(w gemproto.ResponseWriter, r *gemproto.Request, err error) {
+		errs = append(errs, err)
+		w.WriteHeader(gemproto.StatusProxyError, "Proxy Error")
+	}
+
+	for i := 0; i < 2; i++ {
+		w := gemtest.NewRecorder()
+		proxy.ServeGemini(w, gemtest.NewRequest("/"))
+		require.Equal(t, gemproto.StatusProxyError, w.Code)
+	}
+
+	require.Equal(t, 2, len(errs))
+	require.True(t, errs[0] != gemproto.ErrCircuitOpen)
+	require.ErrorIs(t, errs[1], gemproto.ErrCircuitOpen)
+	require.Equal(t, gemproto.CircuitOpen, proxy.Breaker.State())
+}
@@ -0,0 +1,60 @@
+package gemproto
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Canary is a Handler that splits traffic between a stable and a canary
+// handler, so that a new release of a capsule can be tried on a share
+// of the clients and rolled back by setting Percent to zero:
+//
+//	srv.Handler = &gemproto.Canary{
+//	  Stable:  v1,
+//	  Canary:  v2,
+//	  Percent: 5,
+//	  Key:     gemproto.CertificateKey,
+//	}
+type Canary struct {
+	// Stable serves the requests that are not sent to Canary.
+	Stable Handler
+
+	// Canary serves Percent percent of the requests.
+	Canary Handler
+
+	// Percent is the share of requests served by Canary,
+	// between 0 and 100.
+	Percent float64
+
+	// Key is optional and assigns clients to buckets, so that every
+	// client is consistently served by the same handler. For example,
+	// CertificateKey buckets clients by certificate fingerprint.
+	// Requests are assigned at random if it is nil.
+	Key func(*Request) string
+}
+
+// UseCanary reports whether r is served by Canary.
+func (c *Canary) UseCanary(r *Request) bool {
+	if c.Percent <= 0 {
+		return false
+	} else if c.Percent >= 100 {
+		return true
+	}
+
+	if c.Key == nil {
+		return rand.Float64()*100 < c.Percent
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(c.Key(r)))
+	return float64(h.Sum32()%10000) < c.Percent*100
+}
+
+// ServeGemini implements Handler.
+func (c *Canary) ServeGemini(w ResponseWriter, r *Request) {
+	if c.UseCanary(r) {
+		c.Canary.ServeGemini(w, r)
+		return
+	}
+	c.Stable.ServeGemini(w, r)
+}
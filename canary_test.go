@@ -0,0 +1,63 @@
+package gemproto_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestCanary(t *testing.T) {
+	t.Parallel()
+
+	stable := gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = w.Write([]byte("stable"))
+	})
+
+	canary := gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = w.Write([]byte("canary"))
+	})
+
+	c := gemproto.Canary{
+		Stable: stable,
+		Canary: canary,
+		Key:    gemproto.RemoteIPKey,
+	}
+
+	serve := func(remoteAddr string) string {
+		r := gemtest.NewRequest("/")
+		r.RemoteAddr = remoteAddr
+		w := gemtest.NewRecorder()
+		c.ServeGemini(w, r)
+		return w.Body.String()
+	}
+
+	require.Equal(t, "stable", serve("192.0.2.1:1965"))
+
+	c.Percent = 100
+	require.Equal(t, "canary", serve("192.0.2.1:1965"))
+
+	c.Percent = 30
+	var canaries int
+	for i := 0; i < 1000; i++ {
+		addr := fmt.Sprintf("10.0.%d.%d:1965", i/256, i%256)
+		got := serve(addr)
+		// clients are consistently served by the same handler
+		require.Equal(t, got, serve(addr))
+		if got == "canary" {
+			canaries++
+		}
+	}
+	require.True(t, canaries > 200 && canaries < 400, canaries)
+
+	c.Key = nil
+	canaries = 0
+	for i := 0; i < 1000; i++ {
+		if serve("192.0.2.1:1965") == "canary" {
+			canaries++
+		}
+	}
+	require.True(t, canaries > 200 && canaries < 400, canaries)
+}
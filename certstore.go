@@ -0,0 +1,122 @@
+package gemproto
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrNoCertificate is returned by CertificateStore if no certificate
+// matches the requested server name and there is no default certificate.
+var ErrNoCertificate = errors.New("gemproto: no certificate for server name")
+
+// CertificateStore maps hostnames to certificates so that several
+// capsules can be hosted on the same address using SNI.
+//
+// Hostnames may be wildcards of the form *.example.com,
+// which match exactly one label. Exact matches take precedence over wildcards.
+// The default certificate is used for clients that do not send SNI
+// or request an unknown hostname.
+//
+// CertificateStore is intended to be used with Server
+// by setting the GetCertificate field of the TLS configuration:
+//
+//	store := &gemproto.CertificateStore{}
+//	store.Add(cert1)
+//	store.Add(cert2, "example.com", "*.example.com")
+//	srv := gemproto.Server{
+//	  TLSConfig: &tls.Config{
+//	    GetCertificate: store.GetCertificate,
+//	  },
+//	}
+//
+// The zero CertificateStore is empty and ready to use.
+// CertificateStore is safe to use concurrently and certificates
+// may be added and removed while the server is running.
+type CertificateStore struct {
+	certs       map[string]*tls.Certificate
+	defaultCert *tls.Certificate
+	mu          sync.RWMutex
+}
+
+// Add registers the certificate for the hostnames.
+// If no hostnames are given, the certificate is registered for
+// the DNS names of its leaf certificate.
+// Existing registrations for the same hostnames are replaced.
+func (s *CertificateStore) Add(cert tls.Certificate, hostnames ...string) error {
+	if len(hostnames) == 0 {
+		leaf := cert.Leaf
+		if leaf == nil {
+			if len(cert.Certificate) == 0 {
+				return errors.New("gemproto: empty certificate")
+			}
+
+			var err error
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return err
+			}
+		}
+
+		hostnames = leaf.DNSNames
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.certs == nil {
+		s.certs = make(map[string]*tls.Certificate)
+	}
+
+	for _, hostname := range hostnames {
+		s.certs[strings.ToLower(hostname)] = &cert
+	}
+
+	return nil
+}
+
+// Remove unregisters the certificates of the hostnames.
+func (s *CertificateStore) Remove(hostnames ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, hostname := range hostnames {
+		delete(s.certs, strings.ToLower(hostname))
+	}
+}
+
+// SetDefault sets the certificate that is used when no hostname matches.
+func (s *CertificateStore) SetDefault(cert tls.Certificate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultCert = &cert
+}
+
+// Lookup returns the certificate that matches the server name.
+func (s *CertificateStore) Lookup(serverName string) (*tls.Certificate, bool) {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if cert, ok := s.certs[name]; ok && name != "" {
+		return cert, true
+	}
+
+	if _, rest, ok := strings.Cut(name, "."); ok {
+		if cert, ok := s.certs["*."+rest]; ok {
+			return cert, true
+		}
+	}
+
+	return s.defaultCert, s.defaultCert != nil
+}
+
+// GetCertificate implements the tls.Config.GetCertificate callback.
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert, ok := s.Lookup(hello.ServerName); ok {
+		return cert, nil
+	}
+	return nil, ErrNoCertificate
+}
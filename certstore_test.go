@@ -0,0 +1,64 @@
+package gemproto_test
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestCertificateStore(t *testing.T) {
+	t.Parallel()
+
+	create := func(name string) tls.Certificate {
+		cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+			Subject:  pkix.Name{CommonName: name},
+			DNSNames: []string{name},
+		})
+		require.NoError(t, err)
+		return cert
+	}
+
+	lookup := func(store *gemproto.CertificateStore, serverName string) (string, error) {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			return "", err
+		}
+		return cert.Leaf.Subject.CommonName, nil
+	}
+
+	var store gemproto.CertificateStore
+
+	_, err := lookup(&store, "example.com")
+	require.ErrorIs(t, err, gemproto.ErrNoCertificate)
+
+	require.NoError(t, store.Add(create("example.com")))
+	require.NoError(t, store.Add(create("wildcard"), "*.example.com"))
+	require.NoError(t, store.Add(create("www.example.com")))
+	store.SetDefault(create("default"))
+
+	for _, tc := range []struct {
+		serverName string
+		expected   string
+	}{
+		{"example.com", "example.com"},
+		{"EXAMPLE.COM.", "example.com"},
+		{"www.example.com", "www.example.com"},
+		{"blog.example.com", "wildcard"},
+		{"a.b.example.com", "default"},
+		{"example.org", "default"},
+		{"", "default"},
+	} {
+		name, err := lookup(&store, tc.serverName)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, name, tc.serverName)
+	}
+
+	store.Remove("www.example.com")
+	name, err := lookup(&store, "www.example.com")
+	require.NoError(t, err)
+	require.Equal(t, "wildcard", name)
+}
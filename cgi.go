@@ -0,0 +1,154 @@
+package gemproto
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemcert"
+)
+
+// CGIHandler executes a program per request following the
+// conventions of Gemini CGI. The request is described by
+// environment variables and the program writes the complete
+// response, including the header, to its standard output:
+//
+//	GATEWAY_INTERFACE  CGI/1.1
+//	SERVER_PROTOCOL    GEMINI
+//	SERVER_SOFTWARE    gemproto/<version>
+//	SERVER_NAME        host name of the request
+//	SERVER_PORT        port of the request URL, or 1965
+//	GEMINI_URL         request URL
+//	PATH_INFO          path of the request URL
+//	QUERY_STRING       raw query of the request URL
+//	REMOTE_ADDR        network address of the client
+//	REMOTE_HOST        same as REMOTE_ADDR
+//
+// These are set if the client presented a certificate:
+//
+//	AUTH_TYPE                 CERTIFICATE
+//	REMOTE_USER               common name of the certificate subject
+//	TLS_CLIENT_HASH           fingerprint of the certificate
+//	TLS_CLIENT_NOT_BEFORE     start of validity in RFC 3339 format
+//	TLS_CLIENT_NOT_AFTER      end of validity in RFC 3339 format
+//	TLS_CLIENT_SERIAL_NUMBER  serial number of the certificate
+//
+// PATH_INFO is the path that remains after StripPrefix,
+// so that a program mounted at a prefix can route the requests below it.
+// The program is killed when the request context is done.
+// Clients are answered with 42 CGI ERROR if the program cannot
+// be started or does not write a valid response header.
+type CGIHandler struct {
+	// Path is the path of the program.
+	Path string
+
+	// Args are optional arguments passed to the program.
+	Args []string
+
+	// Dir is the working directory of the program.
+	// Defaults to the working directory of the server.
+	Dir string
+
+	// Env lists additional environment variables in the form key=value.
+	// Only PATH is inherited from the server environment.
+	Env []string
+
+	// Stderr receives the standard error of the program.
+	// Defaults to os.Stderr.
+	Stderr io.Writer
+}
+
+// environ returns the environment variables of the program.
+func (h *CGIHandler) environ(r *Request) []string {
+	host, _ := splitHostPort(r.Host)
+	port := r.URL.Port()
+	if port == "" {
+		port = "1965"
+	}
+
+	env := []string{
+		"GATEWAY_INTERFACE=CGI/1.1",
+		"SERVER_PROTOCOL=GEMINI",
+		"SERVER_SOFTWARE=" + Software(),
+		"SERVER_NAME=" + host,
+		"SERVER_PORT=" + port,
+		"GEMINI_URL=" + r.URL.String(),
+		"PATH_INFO=" + r.URL.Path,
+		"QUERY_STRING=" + r.URL.RawQuery,
+		"REMOTE_ADDR=" + r.RemoteAddr,
+		"REMOTE_HOST=" + r.RemoteAddr,
+	}
+
+	if path, ok := os.LookupEnv("PATH"); ok {
+		env = append(env, "PATH="+path)
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 {
+		cert := r.TLS.PeerCertificates[0]
+		env = append(env,
+			"AUTH_TYPE=CERTIFICATE",
+			"REMOTE_USER="+cert.Subject.CommonName,
+			"TLS_CLIENT_HASH="+gemcert.Fingerprint(cert),
+			"TLS_CLIENT_NOT_BEFORE="+cert.NotBefore.Format(time.RFC3339),
+			"TLS_CLIENT_NOT_AFTER="+cert.NotAfter.Format(time.RFC3339),
+			"TLS_CLIENT_SERIAL_NUMBER="+cert.SerialNumber.String(),
+		)
+	}
+
+	return append(env, h.Env...)
+}
+
+// ServeGemini implements Handler.
+func (h *CGIHandler) ServeGemini(w ResponseWriter, r *Request) {
+	cmd := exec.CommandContext(r.Context(), h.Path, h.Args...)
+	cmd.Dir = h.Dir
+	cmd.Env = h.environ(r)
+	cmd.Stderr = h.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		w.WriteHeader(StatusCGIError, "CGI Error")
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		w.WriteHeader(StatusCGIError, "CGI Error")
+		return
+	}
+
+	defer func() {
+		// unblock the program if the response was cut short
+		_ = stdout.Close()
+		_ = cmd.Wait()
+	}()
+
+	// validate the header before anything is sent to the client,
+	// so that programs that fail early can still be answered with 42
+	var header bytes.Buffer
+	status, meta, err := readResponseHeader(stdout)
+	if err == nil {
+		var code int
+		if code, err = strconv.Atoi(status); err == nil {
+			err = WriteResponseHeader(&header, code, meta)
+		}
+	}
+
+	if err != nil {
+		_ = cmd.Process.Kill()
+		w.WriteHeader(StatusCGIError, "CGI Error")
+		return
+	}
+
+	// a status code lower than 10 passes the output through as is
+	w.WriteHeader(0, "")
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return
+	}
+	_, _ = io.Copy(w, stdout)
+}
@@ -0,0 +1,51 @@
+package gemproto_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestCGIHandler(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+
+	dir := t.TempDir()
+
+	script := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, []byte("#!/bin/sh\n"+body), 0o755))
+		return p
+	}
+
+	serve := func(path, rawURL string) *gemtest.ResponseRecorder {
+		h := gemproto.CGIHandler{Path: path, Env: []string{"GREETING=hello"}}
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, gemtest.NewRequest(rawURL))
+		return w
+	}
+
+	ok := script("ok", `printf '20 text/plain\r\n'
+echo "$GREETING $SERVER_NAME $SERVER_PORT $PATH_INFO $QUERY_STRING $GEMINI_URL"
+`)
+	w := serve(ok, "gemini://localhost/cgi?a%20b")
+	require.Equal(t, 0, w.Code)
+	require.Equal(t, "20 text/plain\r\n"+
+		"hello localhost 1965 /cgi a%20b gemini://localhost/cgi?a%20b\n", w.Body.String())
+
+	fail := script("fail", "exit 1\n")
+	require.Equal(t, gemproto.StatusCGIError, serve(fail, "/").Code)
+
+	invalid := script("invalid", "echo hello world\n")
+	require.Equal(t, gemproto.StatusCGIError, serve(invalid, "/").Code)
+
+	require.Equal(t, gemproto.StatusCGIError, serve(filepath.Join(dir, "missing"), "/").Code)
+}
@@ -0,0 +1,173 @@
+package gemproto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ChangeRecord is the last known state of a URL tracked by ChangeDetector.
+type ChangeRecord struct {
+	// URL is the requested URL.
+	URL string `json:"url"`
+
+	// Version numbers the distinct contents seen so far.
+	// It is 1 after the first check and is incremented on every change.
+	Version int `json:"version"`
+
+	// Hash is the hex encoded SHA-256 digest of the status, meta and body.
+	Hash string `json:"hash"`
+
+	// StatusCode is the status code of the last response.
+	StatusCode int `json:"status"`
+
+	// Checked is the time of the last check.
+	Checked time.Time `json:"checked"`
+}
+
+// ChangeStore persists ChangeRecords by URL.
+// Implementations must be safe to use concurrently.
+type ChangeStore interface {
+	// LoadChange returns the record of the URL
+	// and reports whether it was found.
+	LoadChange(url string) (ChangeRecord, bool, error)
+
+	// StoreChange stores the record under its URL.
+	StoreChange(rec ChangeRecord) error
+}
+
+// ChangeDetector fetches URLs and reports whether their content
+// changed since the previous check, for example to power "what's new"
+// aggregators. Only a hash of every URL is stored, not its content.
+//
+//	store, err := gemproto.OpenFileChangeStore("changes.json")
+//	// ...
+//	d := gemproto.ChangeDetector{Store: store}
+//	rec, changed, err := d.Check("gemini://example.com/gemlog/")
+type ChangeDetector struct {
+	// Client fetches the URLs.
+	// The zero Client is used if it is nil.
+	Client *Client
+
+	// Store persists the records.
+	Store ChangeStore
+}
+
+// Check fetches the URL and compares the hash of the response
+// with the stored record. It returns the updated record and reports
+// whether the content changed. The first check of a URL is a change.
+// Redirects are followed and non-success responses are hashed
+// by their status and meta, so that a page that disappears is a change too.
+func (d *ChangeDetector) Check(rawURL string) (ChangeRecord, bool, error) {
+	client := d.Client
+	if client == nil {
+		client = &Client{}
+	}
+
+	res, err := client.Get(rawURL)
+	if err != nil {
+		return ChangeRecord{}, false, err
+	}
+	defer res.Body.Close()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d %s\r\n", res.StatusCode, res.Meta)
+	if _, err := io.Copy(h, res.Body); err != nil {
+		return ChangeRecord{}, false, err
+	}
+
+	rec, found, err := d.Store.LoadChange(rawURL)
+	if err != nil {
+		return ChangeRecord{}, false, err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	changed := !found || rec.Hash != hash
+	if changed {
+		rec.Version++
+	}
+
+	rec.URL = rawURL
+	rec.Hash = hash
+	rec.StatusCode = res.StatusCode
+	rec.Checked = time.Now()
+
+	if err := d.Store.StoreChange(rec); err != nil {
+		return ChangeRecord{}, false, err
+	}
+
+	return rec, changed, nil
+}
+
+// FileChangeStore is a ChangeStore that keeps the records in memory
+// and saves them to a JSON file after every change.
+type FileChangeStore struct {
+	name    string
+	records map[string]ChangeRecord
+	mu      sync.Mutex
+}
+
+// OpenFileChangeStore loads the records from the named file.
+// It is not an error if the file does not exist.
+func OpenFileChangeStore(name string) (*FileChangeStore, error) {
+	s := FileChangeStore{
+		name:    name,
+		records: make(map[string]ChangeRecord),
+	}
+
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return &s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// LoadChange implements ChangeStore.
+func (s *FileChangeStore) LoadChange(url string) (ChangeRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[url]
+	return rec, ok, nil
+}
+
+// StoreChange implements ChangeStore.
+// The file is replaced atomically.
+func (s *FileChangeStore) StoreChange(rec ChangeRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[rec.URL] = rec
+
+	data, err := json.Marshal(s.records)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.name), filepath.Base(s.name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.name)
+}
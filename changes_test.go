@@ -0,0 +1,53 @@
+package gemproto_test
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestChangeDetector(t *testing.T) {
+	t.Parallel()
+
+	var content atomic.Value
+	content.Store("first")
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = gemproto.WriteString(w, content.Load().(string))
+	}))
+	defer server.Close()
+
+	name := filepath.Join(t.TempDir(), "changes.json")
+	store, err := gemproto.OpenFileChangeStore(name)
+	require.NoError(t, err)
+
+	d := gemproto.ChangeDetector{Store: store}
+	url := server.URL + "/feed.gmi"
+
+	rec, changed, err := d.Check(url)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, 1, rec.Version)
+	require.Equal(t, gemproto.StatusOK, rec.StatusCode)
+
+	_, changed, err = d.Check(url)
+	require.NoError(t, err)
+	require.True(t, !changed)
+
+	content.Store("second")
+
+	// the records survive reopening the store
+	store, err = gemproto.OpenFileChangeStore(name)
+	require.NoError(t, err)
+	d.Store = store
+
+	rec, changed, err = d.Check(url)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, 2, rec.Version)
+	require.Equal(t, url, rec.URL)
+}
@@ -1,20 +1,44 @@
 package gemproto
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"net"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtext"
 )
 
-// ErrInvalidResponse is returned by Client if it received an invalid response.
+// ErrInvalidResponse is returned by Client if it received an invalid response,
+// and by WriteResponseHeader if it was asked to write one.
 var ErrInvalidResponse = errors.New("gemproto: invalid response")
 
+// ErrUseLastResponse can be returned by Client.CheckRedirect to control
+// how redirects are processed. If returned, the next request is not sent
+// and the most recent redirect response is returned with a nil error.
+var ErrUseLastResponse = errors.New("gemproto: use last response")
+
+// ErrCrossHostRedirect is returned by Client.Do if a redirect points to
+// another host and Client.SameHostRedirects is set.
+var ErrCrossHostRedirect = errors.New("gemproto: redirect to another host")
+
+// ErrChecksumMismatch is returned when reading the body of a response
+// returned by Client.GetVerified if the digest does not match.
+var ErrChecksumMismatch = errors.New("gemproto: checksum mismatch")
+
 // RedirectError is returned by Client.Do if the
 // maximum number of redirects has been exceeded.
 type RedirectError struct {
@@ -30,21 +54,125 @@ func (err RedirectError) Error() string {
 	return fmt.Sprintf("gemproto: too many redirects: %s", err.NextURL)
 }
 
+// URLError is returned by Client and records the operation
+// and the URL of the request that failed, like url.Error.
+type URLError struct {
+	// Op is the operation that failed:
+	// "dial" (including the TLS handshake), "request", "redirect"
+	// or "backoff" if the request was refused by Client.HostTracker.
+	Op string
+
+	// URL is the URL of the request.
+	URL string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (err *URLError) Error() string {
+	return fmt.Sprintf("gemproto: %s %q: %s", err.Op, err.URL, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *URLError) Unwrap() error {
+	return err.Err
+}
+
+// Timeout reports whether the underlying error is a timeout.
+func (err *URLError) Timeout() bool {
+	t, ok := err.Err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
+
+// PinMismatchError is returned by Client if the certificate of a host
+// does not match any of the fingerprints pinned by Client.Pins.
+type PinMismatchError struct {
+	// Addr is the domain:port of the remote host.
+	Addr string
+
+	// Fingerprint is the fingerprint of the certificate presented by the host.
+	Fingerprint string
+
+	// Pins are the expected fingerprints.
+	Pins []string
+}
+
+// Error implements the error interface.
+func (err PinMismatchError) Error() string {
+	return fmt.Sprintf("gemproto: pinned certificate mismatch: %s", err.Addr)
+}
+
 type nopReader struct{}
 
 func (*nopReader) Read([]byte) (int, error) { return 0, io.EOF }
 
 var nopReadCloser io.ReadCloser = io.NopCloser((*nopReader)(nil))
 
+// VerifyMode determines how Client verifies host certificates.
+type VerifyMode int
+
+const (
+	// VerifyTOFU trusts unknown hosts on first use and records them
+	// in the HostsFile. Host certificates are not verified if
+	// the HostsFile is nil. This is the default.
+	VerifyTOFU VerifyMode = iota
+
+	// VerifyPinned only trusts hosts that are already recorded
+	// in the HostsFile. Unknown hosts are refused with UnknownHostError.
+	VerifyPinned
+
+	// VerifyCustom delegates verification to the Client.Verifier.
+	// All hosts are refused if the Verifier is nil.
+	VerifyCustom
+)
+
+// CertificateVerifier verifies the certificates presented by a remote host.
+type CertificateVerifier interface {
+	// VerifyCertificate verifies the connection state of the
+	// TLS handshake with the remote domain:port address.
+	VerifyCertificate(cs tls.ConnectionState, addr string) error
+}
+
 type dialer struct {
 	*tls.Dialer
 	hostsFile  *HostsFile
+	verifyMode VerifyMode
+	verifier   CertificateVerifier
 	serverAddr string
+	pins       []string
 }
 
 func (d *dialer) verifyConnection(cs tls.ConnectionState) error {
-	if d.hostsFile != nil {
-		return d.hostsFile.TrustCertificate(cs.PeerCertificates[0], d.serverAddr)
+	if len(d.pins) != 0 {
+		fp := gemcert.Fingerprint(cs.PeerCertificates[0])
+		for _, pin := range d.pins {
+			if strings.EqualFold(pin, fp) {
+				return nil
+			}
+		}
+		return PinMismatchError{
+			Addr:        d.serverAddr,
+			Fingerprint: fp,
+			Pins:        d.pins,
+		}
+	}
+
+	switch d.verifyMode {
+	case VerifyPinned:
+		if d.hostsFile == nil {
+			return UnknownHostError{Addr: d.serverAddr}
+		}
+		return d.hostsFile.VerifyPinned(cs.PeerCertificates[0], d.serverAddr)
+	case VerifyCustom:
+		if d.verifier == nil {
+			return ErrCertificateNotTrusted
+		}
+		return d.verifier.VerifyCertificate(cs, d.serverAddr)
+	default:
+		if d.hostsFile != nil {
+			return d.hostsFile.TrustCertificate(cs.PeerCertificates[0], d.serverAddr)
+		}
 	}
 	return nil
 }
@@ -108,8 +236,129 @@ type Client struct {
 	// HostsFile is optional and specifies to verify hosts.
 	HostsFile *HostsFile
 
+	// VerifyMode determines how host certificates are verified.
+	// It defaults to VerifyTOFU.
+	VerifyMode VerifyMode
+
+	// Verifier verifies host certificates if VerifyMode is VerifyCustom.
+	Verifier CertificateVerifier
+
 	// GetCertificate is optional and maps hostnames to client certificates.
+	// It is consulted again whenever a redirect points to another host,
+	// and the previously selected certificate is dropped, so that
+	// identities are not sent to hosts that GetCertificate does not map.
 	GetCertificate GetCertificateFunc
+
+	// SameHostRedirects refuses to follow redirects to other hosts
+	// and returns ErrCrossHostRedirect instead. Hosts are compared by
+	// host name regardless of port.
+	SameHostRedirects bool
+
+	// FileRoot is optional and enables the file:// scheme.
+	// File URLs are served from FileRoot by a FileServer with directory
+	// listings enabled, so that local files can be previewed
+	// through the same code path as remote resources.
+	FileRoot fs.FS
+
+	// CheckRedirect is optional and specifies the policy for handling redirects.
+	// It is called before following a redirect with the upcoming request
+	// and the requests made so far, oldest first. If CheckRedirect returns
+	// an error, Do returns that error instead of following the redirect,
+	// except for ErrUseLastResponse which returns the redirect response.
+	// If CheckRedirect is nil, Client follows at most 5 redirects
+	// and returns RedirectError if more are attempted.
+	CheckRedirect func(req *Request, via []*Request) error
+
+	// Decoders is optional and enables transparent decompression
+	// of response bodies. It maps compression names such as "gzip"
+	// to functions that decompress a body. See DefaultDecoders.
+	//
+	// Successful responses with a compression mimetype such as
+	// application/gzip are decompressed if a decoder for it is present.
+	// The mimetype of the decompressed content is derived from the URL
+	// path with the compression extension removed, so that notes.gmi.gz
+	// is reported as text/gemini. It defaults to application/octet-stream.
+	// The meta sent by the server is retained in Response.RawMeta and
+	// the compression name is stored in Response.Encoding.
+	Decoders map[string]DecoderFunc
+
+	// Dialers is optional and maps host suffixes such as ".onion"
+	// to dialers that connect to matching hosts instead of the default
+	// dialer. The longest matching suffix wins. This allows hosts
+	// to be reached through a SOCKS proxy or resolved by a custom
+	// resolver, for example by a net.Dialer with a custom net.Resolver.
+	// Hosts ending in .onion are refused with ErrNoOnionDialer
+	// if no dialer matches, so that they are not leaked to DNS.
+	Dialers map[string]ContextDialer
+
+	// Pins is optional and maps hosts or URL prefixes to the
+	// fingerprints, as computed by gemcert.Fingerprint, that the host
+	// certificate must match. Keys are either a host, a host:port,
+	// or a URL prefix such as "gemini://bank.example/account/".
+	// The longest matching key wins. Pinned hosts are verified
+	// before and instead of VerifyMode, so that high-value hosts are
+	// trusted independently of the HostsFile. Connections to pinned
+	// hosts are refused with PinMismatchError if no fingerprint matches.
+	Pins map[string][]string
+
+	// MaxConcurrentDials limits the number of connections that are
+	// dialed and TLS handshakes that are performed concurrently,
+	// so that crawlers do not exhaust local ports and CPU.
+	// Requests in excess of the limit wait in a queue until a slot
+	// is available or their context is done. Slots are released when
+	// the handshake completes, so reading response bodies does not
+	// count towards the limit. There is no limit if it is zero.
+	MaxConcurrentDials int
+
+	// HostTracker is optional and records the outcome of requests per host,
+	// so that hosts that keep failing are backed off from. Requests to
+	// hosts that are backing off fail immediately with a HostBackoffError.
+	// It is applied inside the interceptors added with Use.
+	HostTracker *HostTracker
+
+	interceptors []func(DoFunc) DoFunc
+
+	// dials is created on first use behind a pointer,
+	// so that Client values remain copyable
+	dials *dialState
+}
+
+// dialState limits the number of concurrent dials of a Client.
+type dialState struct {
+	slots chan struct{}
+}
+
+// dialStateMu guards the lazy creation of Client.dials.
+var dialStateMu sync.Mutex
+
+// dialSlots returns the semaphore that limits concurrent dials.
+func (c *Client) dialSlots() chan struct{} {
+	dialStateMu.Lock()
+	defer dialStateMu.Unlock()
+	if c.dials == nil {
+		c.dials = &dialState{slots: make(chan struct{}, c.MaxConcurrentDials)}
+	}
+	return c.dials.slots
+}
+
+// DoFunc sends a request and returns a response.
+// It has the signature of Client.Do.
+type DoFunc func(req *Request) (*Response, error)
+
+// Use adds interceptors that wrap every call to Do, so that logging,
+// caching, retries and politeness delays can be applied uniformly.
+// Interceptors are applied in the order they are added, so the first
+// interceptor is the outermost. Redirects are followed inside
+// the innermost DoFunc and do not pass through the interceptors.
+//
+//	client.Use(func(next gemproto.DoFunc) gemproto.DoFunc {
+//	  return func(req *gemproto.Request) (*gemproto.Response, error) {
+//	    log.Println("GET", req.URL)
+//	    return next(req)
+//	  }
+//	})
+func (c *Client) Use(interceptors ...func(next DoFunc) DoFunc) {
+	c.interceptors = append(c.interceptors, interceptors...)
 }
 
 // Get issues a request to the specified URL.
@@ -121,14 +370,98 @@ func (c *Client) Get(rawURL string) (*Response, error) {
 	return c.Do(req)
 }
 
+// Probe issues a request to the specified URL like Get, but only reads
+// the response header and closes the connection immediately, so that
+// checking whether a resource exists costs no more than the header.
+// Redirects are followed and the body is never decompressed.
+// The returned response has an empty Body that need not be closed.
+// This makes it suitable for link checkers and feed pollers.
+func (c *Client) Probe(rawURL string) (*Response, error) {
+	req, err := NewRequestWithContext(context.Background(), rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req.probe = true
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = res.Body.Close()
+	res.Body = nopReadCloser
+	res.body = nil
+	return res, nil
+}
+
+// GetVerified issues a request to the specified URL and verifies the body
+// against the hex encoded SHA-256 digest expectedSHA256.
+// The body is hashed while it is read and the final Read returns
+// ErrChecksumMismatch instead of io.EOF if the digest does not match.
+// The body must be read to completion to be verified.
+func (c *Client) GetVerified(rawURL, expectedSHA256 string) (*Response, error) {
+	expected, err := hex.DecodeString(expectedSHA256)
+	if err != nil {
+		return nil, err
+	} else if len(expected) != sha256.Size {
+		return nil, errors.New("gemproto: invalid sha256 digest")
+	}
+
+	res, err := c.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode/10 == 2 {
+		res.Body = &verifyingBody{
+			ReadCloser: res.Body,
+			hash:       sha256.New(),
+			expected:   expected,
+		}
+	}
+
+	return res, nil
+}
+
+type verifyingBody struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected []byte
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(b.hash.Sum(nil), b.expected) {
+		err = ErrChecksumMismatch
+	}
+	return n, err
+}
+
 // Do sends a request and returns a response.
+// The request passes through the interceptors added with Use.
 func (c *Client) Do(req *Request) (*Response, error) {
-	const maxRedirects = 5
+	do := c.send
+	if c.HostTracker != nil {
+		do = c.HostTracker.track(do)
+	}
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		do = c.interceptors[i](do)
+	}
+	return do(req)
+}
 
+func (c *Client) send(req *Request) (*Response, error) {
 	if req.URL == nil {
 		return nil, errors.New("gemproto: nil Request.URL")
+	} else if req.URL.Scheme == "file" && c.FileRoot != nil {
+		if req.probe {
+			return c.doFile(req, nil)
+		}
+		return c.decode(c.doFile(req, nil))
 	} else if req.URL.Scheme != "gemini" {
-		return nil, errors.New("gemproto: Request.URL.Scheme is not gemini")
+		return nil, &URLError{"request", req.URL.String(), errors.New("gemproto: Request.URL.Scheme is not gemini")}
 	}
 
 	d := dialer{
@@ -141,15 +474,40 @@ func (c *Client) Do(req *Request) (*Response, error) {
 				InsecureSkipVerify: true,
 			},
 		},
-		hostsFile: c.HostsFile,
+		hostsFile:  c.HostsFile,
+		verifyMode: c.VerifyMode,
+		verifier:   c.Verifier,
 	}
 
 	d.Dialer.Config.VerifyConnection = d.verifyConnection
 
-	return c.do(req, &d, maxRedirects)
+	if req.probe {
+		return c.do(req, &d, nil)
+	}
+	return c.decode(c.do(req, &d, nil))
 }
 
-func (c *Client) do(r *Request, d *dialer, redirects int) (*Response, error) {
+func (c *Client) checkRedirect(req *Request, via []*Request) error {
+	const maxRedirects = 5
+
+	if c.SameHostRedirects && len(via) != 0 &&
+		!strings.EqualFold(req.URL.Hostname(), via[len(via)-1].URL.Hostname()) {
+		return ErrCrossHostRedirect
+	}
+
+	if c.CheckRedirect != nil {
+		return c.CheckRedirect(req, via)
+	} else if len(via) > maxRedirects {
+		return RedirectError{
+			LastURL: via[len(via)-1].URL.String(),
+			NextURL: req.URL.String(),
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) do(r *Request, d *dialer, via []*Request) (*Response, error) {
 	host, port := splitHostPort(r.Host)
 
 	if host == "" {
@@ -160,11 +518,13 @@ func (c *Client) do(r *Request, d *dialer, redirects int) (*Response, error) {
 		port = "1965"
 	}
 
-	if c.GetCertificate != nil && host != d.Config.ServerName {
-		if cert, ok := c.GetCertificate(host); ok {
-			d.Config.Certificates = []tls.Certificate{cert}
-		} else {
-			d.Config.Certificates = nil
+	// never carry a certificate over to another host
+	if host != d.Config.ServerName {
+		d.Config.Certificates = nil
+		if c.GetCertificate != nil {
+			if cert, ok := c.GetCertificate(host); ok {
+				d.Config.Certificates = []tls.Certificate{cert}
+			}
 		}
 	}
 
@@ -172,60 +532,222 @@ func (c *Client) do(r *Request, d *dialer, redirects int) (*Response, error) {
 
 	d.Config.ServerName = host
 	d.serverAddr = addr
+	d.pins = c.pinsFor(r.URL, host, addr)
 
-	conn, err := d.DialContext(r.Context(), "tcp", addr)
+	conn, err := c.dial(r.Context(), d, host, addr)
 	if err != nil {
-		return nil, err
+		return nil, &URLError{"dial", r.URL.String(), err}
 	}
 
 	now := time.Now()
 	if c.ReadTimeout > 0 {
 		if err := conn.SetReadDeadline(now.Add(c.ReadTimeout)); err != nil {
 			defer conn.Close()
-			return nil, err
+			return nil, &URLError{"dial", r.URL.String(), err}
 		}
 	}
 	if c.WriteTimeout > 0 {
 		if err := conn.SetWriteDeadline(now.Add(c.WriteTimeout)); err != nil {
 			defer conn.Close()
-			return nil, err
+			return nil, &URLError{"dial", r.URL.String(), err}
 		}
 	}
 
 	status, meta, err := c.doReqRes(conn, r.URL.String())
 	if err != nil {
 		defer conn.Close()
-		return nil, err
+		return nil, &URLError{"request", r.URL.String(), err}
+	}
+
+	statusCode, _ := strconv.Atoi(status)
+
+	connState := conn.(*tls.Conn).ConnectionState()
+
+	res := &Response{
+		URL:        r.URL,
+		StatusCode: statusCode,
+		Meta:       meta,
+		Body:       nopReadCloser,
+		TLS:        &connState,
 	}
 
 	// handle redirects
 	if status[0] == '3' {
 		defer conn.Close()
 
-		if redirects == 0 {
-			return nil, RedirectError{
-				LastURL: r.URL.String(),
-				NextURL: meta,
-			}
-		}
-
 		newreq, err := NewRequestWithContext(r.Context(), absoluteURL(r, meta))
 		if err != nil {
-			return nil, err
+			return nil, &URLError{"redirect", r.URL.String(), err}
+		}
+
+		via = append(via, r)
+		if err := c.checkRedirect(newreq, via); err == ErrUseLastResponse {
+			return res, nil
+		} else if err != nil {
+			return nil, &URLError{"redirect", r.URL.String(), err}
+		}
+
+		return c.do(newreq, d, via)
+	}
+
+	// only 2x responses have a body
+	if status[0] == '2' {
+		res.body = newResponseBody(conn, r.URL.String(), res)
+		res.Body = res.body
+	} else {
+		defer conn.Close()
+	}
+
+	return res, nil
+}
+
+// pinsFor returns the pins of the longest key in Pins
+// that matches the host, domain:port address or URL.
+func (c *Client) pinsFor(u *url.URL, host, addr string) []string {
+	var pins []string
+	var longest int
+	for key, fps := range c.Pins {
+		var ok bool
+		if strings.Contains(key, "://") {
+			ok = matchPinURL(key, u)
+		} else {
+			ok = strings.EqualFold(key, host) || strings.EqualFold(key, addr)
+		}
+		if ok && len(key) > longest {
+			pins, longest = fps, len(key)
 		}
+	}
+	return pins
+}
 
-		return c.do(newreq, d, redirects-1)
+// matchPinURL reports whether u is under the URL prefix key.
+// Hosts are compared case-insensitively with the default port 1965,
+// and paths only match on whole segments, so that
+// gemini://bank.example/account does not match
+// gemini://bank.example.evil/ or gemini://bank.example/accounting.
+func matchPinURL(key string, u *url.URL) bool {
+	k, err := url.Parse(key)
+	if err != nil || !strings.EqualFold(k.Scheme, u.Scheme) {
+		return false
+	}
+
+	defaultPort := func(hostport string) (string, string) {
+		host, port := splitHostPort(hostport)
+		if port == "" {
+			port = "1965"
+		}
+		return host, port
+	}
+
+	khost, kport := defaultPort(k.Host)
+	host, port := defaultPort(u.Host)
+	if !strings.EqualFold(khost, host) || kport != port {
+		return false
+	}
+
+	prefix, path := k.Path, u.Path
+	if prefix == "" || prefix == "/" {
+		return true
+	} else if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// dial connects to addr using the dialer of host in Dialers
+// and performs the TLS handshake.
+func (c *Client) dial(ctx context.Context, d *dialer, host, addr string) (net.Conn, error) {
+	if c.MaxConcurrentDials > 0 {
+		slots := c.dialSlots()
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	nd := dialerFor(c.Dialers, host)
+	if nd == nil {
+		if strings.HasSuffix(strings.TrimSuffix(host, "."), ".onion") {
+			return nil, ErrNoOnionDialer
+		}
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	if c.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ConnectTimeout)
+		defer cancel()
+	}
+
+	raw, err := nd.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := tls.Client(raw, d.Config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (c *Client) doFile(r *Request, via []*Request) (*Response, error) {
+	// copy the request because FileServer may modify the URL
+	r2 := new(Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+
+	var buf bytes.Buffer
+	rw := responseWriter{
+		w:          &buf,
+		statusCode: StatusOK,
+		metadata:   gemtext.MIMEType,
+	}
+
+	FileServer(c.FileRoot, ListDirs).ServeGemini(&rw, r2)
+	if err := rw.writeHeader(); err != nil {
+		return nil, &URLError{"request", r.URL.String(), err}
+	}
+
+	status, meta, err := readResponseHeader(&buf)
+	if err != nil {
+		return nil, &URLError{"request", r.URL.String(), err}
 	}
 
 	statusCode, _ := strconv.Atoi(status)
 
-	connState := conn.(*tls.Conn).ConnectionState()
+	// handle redirects
+	if status[0] == '3' {
+		newreq, err := NewRequestWithContext(r.Context(), absoluteURL(r, meta))
+		if err != nil {
+			return nil, &URLError{"redirect", r.URL.String(), err}
+		}
+
+		via = append(via, r)
+		if err := c.checkRedirect(newreq, via); err == ErrUseLastResponse {
+			return &Response{
+				URL:        r.URL,
+				StatusCode: statusCode,
+				Meta:       meta,
+				Body:       nopReadCloser,
+			}, nil
+		} else if err != nil {
+			return nil, &URLError{"redirect", r.URL.String(), err}
+		}
+
+		return c.doFile(newreq, via)
+	}
 
-	var body io.ReadCloser = conn
+	body := io.NopCloser(&buf)
 
 	// only 2x responses have a body
 	if status[0] != '2' {
-		defer conn.Close()
 		body = nopReadCloser
 	}
 
@@ -234,7 +756,6 @@ func (c *Client) do(r *Request, d *dialer, redirects int) (*Response, error) {
 		StatusCode: statusCode,
 		Meta:       meta,
 		Body:       body,
-		TLS:        &connState,
 	}, nil
 }
 
@@ -243,8 +764,12 @@ func (c *Client) doReqRes(conn net.Conn, rawURL string) (status, meta string, er
 		return status, meta, err
 	}
 
+	return readResponseHeader(conn)
+}
+
+func readResponseHeader(r io.Reader) (status, meta string, err error) {
 	var line string
-	if line, err = readHeaderLine(conn, 1029); err != nil {
+	if line, err = readHeaderLine(r, 1029); err != nil {
 		return status, meta, err
 	}
 
@@ -1,8 +1,13 @@
 package gemproto_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"errors"
 	"io"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -95,3 +100,481 @@ func TestClientRedirectTooMany(t *testing.T) {
 
 	t.Fatal()
 }
+
+func TestClientFileScheme(t *testing.T) {
+	t.Parallel()
+
+	client := gemproto.Client{
+		FileRoot: gemproto.Dir("testfiles"),
+	}
+
+	res, err := client.Get("file:///hello.gmi")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, gemproto.StatusOK, res.StatusCode)
+	require.Equal(t, gemtext.MIMEType, res.Meta)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.True(t, len(body) != 0)
+
+	res, err = client.Get("file:///doesnotexist.gmi")
+	require.NoError(t, err)
+	require.Equal(t, gemproto.StatusNotFound, res.StatusCode)
+
+	res, err = client.Get("file:///index.gmi")
+	require.NoError(t, err)
+	require.Equal(t, gemproto.StatusOK, res.StatusCode)
+	require.Equal(t, "file:///", res.URL.String())
+}
+
+func TestClientDecodeJSON(t *testing.T) {
+	t.Parallel()
+
+	type message struct {
+		Text string `json:"text"`
+	}
+
+	handler := func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		require.NoError(t, gemproto.JSON(w, message{"hello world"}))
+	}
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(handler))
+	defer server.Close()
+
+	client := gemproto.Client{}
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, "application/json", res.Meta)
+
+	var msg message
+	require.NoError(t, res.DecodeJSON(&msg))
+	require.Equal(t, "hello world", msg.Text)
+}
+
+func TestClientVerifyPinned(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+	defer server.Close()
+
+	hf := gemproto.NewHostsFile(io.Discard)
+	client := gemproto.Client{
+		HostsFile:  hf,
+		VerifyMode: gemproto.VerifyPinned,
+	}
+
+	_, err := client.Get(server.URL)
+	var unknown gemproto.UnknownHostError
+	require.True(t, errors.As(err, &unknown), err)
+
+	addr := strings.TrimPrefix(server.URL, "gemini://")
+	require.NoError(t, hf.SetHost(gemproto.Host{
+		Addr:        addr,
+		Algorithm:   "sha256",
+		Fingerprint: gemcert.Fingerprint(server.Certificate.Leaf),
+		NotAfter:    server.Certificate.Leaf.NotAfter,
+	}))
+
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, gemproto.StatusOK, res.StatusCode)
+}
+
+func TestClientPins(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+	defer server.Close()
+
+	fp := gemcert.Fingerprint(server.Certificate.Leaf)
+	addr := strings.TrimPrefix(server.URL, "gemini://")
+
+	// pins take precedence over the hostsfile
+	client := gemproto.Client{
+		HostsFile:  gemproto.NewHostsFile(io.Discard),
+		VerifyMode: gemproto.VerifyPinned,
+		Pins: map[string][]string{
+			addr:                     {fp},
+			server.URL + "/private/": {"bogus"},
+		},
+	}
+
+	res, err := client.Get(server.URL + "/public")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, gemproto.StatusOK, res.StatusCode)
+
+	_, err = client.Get(server.URL + "/private/key")
+	var mismatch gemproto.PinMismatchError
+	require.True(t, errors.As(err, &mismatch), err)
+	require.Equal(t, addr, mismatch.Addr)
+	require.Equal(t, fp, mismatch.Fingerprint)
+}
+
+func TestClientPinsURLPrefix(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+	defer server.Close()
+
+	addr := strings.TrimPrefix(server.URL, "gemini://")
+	_, port, _ := net.SplitHostPort(addr)
+
+	client := gemproto.Client{
+		HostsFile: gemproto.NewHostsFile(io.Discard),
+		Pins: map[string][]string{
+			"gemini://LOCALHOST:" + port + "/account": {"bogus"},
+			"gemini://localhost/public":               {"bogus"},
+			"gemini://localhos:" + port + "/":         {"bogus"},
+		},
+	}
+
+	for _, tc := range []struct {
+		Path   string
+		Pinned bool
+	}{
+		{"/account", true},
+		{"/account/balance", true},
+		{"/accounting", false},
+		{"/public", false}, // pinned on the default port only
+		{"/", false},
+	} {
+		res, err := client.Get(server.URL + tc.Path)
+		if tc.Pinned {
+			var mismatch gemproto.PinMismatchError
+			require.True(t, errors.As(err, &mismatch), tc.Path, err)
+			continue
+		}
+		require.NoError(t, err, tc.Path)
+		res.Body.Close()
+	}
+}
+
+func TestClientBodyClose(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	client := gemproto.Client{}
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+
+	deadliner, ok := res.Body.(interface{ SetReadDeadline(time.Time) error })
+	require.True(t, ok)
+	require.NoError(t, deadliner.SetReadDeadline(time.Now().Add(time.Second)))
+
+	require.NoError(t, res.Body.Close())
+	require.NoError(t, res.Body.Close())
+}
+
+func TestClientGetVerified(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	client := gemproto.Client{}
+
+	const digest = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+	res, err := client.GetVerified(server.URL, digest)
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(body))
+
+	res, err = client.GetVerified(server.URL, strings.Repeat("0", 64))
+	require.NoError(t, err)
+	_, err = io.ReadAll(res.Body)
+	res.Body.Close()
+	require.ErrorIs(t, err, gemproto.ErrChecksumMismatch)
+}
+
+func TestClientProgress(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 10000)))
+	}))
+	defer server.Close()
+
+	client := gemproto.Client{}
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var last gemproto.Progress
+	res.OnProgress = func(p gemproto.Progress) {
+		require.True(t, p.BytesRead > last.BytesRead)
+		last = p
+	}
+
+	_, err = io.Copy(io.Discard, res.Body)
+	require.NoError(t, err)
+	require.Equal(t, int64(10000), last.BytesRead)
+	require.Equal(t, int64(10000), res.Progress().BytesRead)
+	require.True(t, res.Progress().BytesPerSecond() > 0)
+}
+
+func TestClientCheckRedirect(t *testing.T) {
+	t.Parallel()
+
+	handler := func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		if r.URL.Path != "/" {
+			gemproto.Redirect(w, r, "/", gemproto.StatusTemporaryRedirect)
+		}
+	}
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(handler))
+	defer server.Close()
+
+	var via []*gemproto.Request
+	client := gemproto.Client{
+		CheckRedirect: func(req *gemproto.Request, v []*gemproto.Request) error {
+			via = v
+			return gemproto.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(server.URL + "/a")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, gemproto.StatusTemporaryRedirect, res.StatusCode)
+	require.Equal(t, server.URL+"/", res.Meta)
+	require.Equal(t, 1, len(via))
+	require.Equal(t, server.URL+"/a", via[0].URL.String())
+}
+
+func TestClientDecoders(t *testing.T) {
+	t.Parallel()
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte("# hello world\n"))
+	require.NoError(t, zw.Close())
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		w.WriteHeader(gemproto.StatusOK, "application/gzip")
+		_, _ = w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	client := gemproto.Client{
+		Decoders: gemproto.DefaultDecoders(),
+	}
+
+	res, err := client.Get(server.URL + "/notes.gmi.gz")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, "# hello world\n", string(body))
+	require.Equal(t, gemtext.MIMEType, res.Meta)
+	require.Equal(t, "application/gzip", res.RawMeta)
+	require.Equal(t, "gzip", res.Encoding)
+
+	client.Decoders = nil
+	res, err = client.Get(server.URL + "/notes.gmi.gz")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, "application/gzip", res.Meta)
+	require.Equal(t, "", res.Encoding)
+}
+
+func TestClientUse(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	var calls []string
+
+	client := gemproto.Client{}
+	client.Use(func(next gemproto.DoFunc) gemproto.DoFunc {
+		return func(req *gemproto.Request) (*gemproto.Response, error) {
+			calls = append(calls, "outer")
+			return next(req)
+		}
+	}, func(next gemproto.DoFunc) gemproto.DoFunc {
+		return func(req *gemproto.Request) (*gemproto.Response, error) {
+			calls = append(calls, "inner")
+			req.URL.Path = "/rewritten"
+			return next(req)
+		}
+	})
+
+	res, err := client.Get(server.URL + "/")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "/rewritten", string(body))
+	require.Equal(t, []string{"outer", "inner"}, calls)
+}
+
+func TestClientMaxConcurrentDials(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+	defer server.Close()
+
+	// stall accepts connections but never completes the handshake
+	stall, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer stall.Close()
+
+	go func() {
+		for {
+			conn, err := stall.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := gemproto.Client{MaxConcurrentDials: 1}
+
+	get := func(rawURL string, timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		req, err := gemproto.NewRequestWithContext(ctx, rawURL)
+		require.NoError(t, err)
+		res, err := client.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	stalled := make(chan error)
+	go func() { stalled <- get("gemini://"+stall.Addr().String(), 300*time.Millisecond) }()
+	time.Sleep(50 * time.Millisecond)
+
+	// the request waits in the queue behind the stalled dial
+	require.ErrorIs(t, get(server.URL, 50*time.Millisecond), context.DeadlineExceeded)
+
+	require.True(t, <-stalled != nil)
+	require.NoError(t, get(server.URL, time.Second))
+}
+
+func TestClientURLError(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	client := gemproto.Client{}
+	_, err = client.Get("gemini://" + addr + "/page")
+
+	var urlErr *gemproto.URLError
+	require.True(t, errors.As(err, &urlErr), err)
+	require.Equal(t, "dial", urlErr.Op)
+	require.Equal(t, "gemini://"+addr+"/page", urlErr.URL)
+
+	var opErr *net.OpError
+	require.True(t, errors.As(err, &opErr), err)
+}
+
+func TestClientProbe(t *testing.T) {
+	t.Parallel()
+
+	writeErr := make(chan error, 1)
+
+	mux := gemproto.NewServeMux()
+	mux.Handle("/old", gemproto.RedirectHandler("/big.gz", gemproto.StatusPermanentRedirect))
+	mux.HandleFunc("/big.gz", func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		w.WriteHeader(gemproto.StatusOK, "application/gzip")
+		chunk := make([]byte, 64<<10)
+		for i := 0; i < 1024; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+		writeErr <- nil
+	})
+
+	server := gemtest.NewServer(mux)
+	defer server.Close()
+
+	client := gemproto.Client{
+		Decoders: gemproto.DefaultDecoders(),
+	}
+
+	res, err := client.Probe(server.URL + "/old")
+	require.NoError(t, err)
+	require.Equal(t, gemproto.StatusOK, res.StatusCode)
+	require.Equal(t, "application/gzip", res.Meta)
+	require.Equal(t, "", res.Encoding)
+	require.Equal(t, server.URL+"/big.gz", res.URL.String())
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(body))
+
+	// the connection was closed before the body was sent
+	require.True(t, <-writeErr != nil)
+
+	res, err = client.Probe(server.URL + "/missing")
+	require.NoError(t, err)
+	require.Equal(t, gemproto.StatusNotFound, res.StatusCode)
+}
+
+func TestClientCrossHostRedirect(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+	require.NoError(t, err)
+
+	var server *gemtest.Server
+	server = gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		switch r.URL.Path {
+		case "/hop":
+			// same server, but a different host name
+			target := strings.Replace(server.URL, "localhost", "127.0.0.1", 1) + "/whoami"
+			gemproto.Redirect(w, r, target, gemproto.StatusTemporaryRedirect)
+		case "/whoami":
+			if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 {
+				_, _ = io.WriteString(w, "identified")
+			} else {
+				_, _ = io.WriteString(w, "anonymous")
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := gemproto.Client{
+		GetCertificate: func(host string) (tls.Certificate, bool) {
+			return cert, host == "localhost"
+		},
+	}
+
+	get := func(rawURL string) string {
+		res, err := client.Get(rawURL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	require.Equal(t, "identified", get(server.URL+"/whoami"))
+	require.Equal(t, "anonymous", get(server.URL+"/hop"))
+
+	client.SameHostRedirects = true
+	_, err = client.Get(server.URL + "/hop")
+	require.ErrorIs(t, err, gemproto.ErrCrossHostRedirect)
+}
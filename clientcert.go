@@ -0,0 +1,88 @@
+package gemproto
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemcert"
+)
+
+// ClientCertKey stores the leaf certificate presented by the client.
+// It is set by RequireClientCert.
+var ClientCertKey = NewContextKey[*x509.Certificate]("client-cert")
+
+// RequireClientCert is middleware that restricts access to clients
+// presenting a valid certificate. It responds with
+// 60 Client Certificate Required if no certificate was presented and with
+// 62 Certificate Not Valid if the certificate is expired, not yet valid,
+// or is self-signed with a bad signature. Otherwise the leaf certificate
+// is stored under ClientCertKey and the request is passed to next.
+//
+// The server must request client certificates for this to work,
+// for example by setting tls.Config.ClientAuth to tls.RequestClientCert.
+func RequireClientCert(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(StatusClientCertificateRequired, "Client Certificate Required")
+			return
+		}
+
+		leaf := r.TLS.PeerCertificates[0]
+		if !validClientCert(leaf, time.Now()) {
+			w.WriteHeader(StatusClientCertificateNotValid, "Certificate Not Valid")
+			return
+		}
+
+		next.ServeGemini(w, WithValue(r, ClientCertKey, leaf))
+	})
+}
+
+func validClientCert(cert *x509.Certificate, now time.Time) bool {
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return false
+	}
+
+	// self-signed certificates are the norm in Gemini,
+	// so the best that can be checked is that the signature is intact
+	if len(cert.RawTBSCertificate) != 0 && bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+	}
+
+	return true
+}
+
+// RequireFingerprints returns middleware that restricts access to clients
+// presenting a certificate with one of the given fingerprints,
+// as computed by gemcert.Fingerprint. It responds with
+// 60 Client Certificate Required if no certificate was presented and with
+// 61 Certificate Not Authorized if the fingerprint is not allowed.
+func RequireFingerprints(fingerprints ...string) func(Handler) Handler {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				w.WriteHeader(StatusClientCertificateRequired, "Client Certificate Required")
+				return
+			} else if !authorizedFingerprint(r.TLS, fingerprints) {
+				w.WriteHeader(StatusClientCertificateNotAuthorized, "Certificate Not Authorized")
+				return
+			}
+			next.ServeGemini(w, r)
+		})
+	}
+}
+
+func authorizedFingerprint(cs *tls.ConnectionState, fingerprints []string) bool {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return false
+	}
+
+	fp := gemcert.Fingerprint(cs.PeerCertificates[0])
+	for _, allowed := range fingerprints {
+		if fp == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package gemproto_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestRequireClientCert(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{Duration: time.Hour})
+	require.NoError(t, err)
+
+	var leaf *x509.Certificate
+	h := gemproto.RequireClientCert(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		leaf, _ = gemproto.FromContext(r.Context(), gemproto.ClientCertKey)
+	}))
+
+	serve := func(cert *x509.Certificate) *gemtest.ResponseRecorder {
+		r := gemtest.NewRequest("/")
+		if cert != nil {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	require.Equal(t, gemproto.StatusClientCertificateRequired, serve(nil).Code)
+
+	require.Equal(t, gemproto.StatusOK, serve(cert.Leaf).Code)
+	require.True(t, leaf == cert.Leaf)
+
+	expired := *cert.Leaf
+	expired.NotAfter = time.Now().Add(-time.Minute)
+	require.Equal(t, gemproto.StatusClientCertificateNotValid, serve(&expired).Code)
+
+	tampered := *cert.Leaf
+	tampered.Signature = append([]byte{}, cert.Leaf.Signature...)
+	tampered.Signature[0] ^= 0xff
+	require.Equal(t, gemproto.StatusClientCertificateNotValid, serve(&tampered).Code)
+}
+
+func TestRequireFingerprints(t *testing.T) {
+	t.Parallel()
+
+	admin, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{Duration: time.Hour})
+	require.NoError(t, err)
+	other, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{Duration: time.Hour})
+	require.NoError(t, err)
+
+	h := gemproto.RequireFingerprints(gemcert.Fingerprint(admin.Leaf))(
+		gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+
+	serve := func(cert *x509.Certificate) int {
+		r := gemtest.NewRequest("/")
+		if cert != nil {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, gemproto.StatusClientCertificateRequired, serve(nil))
+	require.Equal(t, gemproto.StatusClientCertificateNotAuthorized, serve(other.Leaf))
+	require.Equal(t, gemproto.StatusOK, serve(admin.Leaf))
+}
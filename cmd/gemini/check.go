@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtext"
+)
+
+// checkProblem is a single problem found by check.
+type checkProblem struct {
+	Kind   string   `json:"kind"`
+	URL    string   `json:"url"`
+	Source string   `json:"source,omitempty"`
+	Detail string   `json:"detail,omitempty"`
+	Chain  []string `json:"chain,omitempty"`
+}
+
+// checkReport is the result of a check.
+type checkReport struct {
+	URL      string         `json:"url"`
+	Pages    int            `json:"pages"`
+	Links    int            `json:"links"`
+	Problems []checkProblem `json:"problems"`
+
+	// Skipped are the links that ask for input or a client certificate,
+	// which are valid but cannot be crawled.
+	Skipped []checkProblem `json:"skipped"`
+}
+
+// checker crawls a capsule and validates its links.
+type checker struct {
+	client    gemproto.Client
+	host      string
+	external  bool
+	maxPages  int
+	maxChain  int
+	maxBody   int64
+	visited   map[string]bool
+	report    checkReport
+	queue     []checkLink
+	checkedEx map[string]bool
+}
+
+type checkLink struct {
+	url    string
+	source string
+}
+
+func check(args []string) {
+	fset := flag.NewFlagSet("check", flag.ExitOnError)
+
+	var (
+		external = fset.Bool("external", false, "also validate links to other hosts")
+		asJSON   = fset.Bool("json", false, "write the report as JSON")
+		maxPages = fset.Int("max", 1000, "maximum number of pages to crawl")
+	)
+
+	if err := fset.Parse(args); err != nil {
+		fset.Usage()
+		die(err)
+	}
+
+	start, err := url.Parse(fset.Arg(0))
+	if err != nil {
+		die(err)
+	} else if start.Scheme != "gemini" {
+		die(errors.New("check: url scheme must be gemini"))
+	}
+
+	c := checker{
+		client: gemproto.Client{
+			ConnectTimeout: 5 * time.Second,
+			WriteTimeout:   10 * time.Second,
+			ReadTimeout:    30 * time.Second,
+			CheckRedirect: func(*gemproto.Request, []*gemproto.Request) error {
+				return gemproto.ErrUseLastResponse
+			},
+		},
+		host:      start.Host,
+		external:  *external,
+		maxPages:  *maxPages,
+		maxChain:  5,
+		maxBody:   1 << 20,
+		visited:   map[string]bool{},
+		checkedEx: map[string]bool{},
+		report: checkReport{
+			URL:      start.String(),
+			Problems: []checkProblem{},
+			Skipped:  []checkProblem{},
+		},
+	}
+
+	c.run(start.String())
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(c.report); err != nil {
+			die(err)
+		}
+	} else {
+		writeCheckReport(os.Stdout, &c.report)
+	}
+
+	if len(c.report.Problems) != 0 {
+		os.Exit(1)
+	}
+}
+
+func (c *checker) run(start string) {
+	c.queue = append(c.queue, checkLink{url: start})
+
+	for len(c.queue) > 0 && c.report.Pages < c.maxPages {
+		link := c.queue[0]
+		c.queue = c.queue[1:]
+
+		if c.visited[link.url] {
+			continue
+		}
+		c.visited[link.url] = true
+		c.report.Pages++
+
+		res, final := c.fetch(link, false)
+		if res == nil {
+			continue
+		}
+
+		// only crawl gemtext pages on the same host
+		if u, err := url.Parse(final); err != nil || u.Host != c.host || !isGemtextMeta(res.Meta) {
+			res.Body.Close()
+			continue
+		}
+
+		links, err := gemtext.Links(io.LimitReader(res.Body, c.maxBody))
+		res.Body.Close()
+		if err != nil {
+			c.addProblem("broken", final, link.source, err.Error(), nil)
+			continue
+		}
+
+		for _, rawURL := range links {
+			c.follow(final, rawURL)
+		}
+	}
+}
+
+// follow queues a link found on the page at base.
+func (c *checker) follow(base, rawURL string) {
+	baseURL, _ := url.Parse(base)
+	ref, err := url.Parse(rawURL)
+	if err != nil {
+		c.addProblem("broken", rawURL, base, err.Error(), nil)
+		return
+	}
+
+	u := baseURL.ResolveReference(ref)
+	u.Fragment = ""
+	if u.Scheme != "gemini" {
+		return
+	}
+
+	c.report.Links++
+
+	if u.Host == c.host {
+		c.queue = append(c.queue, checkLink{url: u.String(), source: base})
+	} else if c.external && !c.checkedEx[u.String()] {
+		c.checkedEx[u.String()] = true
+		// external pages are not crawled, so only their headers are read
+		if res, _ := c.fetch(checkLink{url: u.String(), source: base}, true); res != nil {
+			res.Body.Close()
+		}
+	}
+}
+
+// fetch requests the link and follows redirects manually
+// so that redirect chains can be reported.
+// It returns the final response and URL, or nil if the link is broken
+// or asks for input or a client certificate.
+// Only the response header is read if probe is set.
+func (c *checker) fetch(link checkLink, probe bool) (*gemproto.Response, string) {
+	chain := []string{link.url}
+	rawURL := link.url
+
+	get := c.client.Get
+	if probe {
+		get = c.client.Probe
+	}
+
+	for {
+		res, err := get(rawURL)
+		if errors.Is(err, gemproto.ErrHeaderTooLong) {
+			c.addProblem("oversized-meta", rawURL, link.source, err.Error(), nil)
+			return nil, ""
+		} else if err != nil {
+			c.addProblem("broken", rawURL, link.source, err.Error(), nil)
+			return nil, ""
+		}
+
+		if res.StatusCode/10 != 3 {
+			if len(chain) > 2 {
+				c.addProblem("redirect-chain", link.url, link.source,
+					fmt.Sprintf("%d redirects", len(chain)-1), chain)
+			}
+
+			detail := fmt.Sprintf("%d %s", res.StatusCode, res.Meta)
+
+			switch res.StatusCode / 10 {
+			case 2:
+				return res, rawURL
+			case 1:
+				c.addSkipped("input", rawURL, link.source, detail)
+			case 6:
+				c.addSkipped("certificate", rawURL, link.source, detail)
+			default:
+				c.addProblem("broken", rawURL, link.source, detail, nil)
+			}
+
+			res.Body.Close()
+			return nil, ""
+		}
+
+		res.Body.Close()
+
+		next, err := res.URL.Parse(res.Meta)
+		if err != nil {
+			c.addProblem("broken", rawURL, link.source, err.Error(), nil)
+			return nil, ""
+		}
+
+		rawURL = next.String()
+		chain = append(chain, rawURL)
+
+		if len(chain) > c.maxChain+1 {
+			c.addProblem("broken", link.url, link.source, "too many redirects", chain)
+			return nil, ""
+		}
+	}
+}
+
+func (c *checker) addProblem(kind, url, source, detail string, chain []string) {
+	c.report.Problems = append(c.report.Problems, checkProblem{
+		Kind:   kind,
+		URL:    url,
+		Source: source,
+		Detail: detail,
+		Chain:  chain,
+	})
+}
+
+func (c *checker) addSkipped(kind, url, source, detail string) {
+	c.report.Skipped = append(c.report.Skipped, checkProblem{
+		Kind:   kind,
+		URL:    url,
+		Source: source,
+		Detail: detail,
+	})
+}
+
+func isGemtextMeta(meta string) bool {
+	mediatype, _, _ := strings.Cut(meta, ";")
+	gemtype, _, _ := strings.Cut(gemtext.MIMEType, ";")
+	return strings.TrimSpace(mediatype) == gemtype
+}
+
+func writeCheckReport(w io.Writer, report *checkReport) {
+	fmt.Fprintf(w, "# Link check of %s\n\n", report.URL)
+	fmt.Fprintf(w, "Crawled %d pages and found %d links with %d problems.\n", report.Pages, report.Links, len(report.Problems))
+	if len(report.Skipped) != 0 {
+		fmt.Fprintf(w, "Skipped %d links that ask for input or a client certificate.\n", len(report.Skipped))
+	}
+
+	sections := []struct {
+		kind     string
+		title    string
+		problems []checkProblem
+	}{
+		{"broken", "Broken links", report.Problems},
+		{"redirect-chain", "Redirect chains", report.Problems},
+		{"oversized-meta", "Oversized metas", report.Problems},
+		{"input", "Skipped input prompts", report.Skipped},
+		{"certificate", "Skipped links requiring a certificate", report.Skipped},
+	}
+
+	for _, section := range sections {
+		var header bool
+		for _, p := range section.problems {
+			if p.Kind != section.kind {
+				continue
+			} else if !header {
+				header = true
+				fmt.Fprintf(w, "\n## %s\n\n", section.title)
+			}
+
+			fmt.Fprintf(w, "=> %s %s\n", p.URL, p.Detail)
+			if p.Source != "" {
+				fmt.Fprintf(w, "* linked from %s\n", p.Source)
+			}
+			if len(p.Chain) != 0 {
+				fmt.Fprintf(w, "* %s\n", strings.Join(p.Chain, " -> "))
+			}
+		}
+	}
+}
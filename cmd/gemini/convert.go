@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/askeladdk/gemproto/gemtext"
+)
+
+// formatOf guesses the format from the extension of a file name.
+func formatOf(filename, fallback string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return "md"
+	case ".gmi", ".gemini":
+		return "gmi"
+	case ".html", ".htm":
+		return "html"
+	case ".txt":
+		return "txt"
+	default:
+		return fallback
+	}
+}
+
+func convert(args []string) {
+	fset := flag.NewFlagSet("convert", flag.ExitOnError)
+
+	var (
+		from = fset.String("from", "", "input format: gmi or md (default from file extension)")
+		to   = fset.String("to", "", "output format: gmi, html or txt (default from output extension)")
+		out  = fset.String("o", "", "output file (default stdout)")
+	)
+
+	if err := fset.Parse(args); err != nil {
+		fset.Usage()
+		die(err)
+	}
+
+	var src []byte
+	var err error
+	if filename := fset.Arg(0); filename == "" || filename == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		die(err)
+	}
+
+	if *from == "" {
+		*from = formatOf(fset.Arg(0), "gmi")
+	}
+
+	if *to == "" {
+		*to = formatOf(*out, "gmi")
+	}
+
+	// every conversion passes through gemtext
+	var gmi bytes.Buffer
+	switch *from {
+	case "gmi":
+		gmi.Write(src)
+	case "md":
+		err = gemtext.FromMarkdown(&gmi, bytes.NewReader(src))
+	default:
+		err = fmt.Errorf("convert: unsupported input format %q", *from)
+	}
+	if err != nil {
+		die(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			die(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *to {
+	case "gmi":
+		_, err = gmi.WriteTo(w)
+	case "html":
+		err = gemtext.WriteHTML(w, &gmi)
+	case "txt":
+		err = gemtext.WriteText(w, &gmi)
+	default:
+		err = fmt.Errorf("convert: unsupported output format %q", *to)
+	}
+	if err != nil {
+		die(err)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/askeladdk/gemproto/gemtext"
+)
+
+func gemfmt(args []string) {
+	fset := flag.NewFlagSet("fmt", flag.ExitOnError)
+
+	var (
+		write = fset.Bool("w", false, "write result to the source file instead of stdout")
+		diff  = fset.Bool("d", false, "display diffs instead of rewriting files")
+	)
+
+	if err := fset.Parse(args); err != nil {
+		fset.Usage()
+		die(err)
+	}
+
+	if fset.NArg() == 0 {
+		if *write {
+			die(fmt.Errorf("fmt: cannot use -w with standard input"))
+		}
+
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			die(err)
+		}
+
+		if err := formatFile("<standard input>", src, false, *diff); err != nil {
+			die(err)
+		}
+		return
+	}
+
+	for _, filename := range fset.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			die(err)
+		}
+
+		if err := formatFile(filename, src, *write, *diff); err != nil {
+			die(err)
+		}
+	}
+}
+
+func formatFile(filename string, src []byte, write, diff bool) error {
+	var buf bytes.Buffer
+	if err := gemtext.Normalize(&buf, bytes.NewReader(src)); err != nil {
+		return err
+	}
+
+	res := buf.Bytes()
+
+	switch {
+	case diff:
+		if !bytes.Equal(src, res) {
+			writeDiff(os.Stdout, filename, string(src), string(res))
+		}
+	case write:
+		if !bytes.Equal(src, res) {
+			fi, err := os.Stat(filename)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(filename, res, fi.Mode().Perm())
+		}
+	default:
+		_, err := os.Stdout.Write(res)
+		return err
+	}
+
+	return nil
+}
+
+// diffContext is the number of unchanged lines around each hunk.
+const diffContext = 3
+
+type diffOp struct {
+	op   byte
+	line string
+}
+
+// writeDiff writes a unified diff of a and b,
+// which can be applied with patch or git apply.
+func writeDiff(w io.Writer, filename, a, b string) {
+	alines := splitLines(a)
+	blines := splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of alines[i:] and blines[j:]
+	lcs := make([][]int, len(alines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(blines)+1)
+	}
+	for i := len(alines) - 1; i >= 0; i-- {
+		for j := len(blines) - 1; j >= 0; j-- {
+			if alines[i] == blines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// the edit script turns a into b
+	var ops []diffOp
+	for i, j := 0, 0; i < len(alines) || j < len(blines); {
+		if i < len(alines) && j < len(blines) && alines[i] == blines[j] {
+			ops = append(ops, diffOp{' ', alines[i]})
+			i, j = i+1, j+1
+		} else if j == len(blines) || (i < len(alines) && lcs[i+1][j] >= lcs[i][j+1]) {
+			ops = append(ops, diffOp{'-', alines[i]})
+			i++
+		} else {
+			ops = append(ops, diffOp{'+', blines[j]})
+			j++
+		}
+	}
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", filename, filename)
+
+	// aline and bline are the number of lines of a and b before ops[k]
+	var aline, bline int
+	for k := 0; k < len(ops); {
+		if ops[k].op == ' ' {
+			aline, bline, k = aline+1, bline+1, k+1
+			continue
+		}
+
+		// start the hunk with up to diffContext unchanged lines
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		astart, bstart := aline-(k-start), bline-(k-start)
+
+		// extend the hunk until the next change is too far away
+		end := k
+		for unchanged := 0; end < len(ops) && unchanged <= 2*diffContext; end++ {
+			if ops[end].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > k && ops[end-1].op == ' ' {
+			end--
+		}
+		if end += diffContext; end > len(ops) {
+			end = len(ops)
+		}
+
+		var acount, bcount int
+		for _, o := range ops[start:end] {
+			if o.op != '+' {
+				acount++
+			}
+			if o.op != '-' {
+				bcount++
+			}
+		}
+
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(astart, acount), hunkRange(bstart, bcount))
+		for _, o := range ops[start:end] {
+			writeDiffLine(w, o.op, o.line)
+		}
+
+		aline, bline = astart+acount, bstart+bcount
+		k = end
+	}
+}
+
+// hunkRange formats the range of a hunk as start,count,
+// where start is the line before the hunk if the range is empty.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits s after every newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func writeDiffLine(w io.Writer, op byte, line string) {
+	fmt.Fprintf(w, "%c%s", op, line)
+	if !strings.HasSuffix(line, "\n") {
+		fmt.Fprint(w, "\n\\ No newline at end of file\n")
+	}
+}
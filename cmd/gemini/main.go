@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,9 +29,13 @@ func capsule(args []string) {
 	fset := flag.NewFlagSet("capsule", flag.ExitOnError)
 
 	var (
-		addr     = fset.String("addr", "0.0.0.0:1965", "host:port to listen on")
+		addr     = fset.String("addr", "0.0.0.0:1965", "comma-separated host:port or socket paths to listen on")
+		network  = fset.String("network", "tcp", "network to listen on: tcp or unix")
 		certfile = fset.String("certfile", "server.crt", "public key")
 		keyfile  = fset.String("keyfile", "server.key", "private key")
+		autocert = fset.String("autocert", "", "host name of a self-signed certificate that is created if the key pair does not exist")
+		legacy   = fset.Bool("legacy", false, "fix requests from legacy clients before routing")
+		inetd    = fset.Bool("inetd", false, "serve a single request over stdin and stdout")
 	)
 
 	if err := fset.Parse(args); err != nil {
@@ -42,33 +47,83 @@ func capsule(args []string) {
 	dir := fset.Arg(0)
 	dir, _ = filepath.Abs(dir)
 
-	cert, err := tls.LoadX509KeyPair(*certfile, *keyfile)
+	if *autocert != "" {
+		if _, err := gemcert.LoadOrCreateX509KeyPair(*certfile, *keyfile, gemcert.CreateOptions{
+			Duration: 365 * 24 * time.Hour,
+			DNSNames: []string{*autocert},
+			Subject: pkix.Name{
+				CommonName: *autocert,
+			},
+		}); err != nil {
+			die(err)
+		}
+	}
+
+	reloader, err := gemcert.NewCertReloader(*certfile, *keyfile)
 	if err != nil {
 		fmt.Println("error when loading key pair:", err)
 		fset.Usage()
 		return
 	}
 
+	reloader.OnError = func(err error) {
+		log.Println("error when reloading key pair:", err)
+	}
+
 	mux := gemproto.NewServeMux()
+	mux.Handle("/.well-known/software", gemproto.SoftwareHandler())
 	mux.Mount("/", gemproto.FileServer(gemproto.Dir(dir),
 		gemproto.UseMetaFile|gemproto.ListDirs))
 
 	srv := gemproto.Server{
 		Addr:    *addr,
+		Network: *network,
 		Handler: mux,
 		Logger:  log.Default(),
 		TLSConfig: &tls.Config{
-			MinVersion:   tls.VersionTLS12,
-			ClientAuth:   tls.RequestClientCert,
-			Certificates: []tls.Certificate{cert},
+			MinVersion:     tls.VersionTLS12,
+			ClientAuth:     tls.RequestClientCert,
+			GetCertificate: reloader.GetCertificate,
 		},
 	}
 
+	if *legacy {
+		srv.Middleware = append(srv.Middleware, gemproto.Normalize(gemproto.LegacyClientFixes))
+	}
+
 	log.Default().SetFlags(log.LstdFlags | log.LUTC)
-	log.Printf("listening on %s\n", srv.Addr)
+
+	if *inetd {
+		if err := srv.ServeStdio(context.Background()); err != nil {
+			die(err)
+		}
+		return
+	}
+
+	listeners, err := gemproto.SystemdListeners()
+	if err != nil {
+		die(err)
+	}
 
 	ctx := context.Background()
-	if err := srv.ListenAndServe(ctx); !errors.Is(err, gemproto.ErrServerClosed) {
+
+	// pick up renewed certificates without restarting
+	go reloader.Watch(ctx, time.Minute)
+
+	log.Printf("%s\n", gemproto.Software())
+
+	// prefer the socket passed by systemd socket activation
+	if len(listeners) != 0 {
+		for _, l := range listeners {
+			log.Printf("listening on %s (systemd)\n", l.Addr())
+		}
+		err = srv.ServeListeners(ctx, listeners...)
+	} else {
+		log.Printf("listening on %s\n", srv.Addr)
+		err = srv.ListenAndServeAddrs(ctx, strings.Split(srv.Addr, ",")...)
+	}
+
+	if !errors.Is(err, gemproto.ErrServerClosed) {
 		log.Println(err)
 	}
 }
@@ -79,6 +134,7 @@ func get(args []string) {
 	var (
 		certfile = fset.String("certfile", "", "public key")
 		keyfile  = fset.String("keyfile", "", "private key")
+		checksum = fset.String("sha256", "", "expected sha256 digest of the body")
 	)
 
 	if err := fset.Parse(args); err != nil {
@@ -92,6 +148,7 @@ func get(args []string) {
 		ConnectTimeout: 1 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		ReadTimeout:    600 * time.Second,
+		FileRoot:       gemproto.Dir("/"),
 	}
 
 	if *certfile != "" && *keyfile != "" {
@@ -103,7 +160,13 @@ func get(args []string) {
 		client.GetCertificate = gemproto.SingleClientCertificate(cert)
 	}
 
-	res, err := client.Get(rawURL)
+	var res *gemproto.Response
+	var err error
+	if *checksum != "" {
+		res, err = client.GetVerified(rawURL, *checksum)
+	} else {
+		res, err = client.Get(rawURL)
+	}
 	if err != nil {
 		die(err)
 	}
@@ -134,13 +197,22 @@ func makecert(args []string) {
 		os.Exit(1)
 	}
 
-	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+	options := gemcert.CreateOptions{
 		Duration: time.Duration(*days) * 24 * time.Hour,
-		DNSNames: []string{*name},
 		Subject: pkix.Name{
 			CommonName: *name,
 		},
-	})
+		RequireSAN: true,
+	}
+
+	// IP-only capsules need the address as an IP SAN
+	if ip := net.ParseIP(*name); ip != nil {
+		options.IPAddresses = []net.IP{ip}
+	} else {
+		options.DNSNames = []string{*name}
+	}
+
+	cert, err := gemcert.CreateX509KeyPair(options)
 	if err != nil {
 		die(err)
 	}
@@ -195,15 +267,39 @@ func main() {
 		makecert(os.Args[2:])
 	case "viewcert":
 		viewcert(os.Args[2:])
+	case "share":
+		share(os.Args[2:])
+	case "check":
+		check(os.Args[2:])
+	case "fmt":
+		gemfmt(os.Args[2:])
+	case "convert":
+		convert(os.Args[2:])
+	case "ping":
+		ping(os.Args[2:])
+	case "version":
+		fmt.Println(gemproto.Software())
 	default:
 		fmt.Println("Usage of gemini:")
-		fmt.Println("  gemini capsule [-addr=:1965] [-certfile=server.crt] [-keyfile=server.key] root")
+		fmt.Println("  gemini capsule [-addr=:1965] [-network=tcp] [-certfile=server.crt] [-keyfile=server.key] [-autocert=<name>] [-legacy] [-inetd] root")
 		fmt.Println("    Launch a capsule into Geminispace.")
-		fmt.Println("  gemini get [-certfile=<path>] [-keyfile=<path>] <uri>")
-		fmt.Println("    Retrieve and stream a Gemini resource to stdout.")
+		fmt.Println("  gemini get [-certfile=<path>] [-keyfile=<path>] [-sha256=<digest>] <uri>")
+		fmt.Println("    Retrieve and stream a Gemini or local file resource to stdout.")
 		fmt.Println("  gemini makecert -out=<path> -name=<name> -days=<n>")
 		fmt.Println("    Generate a fresh self-signed certificate.")
 		fmt.Println("  gemini viewcert -certfile=<path> -keyfile=<path>")
 		fmt.Println("    View certificate details.")
+		fmt.Println("  gemini share [-addr=:1965] [-name=localhost] [-once] [-expire=<duration>] <file-or-dir>")
+		fmt.Println("    Share a file or directory on a temporary capsule with an ephemeral certificate.")
+		fmt.Println("  gemini check [-external] [-json] [-max=1000] <uri>")
+		fmt.Println("    Crawl a capsule and report broken links, redirect chains and oversized metas.")
+		fmt.Println("  gemini fmt [-w] [-d] [file...]")
+		fmt.Println("    Normalize gemtext files, or standard input if no files are given.")
+		fmt.Println("  gemini convert [-from=gmi|md] [-to=gmi|html|txt] [-o=<path>] [file]")
+		fmt.Println("    Convert between Markdown, gemtext, HTML and plain text.")
+		fmt.Println("  gemini ping [-count=1] [-interval=1s] [-timeout=10s] <host[:port]>")
+		fmt.Println("    Check that a host is up and report latency, status and certificate expiry.")
+		fmt.Println("  gemini version")
+		fmt.Println("    Print the gemproto version.")
 	}
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+)
+
+func ping(args []string) {
+	fset := flag.NewFlagSet("ping", flag.ExitOnError)
+
+	var (
+		count    = fset.Int("count", 1, "number of pings, or 0 to ping forever")
+		interval = fset.Duration("interval", time.Second, "time between pings")
+		timeout  = fset.Duration("timeout", 10*time.Second, "timeout of each ping")
+	)
+
+	if err := fset.Parse(args); err != nil {
+		fset.Usage()
+		die(err)
+	}
+
+	addr := fset.Arg(0)
+	if addr == "" {
+		fset.Usage()
+		os.Exit(1)
+	}
+
+	var failed bool
+
+	for i := 0; *count == 0 || i < *count; i++ {
+		if i > 0 {
+			time.Sleep(*interval)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		result, err := gemproto.Ping(ctx, addr)
+		cancel()
+
+		if err != nil {
+			failed = true
+			fmt.Printf("%s: %s\n", result.URL, err)
+			continue
+		}
+
+		fmt.Printf("%s: %d %s time=%s", result.URL, result.StatusCode, result.Meta, result.Latency.Round(time.Millisecond))
+		if expiry := result.Expiry(); !expiry.IsZero() {
+			days := int(time.Until(expiry).Hours() / 24)
+			fmt.Printf(" cert-expires=%s (%d days)", expiry.Format("2006-01-02"), days)
+		}
+		fmt.Println()
+
+		if result.StatusCode/10 == 4 || result.StatusCode/10 == 5 {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
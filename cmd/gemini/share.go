@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+)
+
+func share(args []string) {
+	fset := flag.NewFlagSet("share", flag.ExitOnError)
+
+	var (
+		addr   = fset.String("addr", "0.0.0.0:1965", "host:port to listen on")
+		name   = fset.String("name", "localhost", "host name of the certificate")
+		once   = fset.Bool("once", false, "stop after the first successful download")
+		expire = fset.Duration("expire", 0, "stop after this duration")
+	)
+
+	if err := fset.Parse(args); err != nil {
+		fset.Usage()
+		die(err)
+	}
+
+	if fset.NArg() != 1 {
+		fset.Usage()
+		os.Exit(1)
+	}
+
+	root, err := filepath.Abs(fset.Arg(0))
+	if err != nil {
+		die(err)
+	}
+
+	fi, err := os.Stat(root)
+	if err != nil {
+		die(err)
+	}
+
+	duration := *expire
+	if duration == 0 {
+		duration = 24 * time.Hour
+	}
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		Duration:   duration,
+		DNSNames:   []string{*name},
+		ListenAddr: *addr,
+		Subject: pkix.Name{
+			CommonName: *name,
+		},
+	})
+	if err != nil {
+		die(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *expire > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *expire)
+		defer cancel()
+	}
+
+	// serve a single file at its base name or the directory tree
+	var handler gemproto.Handler
+	path := "/"
+	if fi.IsDir() {
+		handler = gemproto.FileServer(gemproto.Dir(root), gemproto.ListDirs)
+	} else {
+		path += filepath.Base(root)
+		mux := gemproto.NewServeMux()
+		// only the exact path is routed so the rest of the directory is not exposed
+		mux.Handle(path, gemproto.FileServer(gemproto.Dir(filepath.Dir(root)), gemproto.ShowHiddenFiles))
+		mux.Handle("/", gemproto.RedirectHandler(path, gemproto.StatusTemporaryRedirect))
+		handler = mux
+	}
+
+	if *once {
+		next := handler
+		handler = gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			gemproto.AfterResponse(r, func(info gemproto.ResponseInfo) {
+				// directory listings do not count as downloads
+				if info.StatusCode/10 == 2 && info.Err == nil && !strings.HasSuffix(r.URL.Path, "/") {
+					log.Printf("%s downloaded by %s\n", r.URL.Path, r.RemoteAddr)
+					cancel()
+				}
+			})
+			next.ServeGemini(w, r)
+		})
+	}
+
+	srv := gemproto.Server{
+		Addr:    *addr,
+		Handler: handler,
+		Logger:  log.Default(),
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			ClientAuth:   tls.RequestClientCert,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+
+	_, port, _ := net.SplitHostPort(*addr)
+	fmt.Printf("%s\n", gemproto.Software())
+	fmt.Printf("sharing %s at gemini://%s\n", root, net.JoinHostPort(*name, port)+path)
+	fmt.Printf("certificate fingerprint %s\n", gemcert.Fingerprint(cert.Leaf))
+
+	if err := srv.ListenAndServe(ctx); !errors.Is(err, gemproto.ErrServerClosed) {
+		die(err)
+	}
+}
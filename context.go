@@ -0,0 +1,99 @@
+package gemproto
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+)
+
+// ContextKey is a typed key for request-scoped values.
+// Keys are compared by identity, so values stored under
+// different keys never collide even if their names are equal.
+//
+// Middleware should share values through the predefined keys where
+// possible, so that downstream handlers and middleware interoperate:
+//
+//	var UserKey = gemproto.NewContextKey[*User]("user")
+//
+//	func auth(next gemproto.Handler) gemproto.Handler {
+//	  return gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+//	    next.ServeGemini(w, gemproto.WithValue(r, UserKey, lookupUser(r)))
+//	  })
+//	}
+//
+//	func handler(w gemproto.ResponseWriter, r *gemproto.Request) {
+//	  user, ok := gemproto.FromContext(r.Context(), UserKey)
+//	  // ...
+//	}
+type ContextKey[T any] struct {
+	name string
+}
+
+// NewContextKey returns a new key for values of type T.
+// The name is only used for debugging.
+func NewContextKey[T any](name string) *ContextKey[T] {
+	return &ContextKey[T]{name: name}
+}
+
+// String implements the fmt.Stringer interface.
+func (k *ContextKey[T]) String() string {
+	return "gemproto context value " + k.name
+}
+
+// Predefined keys for values that are commonly shared between middleware.
+var (
+	// RequestIDKey stores a string that uniquely identifies a request.
+	// It is set by Server, so that log lines of the same request
+	// can be correlated.
+	RequestIDKey = NewContextKey[string]("request-id")
+
+	// LoggerKey stores a request-scoped Logger.
+	// It is set by Server to Server.Logger if it is not nil,
+	// and middleware may replace it with a more specific Logger.
+	LoggerKey = NewContextKey[Logger]("logger")
+
+	// ServerContextKey stores the Server that received the request.
+	// It is set by Server.
+	ServerContextKey = NewContextKey[*Server]("server")
+)
+
+var (
+	requestIDPrefix  = newRequestIDPrefix()
+	requestIDCounter uint64
+)
+
+func newRequestIDPrefix() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// newRequestID returns an identifier that is unique within the process
+// and unlikely to collide with those of other processes.
+func newRequestID() string {
+	return requestIDPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&requestIDCounter, 1), 10)
+}
+
+// WithValue returns a shallow copy of r whose context stores val under key.
+func WithValue[T any](r *Request, key *ContextKey[T], val T) *Request {
+	return r.WithContext(context.WithValue(r.Context(), key, val))
+}
+
+// FromContext returns the value stored under key in ctx
+// and reports whether it was present.
+func FromContext[T any](ctx context.Context, key *ContextKey[T]) (T, bool) {
+	val, ok := ctx.Value(key).(T)
+	return val, ok
+}
+
+// contextLogger returns l if it is not nil, or else the Logger stored
+// under LoggerKey in ctx. It returns nil if neither is set,
+// in which case nothing should be logged.
+func contextLogger(ctx context.Context, l Logger) Logger {
+	if l == nil {
+		l, _ = FromContext(ctx, LoggerKey)
+	}
+	return l
+}
@@ -0,0 +1,41 @@
+package gemproto_test
+
+import (
+	"log"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestContextValues(t *testing.T) {
+	t.Parallel()
+
+	otherKey := gemproto.NewContextKey[string]("request-id")
+
+	r := gemtest.NewRequest("/")
+	_, ok := gemproto.FromContext(r.Context(), gemproto.RequestIDKey)
+	require.True(t, !ok)
+
+	r = gemproto.WithValue(r, gemproto.RequestIDKey, "abc")
+	r = gemproto.WithValue(r, otherKey, "def")
+	r = gemproto.WithValue[gemproto.Logger](r, gemproto.LoggerKey, log.Default())
+
+	id, ok := gemproto.FromContext(r.Context(), gemproto.RequestIDKey)
+	require.True(t, ok)
+	require.Equal(t, "abc", id)
+
+	other, ok := gemproto.FromContext(r.Context(), otherKey)
+	require.True(t, ok)
+	require.Equal(t, "def", other)
+
+	logger, ok := gemproto.FromContext(r.Context(), gemproto.LoggerKey)
+	require.True(t, ok)
+	require.True(t, logger == gemproto.Logger(log.Default()))
+
+	// the zero request has a usable context
+	r = gemproto.WithValue(&gemproto.Request{}, gemproto.RequestIDKey, "xyz")
+	id, _ = gemproto.FromContext(r.Context(), gemproto.RequestIDKey)
+	require.Equal(t, "xyz", id)
+}
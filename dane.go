@@ -0,0 +1,164 @@
+package gemproto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// TLSA certificate usages as described in RFC 6698.
+const (
+	TLSAUsagePKIXTA = 0
+	TLSAUsagePKIXEE = 1
+	TLSAUsageDANETA = 2
+	TLSAUsageDANEEE = 3
+)
+
+// TLSA selectors as described in RFC 6698.
+const (
+	TLSASelectorCert = 0
+	TLSASelectorSPKI = 1
+)
+
+// TLSA matching types as described in RFC 6698.
+const (
+	TLSAMatchingFull   = 0
+	TLSAMatchingSHA256 = 1
+	TLSAMatchingSHA512 = 2
+)
+
+// TLSARecord is a DNS TLSA resource record.
+type TLSARecord struct {
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+	Data         []byte
+}
+
+// DANEVerifier implements CertificateVerifier by validating
+// host certificates against DNS TLSA records (DANE).
+//
+// The standard library cannot query TLSA records,
+// so the lookup must be provided by the caller.
+// The lookup is responsible for DNSSEC validation:
+// records that are not authenticated must not be returned.
+//
+//	client := gemproto.Client{
+//	  VerifyMode: gemproto.VerifyCustom,
+//	  Verifier: &gemproto.DANEVerifier{
+//	    LookupTLSA: lookupTLSA,
+//	  },
+//	}
+type DANEVerifier struct {
+	// LookupTLSA returns the DNSSEC validated TLSA records
+	// for the given host and port.
+	LookupTLSA func(host, port string) ([]TLSARecord, error)
+
+	// Roots is the set of root certificates used to validate
+	// PKIX-TA and PKIX-EE records.
+	// If nil, the system roots are used.
+	Roots *x509.CertPool
+}
+
+// VerifyCertificate implements CertificateVerifier.
+// The certificate is trusted if it matches at least one TLSA record.
+func (v *DANEVerifier) VerifyCertificate(cs tls.ConnectionState, addr string) error {
+	if v.LookupTLSA == nil || len(cs.PeerCertificates) == 0 {
+		return ErrCertificateNotTrusted
+	}
+
+	host, port := splitHostPort(addr)
+
+	records, err := v.LookupTLSA(host, port)
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		if v.match(rec, cs.PeerCertificates, host) {
+			return nil
+		}
+	}
+
+	return ErrCertificateNotTrusted
+}
+
+func (v *DANEVerifier) match(rec TLSARecord, certs []*x509.Certificate, host string) bool {
+	switch rec.Usage {
+	case TLSAUsageDANEEE:
+		return matchTLSA(rec, certs[0])
+	case TLSAUsageDANETA:
+		// the leaf must chain to the trust anchor, not merely be
+		// presented alongside it
+		for _, cert := range certs[1:] {
+			if matchTLSA(rec, cert) {
+				roots := x509.NewCertPool()
+				roots.AddCert(cert)
+				if _, err := verifyChains(certs, host, roots); err == nil {
+					return true
+				}
+			}
+		}
+	case TLSAUsagePKIXEE:
+		if !matchTLSA(rec, certs[0]) {
+			return false
+		}
+		_, err := verifyChains(certs, host, v.Roots)
+		return err == nil
+	case TLSAUsagePKIXTA:
+		// the trust anchor must be part of a verified chain
+		chains, err := verifyChains(certs, host, v.Roots)
+		if err != nil {
+			return false
+		}
+		for _, chain := range chains {
+			for _, cert := range chain[1:] {
+				if matchTLSA(rec, cert) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// verifyChains verifies that the leaf chains to roots
+// through the presented intermediates and is valid for host.
+func verifyChains(certs []*x509.Certificate, host string, roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	return certs[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+}
+
+func matchTLSA(rec TLSARecord, cert *x509.Certificate) bool {
+	var data []byte
+	switch rec.Selector {
+	case TLSASelectorCert:
+		data = cert.Raw
+	case TLSASelectorSPKI:
+		data = cert.RawSubjectPublicKeyInfo
+	default:
+		return false
+	}
+
+	switch rec.MatchingType {
+	case TLSAMatchingFull:
+		return bytes.Equal(rec.Data, data)
+	case TLSAMatchingSHA256:
+		h := sha256.Sum256(data)
+		return bytes.Equal(rec.Data, h[:])
+	case TLSAMatchingSHA512:
+		h := sha512.Sum512(data)
+		return bytes.Equal(rec.Data, h[:])
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package gemproto_test
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestDANEVerifier(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+	defer server.Close()
+
+	spki := sha256.Sum256(server.Certificate.Leaf.RawSubjectPublicKeyInfo)
+
+	var records []gemproto.TLSARecord
+
+	client := gemproto.Client{
+		VerifyMode: gemproto.VerifyCustom,
+		Verifier: &gemproto.DANEVerifier{
+			LookupTLSA: func(host, port string) ([]gemproto.TLSARecord, error) {
+				require.Equal(t, "localhost", host)
+				return records, nil
+			},
+		},
+	}
+
+	_, err := client.Get(server.URL)
+	require.True(t, errors.Is(err, gemproto.ErrCertificateNotTrusted), err)
+
+	records = []gemproto.TLSARecord{{
+		Usage:        gemproto.TLSAUsageDANEEE,
+		Selector:     gemproto.TLSASelectorSPKI,
+		MatchingType: gemproto.TLSAMatchingSHA256,
+		Data:         spki[:],
+	}}
+
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, gemproto.StatusOK, res.StatusCode)
+}
+
+func TestDANEVerifierTrustAnchor(t *testing.T) {
+	t.Parallel()
+
+	newCA := func(name string) tls.Certificate {
+		cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+			Subject:  pkix.Name{CommonName: name},
+			Duration: time.Hour,
+			IsCA:     true,
+		})
+		require.NoError(t, err)
+		return cert
+	}
+
+	newLeaf := func(parent *tls.Certificate) *x509.Certificate {
+		opts := gemcert.CreateOptions{
+			Subject:  pkix.Name{CommonName: "localhost"},
+			DNSNames: []string{"localhost"},
+			Duration: time.Hour,
+		}
+		if parent != nil {
+			opts.Parent, opts.ParentKey = parent.Leaf, parent.PrivateKey
+		}
+		cert, err := gemcert.CreateX509KeyPair(opts)
+		require.NoError(t, err)
+		return cert.Leaf
+	}
+
+	ca, otherCA := newCA("ca"), newCA("other ca")
+	leaf := newLeaf(&ca)
+
+	// an unrelated leaf presented alongside the trust anchor
+	unrelated := newLeaf(nil)
+
+	// a leaf that verifies through another root in Roots
+	otherLeaf := newLeaf(&otherCA)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	roots.AddCert(otherCA.Leaf)
+
+	spki := sha256.Sum256(ca.Leaf.RawSubjectPublicKeyInfo)
+
+	verify := func(usage uint8, certs ...*x509.Certificate) error {
+		v := gemproto.DANEVerifier{
+			LookupTLSA: func(host, port string) ([]gemproto.TLSARecord, error) {
+				return []gemproto.TLSARecord{{
+					Usage:        usage,
+					Selector:     gemproto.TLSASelectorSPKI,
+					MatchingType: gemproto.TLSAMatchingSHA256,
+					Data:         spki[:],
+				}}, nil
+			},
+			Roots: roots,
+		}
+		return v.VerifyCertificate(tls.ConnectionState{PeerCertificates: certs}, "localhost:1965")
+	}
+
+	for _, usage := range []uint8{gemproto.TLSAUsageDANETA, gemproto.TLSAUsagePKIXTA} {
+		require.NoError(t, verify(usage, leaf, ca.Leaf))
+		require.ErrorIs(t, verify(usage, unrelated, ca.Leaf), gemproto.ErrCertificateNotTrusted)
+		require.ErrorIs(t, verify(usage, otherLeaf, ca.Leaf), gemproto.ErrCertificateNotTrusted)
+	}
+}
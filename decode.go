@@ -0,0 +1,89 @@
+package gemproto
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"path"
+	"strings"
+)
+
+// DecoderFunc returns a reader that decompresses r.
+type DecoderFunc func(r io.Reader) (io.ReadCloser, error)
+
+// GzipDecoder decompresses gzip streams.
+func GzipDecoder(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// DefaultDecoders returns the decoders supported by the standard library.
+// Other formats such as zstd can be added by the caller:
+//
+//	decoders := gemproto.DefaultDecoders()
+//	decoders["zstd"] = func(r io.Reader) (io.ReadCloser, error) {
+//	  dec, err := zstd.NewReader(r)
+//	  return dec.IOReadCloser(), err
+//	}
+//	client := gemproto.Client{Decoders: decoders}
+func DefaultDecoders() map[string]DecoderFunc {
+	return map[string]DecoderFunc{
+		"gzip": GzipDecoder,
+	}
+}
+
+// compressionTypes maps compression mimetypes to compression names and extensions.
+var compressionTypes = map[string]struct{ name, ext string }{
+	"application/gzip":   {"gzip", ".gz"},
+	"application/x-gzip": {"gzip", ".gz"},
+	"application/zstd":   {"zstd", ".zst"},
+}
+
+type decodedBody struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if err2 := b.body.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// decode transparently decompresses the body of res if Client.Decoders
+// has a decoder for the compression mimetype of the response.
+func (c *Client) decode(res *Response, err error) (*Response, error) {
+	if err != nil || len(c.Decoders) == 0 || res.StatusCode/10 != 2 {
+		return res, err
+	}
+
+	mediatype, _, _ := mime.ParseMediaType(res.Meta)
+	compression, ok := compressionTypes[mediatype]
+	if !ok {
+		return res, nil
+	}
+
+	decoder, ok := c.Decoders[compression.name]
+	if !ok {
+		return res, nil
+	}
+
+	rc, err := decoder(res.Body)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(path.Base(res.URL.Path), compression.ext)
+	mimetype := mime.TypeByExtension(path.Ext(name))
+	if mimetype == "" {
+		mimetype = "application/octet-stream"
+	}
+
+	res.RawMeta = res.Meta
+	res.Meta = mimetype
+	res.Encoding = compression.name
+	res.Body = &decodedBody{ReadCloser: rc, body: res.Body}
+	return res, nil
+}
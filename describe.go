@@ -0,0 +1,222 @@
+package gemproto
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtext"
+)
+
+// CertificateDescription describes a server certificate.
+type CertificateDescription struct {
+	Subject     string    `json:"subject"`
+	DNSNames    []string  `json:"dns_names,omitempty"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"fingerprint"`
+}
+
+// TLSDescription describes the TLS configuration of a Server.
+type TLSDescription struct {
+	MinVersion   string                   `json:"min_version"`
+	ClientAuth   string                   `json:"client_auth"`
+	Certificates []CertificateDescription `json:"certificates,omitempty"`
+
+	// Dynamic reports whether certificates are selected by GetCertificate,
+	// in which case they cannot be listed.
+	Dynamic bool `json:"dynamic"`
+}
+
+// ServerDescription is the effective configuration of a Server
+// as returned by Describe. Defaults are filled in and durations
+// are in nanoseconds when encoded as JSON, where zero means no limit.
+type ServerDescription struct {
+	Software         string          `json:"software"`
+	Addr             string          `json:"addr"`
+	Network          string          `json:"network"`
+	Insecure         bool            `json:"insecure"`
+	Hosts            []string        `json:"hosts,omitempty"`
+	Hostname         string          `json:"hostname,omitempty"`
+	ReadTimeout      time.Duration   `json:"read_timeout"`
+	WriteTimeout     time.Duration   `json:"write_timeout"`
+	SlidingTimeout   bool            `json:"sliding_write_timeout"`
+	HandshakeTimeout time.Duration   `json:"handshake_timeout"`
+	IdleTimeout      time.Duration   `json:"idle_timeout"`
+	DrainTimeout     time.Duration   `json:"drain_timeout"`
+	MaxConns         int             `json:"max_conns"`
+	MaxHandshakes    int             `json:"max_handshakes"`
+	MaxRequestBytes  int             `json:"max_request_bytes"`
+	Middleware       int             `json:"middleware"`
+	ProxyProtocol    bool            `json:"proxy_protocol"`
+	TLS              *TLSDescription `json:"tls,omitempty"`
+
+	// Routes lists the patterns if the handler is a *ServeMux.
+	Routes []RouteInfo `json:"routes,omitempty"`
+}
+
+// tlsVersionName returns the name of a TLS version.
+// The zero version leaves the minimum to crypto/tls,
+// which differs between Go releases.
+func tlsVersionName(v uint16) string {
+	switch v {
+	case 0:
+		return "default"
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", v)
+	}
+}
+
+// Describe returns the effective configuration of the server,
+// for troubleshooting capsules in production.
+func (srv *Server) Describe() ServerDescription {
+	d := ServerDescription{
+		Software:         Software(),
+		Addr:             srv.Addr,
+		Network:          srv.Network,
+		Insecure:         srv.Insecure,
+		Hosts:            srv.Hosts,
+		Hostname:         srv.Hostname,
+		ReadTimeout:      srv.ReadTimeout,
+		WriteTimeout:     srv.WriteTimeout,
+		SlidingTimeout:   srv.SlidingWriteTimeout,
+		HandshakeTimeout: srv.HandshakeTimeout,
+		IdleTimeout:      srv.IdleTimeout,
+		DrainTimeout:     srv.DrainTimeout,
+		MaxConns:         srv.MaxConns,
+		MaxHandshakes:    srv.MaxHandshakes,
+		MaxRequestBytes:  srv.maxRequestLine() - 2,
+		Middleware:       len(srv.Middleware),
+		ProxyProtocol:    srv.ProxyProtocol,
+	}
+
+	if d.Addr == "" {
+		d.Addr = ":1965"
+	}
+
+	if d.Network == "" {
+		d.Network = "tcp"
+	}
+
+	if !srv.Insecure && srv.TLSConfig != nil {
+		d.TLS = &TLSDescription{
+			MinVersion: tlsVersionName(srv.TLSConfig.MinVersion),
+			ClientAuth: srv.TLSConfig.ClientAuth.String(),
+			Dynamic:    srv.TLSConfig.GetCertificate != nil,
+		}
+
+		for _, cert := range srv.TLSConfig.Certificates {
+			leaf := cert.Leaf
+			if leaf == nil && len(cert.Certificate) != 0 {
+				leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+			}
+			if leaf != nil {
+				d.TLS.Certificates = append(d.TLS.Certificates, CertificateDescription{
+					Subject:     leaf.Subject.String(),
+					DNSNames:    leaf.DNSNames,
+					NotAfter:    leaf.NotAfter,
+					Fingerprint: gemcert.Fingerprint(leaf),
+				})
+			}
+		}
+	}
+
+	if mux, ok := srv.currentHandler().(*ServeMux); ok {
+		d.Routes = mux.Routes()
+	}
+
+	return d
+}
+
+// orNone formats a limit that is disabled if it is zero.
+func orNone[T comparable](v T) string {
+	var zero T
+	if v == zero {
+		return "none"
+	}
+	return fmt.Sprint(v)
+}
+
+// gemtext formats the description as a gemtext document.
+func (d ServerDescription) gemtext() []byte {
+	b := gemtext.NewBuilder(make([]byte, 0, 1024))
+	b.Heading("Server configuration")
+	b.Point("Software: " + d.Software)
+	b.Point("Listen: " + d.Network + " " + d.Addr)
+	b.Point(fmt.Sprint("Insecure: ", d.Insecure))
+	if len(d.Hosts) != 0 {
+		b.Point("Hosts: " + strings.Join(d.Hosts, ", "))
+	}
+	if d.Hostname != "" {
+		b.Point("Hostname: " + d.Hostname)
+	}
+
+	b.SubHeading("Limits")
+	b.Point("Read timeout: " + orNone(d.ReadTimeout))
+	b.Point("Write timeout: " + orNone(d.WriteTimeout))
+	b.Point(fmt.Sprint("Sliding write timeout: ", d.SlidingTimeout))
+	b.Point("Handshake timeout: " + orNone(d.HandshakeTimeout))
+	b.Point("Idle timeout: " + orNone(d.IdleTimeout))
+	b.Point("Drain timeout: " + orNone(d.DrainTimeout))
+	b.Point("Max connections: " + orNone(d.MaxConns))
+	b.Point("Max handshakes: " + orNone(d.MaxHandshakes))
+	b.Point(fmt.Sprint("Max request bytes: ", d.MaxRequestBytes))
+	b.Point(fmt.Sprint("Middleware: ", d.Middleware))
+	b.Point(fmt.Sprint("PROXY protocol: ", d.ProxyProtocol))
+
+	if d.TLS != nil {
+		b.SubHeading("TLS")
+		b.Point("Min version: " + d.TLS.MinVersion)
+		b.Point("Client auth: " + d.TLS.ClientAuth)
+		b.Point(fmt.Sprint("Dynamic certificates: ", d.TLS.Dynamic))
+		for _, c := range d.TLS.Certificates {
+			b.SubSubHeading(c.Subject)
+			if len(c.DNSNames) != 0 {
+				b.Point("DNS names: " + strings.Join(c.DNSNames, ", "))
+			}
+			b.Point("Expires: " + c.NotAfter.UTC().Format(time.RFC3339))
+			b.Point("Fingerprint: " + c.Fingerprint)
+		}
+	}
+
+	if len(d.Routes) != 0 {
+		b.SubHeading("Routes")
+		for _, route := range d.Routes {
+			if route.Description != "" {
+				b.Point(route.Pattern + " - " + route.Description)
+			} else {
+				b.Point(route.Pattern)
+			}
+		}
+	}
+
+	return b.Bytes()
+}
+
+// DebugHandler returns a Handler that renders the result of Describe
+// as gemtext, or as JSON if the request has the query "json".
+// Access is restricted with RequireFingerprints.
+func (srv *Server) DebugHandler(fingerprints ...string) Handler {
+	return RequireFingerprints(fingerprints...)(HandlerFunc(func(w ResponseWriter, r *Request) {
+		d := srv.Describe()
+
+		if r.URL.RawQuery == "json" {
+			w.WriteHeader(StatusOK, "application/json")
+			_ = json.NewEncoder(w).Encode(d)
+			return
+		}
+
+		_, _ = w.Write(d.gemtext())
+	}))
+}
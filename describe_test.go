@@ -0,0 +1,85 @@
+package gemproto_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestServerDescribe(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		DNSNames: []string{"example.com"},
+		Subject:  pkix.Name{CommonName: "example.com"},
+	})
+	require.NoError(t, err)
+
+	mux := gemproto.NewServeMux()
+	mux.HandleWithInfo("/about", gemproto.NotFoundHandler(), gemproto.RouteInfo{Description: "About"})
+
+	srv := gemproto.Server{
+		Handler:     mux,
+		ReadTimeout: 5 * time.Second,
+		MaxConns:    100,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			ClientAuth:   tls.RequestClientCert,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+
+	d := srv.Describe()
+	require.Equal(t, ":1965", d.Addr)
+	require.Equal(t, "tcp", d.Network)
+	require.Equal(t, 1024, d.MaxRequestBytes)
+	require.Equal(t, "TLS 1.3", d.TLS.MinVersion)
+	require.Equal(t, "RequestClientCert", d.TLS.ClientAuth)
+	require.Equal(t, 1, len(d.TLS.Certificates))
+	require.Equal(t, gemcert.Fingerprint(cert.Leaf), d.TLS.Certificates[0].Fingerprint)
+	require.Equal(t, []gemproto.RouteInfo{{Pattern: "/about", Description: "About"}}, d.Routes)
+
+	srv.TLSConfig.MinVersion = 0
+	require.Equal(t, "default", srv.Describe().TLS.MinVersion)
+	srv.TLSConfig.MinVersion = tls.VersionTLS11
+	require.Equal(t, "TLS 1.1", srv.Describe().TLS.MinVersion)
+	srv.TLSConfig.MinVersion = tls.VersionTLS13
+
+	h := srv.DebugHandler(gemcert.Fingerprint(cert.Leaf))
+
+	serve := func(rawURL string, withCert bool) *gemtest.ResponseRecorder {
+		r := gemtest.NewRequest(rawURL)
+		if withCert {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+		}
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	require.Equal(t, gemproto.StatusClientCertificateRequired, serve("/debug", false).Code)
+
+	w := serve("/debug", true)
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	body := w.Body.String()
+	require.True(t, strings.Contains(body, "* Read timeout: 5s\n"), body)
+	require.True(t, strings.Contains(body, "* Write timeout: none\n"), body)
+	require.True(t, strings.Contains(body, "* Max connections: 100\n"), body)
+	require.True(t, strings.Contains(body, "* /about - About\n"), body)
+
+	w = serve("/debug?json", true)
+	require.Equal(t, "application/json", w.Meta)
+	var decoded gemproto.ServerDescription
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decoded))
+	require.Equal(t, d.ReadTimeout, decoded.ReadTimeout)
+	require.Equal(t, d.TLS.Certificates[0].Fingerprint, decoded.TLS.Certificates[0].Fingerprint)
+}
@@ -0,0 +1,170 @@
+package gemproto
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContextDialer dials network connections.
+// It is implemented by net.Dialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// ErrNoOnionDialer is returned by Client when it is asked to connect
+// to a .onion host but Client.Dialers has no dialer for it.
+// Such hosts are never resolved through DNS to avoid leaking them.
+var ErrNoOnionDialer = errors.New("gemproto: no dialer for .onion host")
+
+// dialerFor returns the dialer with the longest suffix that matches host.
+func dialerFor(dialers map[string]ContextDialer, host string) ContextDialer {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	var match ContextDialer
+	var matchlen = -1
+	for suffix, d := range dialers {
+		suffix = strings.ToLower(suffix)
+		if (host == strings.TrimPrefix(suffix, ".") || strings.HasSuffix(host, suffix)) && len(suffix) > matchlen {
+			match, matchlen = d, len(suffix)
+		}
+	}
+
+	return match
+}
+
+// HostsDialer returns a dialer that resolves hostnames using
+// the hosts map of hostnames to IP addresses before dialing.
+// Hostnames that are not in the map are resolved by the system resolver.
+func HostsDialer(hosts map[string]string) ContextDialer {
+	return hostsDialer(hosts)
+}
+
+type hostsDialer map[string]string
+
+func (h hostsDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip, ok := h[strings.ToLower(host)]; ok {
+		addr = net.JoinHostPort(ip, port)
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+// SOCKS5Dialer returns a dialer that connects through the SOCKS5 proxy
+// at proxyAddr without authentication. Hostnames are resolved by the proxy.
+// It is intended to be used to reach .onion hosts through Tor:
+//
+//	client := gemproto.Client{
+//	  Dialers: map[string]gemproto.ContextDialer{
+//	    ".onion": gemproto.SOCKS5Dialer("127.0.0.1:9050"),
+//	  },
+//	}
+func SOCKS5Dialer(proxyAddr string) ContextDialer {
+	return socks5Dialer(proxyAddr)
+}
+
+type socks5Dialer string
+
+func (proxyAddr socks5Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, portstr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.ParseUint(portstr, 10, 16)
+	if err != nil {
+		return nil, err
+	} else if len(host) > 255 {
+		return nil, errors.New("gemproto: socks5: host name too long")
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, string(proxyAddr))
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if err := socks5Connect(conn, host, uint16(port)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func socks5Connect(rw io.ReadWriter, host string, port uint16) error {
+	// greeting: version 5, one method, no authentication
+	if _, err := rw.Write([]byte{5, 1, 0}); err != nil {
+		return err
+	}
+
+	var reply [4]byte
+	if _, err := io.ReadFull(rw, reply[:2]); err != nil {
+		return err
+	} else if reply[0] != 5 || reply[1] != 0 {
+		return errors.New("gemproto: socks5: authentication required")
+	}
+
+	// connect request with a domain name or IP address
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		req = append(req, 3, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 1)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 4)
+		req = append(req, ip...)
+	}
+	req = binary.BigEndian.AppendUint16(req, port)
+
+	if _, err := rw.Write(req); err != nil {
+		return err
+	}
+
+	if _, err := io.ReadFull(rw, reply[:]); err != nil {
+		return err
+	} else if reply[0] != 5 {
+		return errors.New("gemproto: socks5: invalid reply")
+	} else if reply[1] != 0 {
+		return fmt.Errorf("gemproto: socks5: connect failed with code %d", reply[1])
+	}
+
+	// skip the bound address and port
+	var skip int
+	switch reply[3] {
+	case 1:
+		skip = 4 + 2
+	case 4:
+		skip = 16 + 2
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(rw, n[:]); err != nil {
+			return err
+		}
+		skip = int(n[0]) + 2
+	default:
+		return errors.New("gemproto: socks5: invalid address type")
+	}
+
+	_, err := io.CopyN(io.Discard, rw, int64(skip))
+	return err
+}
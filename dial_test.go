@@ -0,0 +1,97 @@
+package gemproto_test
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+// serveSOCKS5 accepts a single SOCKS5 connection, records the requested
+// host and forwards the connection to target.
+func serveSOCKS5(t *testing.T, l net.Listener, target string, requested chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 512)
+	_, _ = io.ReadFull(conn, buf[:3])
+	_, _ = conn.Write([]byte{5, 0})
+	_, _ = io.ReadFull(conn, buf[:5])
+	n := int(buf[4])
+	_, _ = io.ReadFull(conn, buf[:n+2])
+	requested <- string(buf[:n])
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer upstream.Close()
+
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func TestClientSOCKS5Dialer(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	requested := make(chan string, 1)
+	go serveSOCKS5(t, l, strings.TrimPrefix(server.URL, "gemini://"), requested)
+
+	client := gemproto.Client{
+		Dialers: map[string]gemproto.ContextDialer{
+			".onion": gemproto.SOCKS5Dialer(l.Addr().String()),
+		},
+	}
+
+	res, err := client.Get("gemini://example.onion/")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "example.onion", <-requested)
+	require.Equal(t, "example.onion", string(body))
+
+	// onion hosts are not resolved without a dialer
+	_, err = (&gemproto.Client{}).Get("gemini://example.onion/")
+	require.ErrorIs(t, err, gemproto.ErrNoOnionDialer)
+}
+
+func TestClientHostsDialer(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "gemini://"))
+	require.NoError(t, err)
+
+	client := gemproto.Client{
+		Dialers: map[string]gemproto.ContextDialer{
+			"capsule.test": gemproto.HostsDialer(map[string]string{"capsule.test": "127.0.0.1"}),
+		},
+	}
+
+	res, err := client.Get("gemini://capsule.test:" + port + "/")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, gemproto.StatusOK, res.StatusCode)
+}
@@ -0,0 +1,61 @@
+// Package expvarstats exports Server instrumentation with expvar:
+//
+//	srv := gemproto.Server{
+//	  Stats: expvarstats.New("gemini"),
+//	}
+//
+// It is a separate package because importing expvar registers
+// /debug/vars on http.DefaultServeMux and publishes the command line
+// and memory statistics of the process.
+package expvarstats
+
+import (
+	"expvar"
+	"strconv"
+	"time"
+)
+
+// Stats is a gemproto.StatsCollector that exports counters with expvar.
+//
+// The counters are published as a map with the following keys:
+//   - conns_accepted is the number of accepted connections.
+//   - handshake_failures is the number of failed TLS handshakes.
+//   - requests is the number of served requests.
+//   - requests_1x through requests_6x count the requests by status class.
+//   - latency_seconds is the total latency of all served requests,
+//     which divided by requests is the mean latency.
+type Stats struct {
+	m *expvar.Map
+}
+
+// New returns a new Stats that publishes its counters under name.
+// Like expvar.Publish, it panics if name is already registered.
+func New(name string) *Stats {
+	return &Stats{
+		m: expvar.NewMap(name),
+	}
+}
+
+// Map returns the published counters.
+func (s *Stats) Map() *expvar.Map {
+	return s.m
+}
+
+// ConnAccepted implements gemproto.StatsCollector.
+func (s *Stats) ConnAccepted() {
+	s.m.Add("conns_accepted", 1)
+}
+
+// HandshakeFailed implements gemproto.StatsCollector.
+func (s *Stats) HandshakeFailed(error) {
+	s.m.Add("handshake_failures", 1)
+}
+
+// RequestServed implements gemproto.StatsCollector.
+func (s *Stats) RequestServed(statusCode int, latency time.Duration) {
+	s.m.Add("requests", 1)
+	if class := statusCode / 10; class >= 1 && class <= 6 {
+		s.m.Add("requests_"+strconv.Itoa(class)+"x", 1)
+	}
+	s.m.AddFloat("latency_seconds", latency.Seconds())
+}
@@ -0,0 +1,82 @@
+package expvarstats_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/expvarstats"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		Duration: 1 * time.Hour,
+		DNSNames: []string{"localhost"},
+	})
+	require.NoError(t, err)
+
+	stats := expvarstats.New("gemproto_test_stats")
+
+	mux := gemproto.NewServeMux()
+	mux.HandleFunc("/index.gmi", func(w gemproto.ResponseWriter, r *gemproto.Request) {})
+
+	s := gemproto.Server{
+		Handler: mux,
+		Stats:   stats,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	client := gemproto.Client{}
+	for _, path := range []string{"/index.gmi", "/missing", "/index.gmi"} {
+		res, err := client.Get("gemini://" + l.Addr().String() + path)
+		require.NoError(t, err)
+		_, _ = io.ReadAll(res.Body)
+		res.Body.Close()
+	}
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn.Write([]byte("/////////////////////////\r\n"))
+	require.NoError(t, err)
+	_, _ = io.ReadAll(conn)
+	conn.Close()
+
+	get := func(key string) string {
+		if v := stats.Map().Get(key); v != nil {
+			return v.String()
+		}
+		return ""
+	}
+
+	// the handshake failure is counted after the connection is closed
+	for deadline := time.Now().Add(3 * time.Second); get("handshake_failures") == "" && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	require.Equal(t, "4", get("conns_accepted"))
+	require.Equal(t, "1", get("handshake_failures"))
+	require.Equal(t, "3", get("requests"))
+	require.Equal(t, "2", get("requests_2x"))
+	require.Equal(t, "1", get("requests_5x"))
+	require.True(t, get("latency_seconds") != "")
+}
@@ -9,7 +9,7 @@ package gemproto
 
 import (
 	"bufio"
-	"embed"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -21,6 +21,8 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/askeladdk/gemproto/gemtext"
 )
@@ -60,11 +62,62 @@ const (
 
 	// UseMetaFile enables the .meta file to be scanned.
 	UseMetaFile
+
+	// ShowErrors includes the file system error in the meta
+	// of 51 Not Found responses instead of a generic message.
+	// The error text is sanitized but may reveal the file system layout.
+	ShowErrors
+
+	// ShowModTime includes the modification time of files in directory listings.
+	ShowModTime
+
+	// HideScheduled hides files that are scheduled for publication.
+	HideScheduled
 )
 
+// DefaultDenyPatterns are the patterns of files that FileServer
+// never serves or lists unless overridden by FileServerOptions.Deny.
+// They cover private keys, backup and swap files, and version control
+// and environment files that are commonly found in document roots.
+var DefaultDenyPatterns = []string{
+	"*.key",
+	"*.bak",
+	"*.swp",
+	"*~",
+	".env",
+	".git/",
+	".hg/",
+	".svn/",
+}
+
+// FileServerOptions configures FileServerWithOptions.
+type FileServerOptions struct {
+	// Flags enables capabilities of the file server.
+	Flags FileServerFlags
+
+	// Deny lists file patterns that are neither served nor listed.
+	// Patterns use the syntax of path.Match and are matched against
+	// every element of the requested path, so that a denied directory
+	// also denies its contents. Patterns ending in a slash only match
+	// directories. Matching is case-insensitive, because case-insensitive
+	// file systems serve secret.KEY as well as secret.key.
+	// Denied files are answered with 51 NOT FOUND.
+	// Defaults to DefaultDenyPatterns if nil.
+	// Set it to an empty slice to deny nothing.
+	Deny []string
+
+	// Navigation is optional and generates a navigation section that is
+	// prepended to index pages and directory listings, giving static trees
+	// consistent site chrome. It is executed with a Navigation value.
+	// See DefaultNavigation for an example.
+	Navigation *template.Template
+}
+
 type fileServer struct {
 	Root  fs.FS
 	Flags FileServerFlags
+	Deny  []string
+	Nav   *template.Template
 }
 
 // FileServer returns a handler that serves Gemini requests
@@ -87,6 +140,24 @@ type fileServer struct {
 //
 // ShowHiddenFiles enables hidden files and directories to be accessed.
 //
+// ShowErrors enables file system errors to be reported in the response meta.
+// It is disabled by default to avoid leaking information about the file system.
+//
+// ShowModTime includes the modification date of entries in directory listings.
+// Entries without a modification time are listed without a date.
+//
+// HideScheduled hides files and directories until they are published,
+// so that gemlog posts can be scheduled without cron scripts.
+// Files are scheduled if their name starts with a date in the future,
+// such as 2030-01-02-post.gmi, which is published at the start of that day
+// in the local time zone, or if their modification time is in the future.
+//
+// Directory listings are sorted by name regardless of the order in which
+// the file system returns its entries, so the output is deterministic.
+// Directories are read from the opened file if it implements fs.ReadDirFile
+// or Readdir, and through fs.ReadDir otherwise, so that file systems
+// that only implement fs.ReadDirFS can be listed.
+//
 // UseMetaFile enables parsing the .meta file to customize the metadata
 // of any files accessed in the same directory as the .meta file.
 //
@@ -99,29 +170,110 @@ type fileServer struct {
 // or a valid Gemini response line.
 // Mimetypes starting with ';' are appended.
 // Response lines have the form <2digitcode><space><metadata>.
+//
+// Files matching DefaultDenyPatterns are never served or listed.
+// Use FileServerWithOptions to configure the patterns.
 func FileServer(root fs.FS, flags FileServerFlags) Handler {
+	return FileServerWithOptions(root, FileServerOptions{Flags: flags})
+}
+
+// FileServerWithOptions is like FileServer but accepts additional options.
+func FileServerWithOptions(root fs.FS, opts FileServerOptions) Handler {
+	patterns := opts.Deny
+	if patterns == nil {
+		patterns = DefaultDenyPatterns
+	}
+
+	// copy the patterns so that later changes to them have no effect
+	deny := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		deny[i] = strings.ToLower(pattern)
+	}
+
 	return fileServer{
 		Root:  root,
-		Flags: flags,
+		Flags: opts.Flags,
+		Deny:  deny,
+		Nav:   opts.Navigation,
+	}
+}
+
+// denied reports whether any element of the rooted path name
+// matches a deny pattern, ignoring case. The last element is a directory if isDir is set.
+func (fsrv fileServer) denied(name string, isDir bool) bool {
+	elems := strings.Split(strings.ToLower(strings.Trim(name, "/")), "/")
+	for i, elem := range elems {
+		elemIsDir := isDir || i < len(elems)-1
+		for _, pattern := range fsrv.Deny {
+			dirOnly := strings.HasSuffix(pattern, "/")
+			if dirOnly && !elemIsDir {
+				continue
+			}
+			if ok, _ := path.Match(strings.TrimSuffix(pattern, "/"), elem); ok {
+				return true
+			}
+		}
 	}
+	return false
+}
+
+// scheduled reports whether any element of name starts with a date
+// that is later than now, or whether modtime is later than now.
+func scheduled(name string, modtime, now time.Time) bool {
+	if modtime.After(now) {
+		return true
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		const layout = "2006-01-02"
+		if len(elem) < len(layout) || len(elem) > len(layout) && '0' <= elem[len(layout)] && elem[len(layout)] <= '9' {
+			continue
+		}
+		if date, err := time.ParseInLocation(layout, elem[:len(layout)], time.Local); err == nil && date.After(now) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (fsrv fileServer) ServeGemini(w ResponseWriter, r *Request) {
 	upath := r.URL.Path
-	// embed.FS does not work with leading /
-	if _, isembed := fsrv.Root.(embed.FS); isembed {
-		upath = strings.TrimPrefix(upath, "/")
-	} else if !strings.HasPrefix(upath, "/") {
+	if !strings.HasPrefix(upath, "/") {
 		upath = "/" + upath
 		r.URL.Path = upath
 	}
-	fsrv.serveFile(w, r, fsrv.Root, path.Clean(upath), true)
+	fsrv.serveFile(w, r, validPathFS{fsrv.Root}, path.Clean(upath), true)
+}
+
+// validPathFS adapts the rooted paths used by fileServer to
+// the unrooted paths accepted by fs.FS implementations.
+// It works for any fs.FS, including embed.FS and wrappers such as fs.Sub,
+// which reject names that are not valid according to fs.ValidPath.
+type validPathFS struct {
+	fsys fs.FS
+}
+
+// validPath converts a cleaned rooted path to a valid fs.FS path.
+func validPath(name string) string {
+	if name = strings.TrimPrefix(name, "/"); name == "" {
+		return "."
+	}
+	return name
+}
+
+func (v validPathFS) Open(name string) (fs.File, error) {
+	return v.fsys.Open(validPath(name))
+}
+
+func (v validPathFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(v.fsys, validPath(name))
 }
 
 func (fsrv fileServer) readMetadata(name string) string {
 	base := path.Base(name)
-	metafilepath := filepath.Join(path.Dir(name), ".meta")
-	f, err := fsrv.Root.Open(metafilepath)
+	metafilepath := path.Join(path.Dir(name), ".meta")
+	f, err := validPathFS{fsrv.Root}.Open(metafilepath)
 	if err != nil {
 		return ""
 	}
@@ -173,18 +325,19 @@ func (fsrv fileServer) serveFile(w ResponseWriter, r *Request, fsys fs.FS, name
 
 	f, err := fsys.Open(name)
 	if err != nil {
-		w.WriteHeader(StatusNotFound, err.Error())
+		fsrv.notFound(w, err)
 		return
 	}
 	defer f.Close()
 
 	fi, err := f.Stat()
 	if err != nil {
-		w.WriteHeader(StatusNotFound, err.Error())
+		fsrv.notFound(w, err)
 		return
 	}
 
-	if fsrv.Flags&ShowHiddenFiles == 0 && strings.Contains(name, "/.") {
+	if fsrv.Flags&ShowHiddenFiles == 0 && strings.Contains(name, "/.") || fsrv.denied(name, fi.IsDir()) ||
+		fsrv.Flags&HideScheduled != 0 && scheduled(name, fi.ModTime(), time.Now()) {
 		w.WriteHeader(StatusNotFound, "Not Found")
 		return
 	}
@@ -211,7 +364,18 @@ func (fsrv fileServer) serveFile(w ResponseWriter, r *Request, fsys fs.FS, name
 		index := strings.TrimSuffix(name, "/") + indexPage
 		if ff, err := fsys.Open(index); err == nil {
 			defer ff.Close()
-			serveContent(w, ff, index, "")
+
+			var content io.Reader = ff
+			if fsrv.Nav != nil {
+				nav, err := fsrv.navigation(fsys, name)
+				if err != nil {
+					w.WriteHeader(StatusTemporaryFailure, "Error generating navigation")
+					return
+				}
+				content = io.MultiReader(bytes.NewReader(nav), ff)
+			}
+
+			serveContent(w, content, index, "")
 			return
 		}
 
@@ -220,28 +384,38 @@ func (fsrv fileServer) serveFile(w ResponseWriter, r *Request, fsys fs.FS, name
 			return
 		}
 
-		fsrv.serveDir(w, f, name)
+		fsrv.serveDir(w, fsys, f, name)
 		return
 	}
 
 	serveContent(w, f, name, metadata)
 }
 
+func (fsrv fileServer) notFound(w ResponseWriter, err error) {
+	if fsrv.Flags&ShowErrors != 0 {
+		w.WriteHeader(StatusNotFound, SanitizeMeta(err.Error()))
+		return
+	}
+	w.WriteHeader(StatusNotFound, "Not Found")
+}
+
 type anyDirs interface {
 	sort.Interface
 	Name(i int) string
 	IsDir(i int) bool
 	Size(i int) int64
+	ModTime(i int) time.Time
 }
 
 type fileInfoDirs []fs.FileInfo
 
-func (d fileInfoDirs) Size(i int) int64   { return d[i].Size() }
-func (d fileInfoDirs) IsDir(i int) bool   { return d[i].IsDir() }
-func (d fileInfoDirs) Name(i int) string  { return d[i].Name() }
-func (d fileInfoDirs) Len() int           { return len(d) }
-func (d fileInfoDirs) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
-func (d fileInfoDirs) Less(i, j int) bool { return d[i].Name() < d[j].Name() }
+func (d fileInfoDirs) ModTime(i int) time.Time { return d[i].ModTime() }
+func (d fileInfoDirs) Size(i int) int64        { return d[i].Size() }
+func (d fileInfoDirs) IsDir(i int) bool        { return d[i].IsDir() }
+func (d fileInfoDirs) Name(i int) string       { return d[i].Name() }
+func (d fileInfoDirs) Len() int                { return len(d) }
+func (d fileInfoDirs) Swap(i, j int)           { d[i], d[j] = d[j], d[i] }
+func (d fileInfoDirs) Less(i, j int) bool      { return d[i].Name() < d[j].Name() }
 
 type dirEntryDirs []fs.DirEntry
 
@@ -253,6 +427,14 @@ func (d dirEntryDirs) Size(i int) int64 {
 	return fi.Size()
 }
 
+func (d dirEntryDirs) ModTime(i int) time.Time {
+	fi, err := d[i].Info()
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
 func (d dirEntryDirs) IsDir(i int) bool   { return d[i].IsDir() }
 func (d dirEntryDirs) Name(i int) string  { return d[i].Name() }
 func (d dirEntryDirs) Len() int           { return len(d) }
@@ -276,7 +458,7 @@ type readdirFS interface {
 	Readdir(count int) ([]fs.FileInfo, error)
 }
 
-func (fsrv fileServer) serveDir(w ResponseWriter, f fs.File, name string) {
+func (fsrv fileServer) serveDir(w ResponseWriter, fsys fs.FS, f fs.File, name string) {
 	var entries anyDirs
 	var err error
 
@@ -288,6 +470,10 @@ func (fsrv fileServer) serveDir(w ResponseWriter, f fs.File, name string) {
 		var fileinfoentries fileInfoDirs
 		fileinfoentries, err = rdf.Readdir(-1)
 		entries = fileinfoentries
+	} else {
+		var direntries dirEntryDirs
+		direntries, err = fs.ReadDir(fsys, name)
+		entries = direntries
 	}
 
 	if err != nil {
@@ -295,7 +481,18 @@ func (fsrv fileServer) serveDir(w ResponseWriter, f fs.File, name string) {
 		return
 	}
 
-	b := gemtext.NewBuilder(make([]byte, 0, 1024))
+	buf := make([]byte, 0, 1024)
+
+	if fsrv.Nav != nil {
+		nav, err := fsrv.navigation(fsys, name)
+		if err != nil {
+			w.WriteHeader(StatusTemporaryFailure, "Error generating navigation")
+			return
+		}
+		buf = append(buf, nav...)
+	}
+
+	b := gemtext.NewBuilder(buf)
 
 	if name == "/" {
 		b.Heading(name)
@@ -305,11 +502,16 @@ func (fsrv fileServer) serveDir(w ResponseWriter, f fs.File, name string) {
 
 	if entries != nil {
 		sort.Sort(entries)
+		now := time.Now()
 
 		for i := 0; i < entries.Len(); i++ {
 			filepath := entries.Name(i)
 			if fsrv.Flags&ShowHiddenFiles == 0 && strings.HasPrefix(filepath, ".") {
 				continue
+			} else if fsrv.denied(path.Join(name, filepath), entries.IsDir(i)) {
+				continue
+			} else if fsrv.Flags&HideScheduled != 0 && scheduled(filepath, entries.ModTime(i), now) {
+				continue
 			}
 
 			if entries.IsDir(i) {
@@ -318,6 +520,9 @@ func (fsrv fileServer) serveDir(w ResponseWriter, f fs.File, name string) {
 
 			fz, ft := formatFileSize(entries.Size(i))
 			label := fmt.Sprintf("%s (%d%s)", filepath, fz, ft)
+			if modtime := entries.ModTime(i); fsrv.Flags&ShowModTime != 0 && !modtime.IsZero() {
+				label = fmt.Sprintf("%s (%d%s, %s)", filepath, fz, ft, modtime.UTC().Format("2006-01-02"))
+			}
 			b.Link(filepath, label)
 		}
 	}
@@ -325,7 +530,7 @@ func (fsrv fileServer) serveDir(w ResponseWriter, f fs.File, name string) {
 	_, _ = w.Write(b.Bytes())
 }
 
-func serveContent(w ResponseWriter, f fs.File, name, mimetype string) {
+func serveContent(w ResponseWriter, f io.Reader, name, mimetype string) {
 	var toappend string
 	if strings.HasPrefix(mimetype, ";") {
 		toappend, mimetype = mimetype, ""
@@ -2,8 +2,13 @@ package gemproto_test
 
 import (
 	"embed"
+	"io/fs"
+	"os"
+	"sort"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/askeladdk/gemproto"
 	"github.com/askeladdk/gemproto/gemtest"
@@ -29,6 +34,12 @@ func TestFileServerNotFound(t *testing.T) {
 	r := gemtest.NewRequest("/blablabla.example")
 	h.ServeGemini(w, r)
 	require.Equal(t, gemproto.StatusNotFound, w.Code)
+	require.Equal(t, "Not Found", w.Meta)
+
+	h = gemproto.FileServer(gemproto.Dir("."), gemproto.ShowErrors)
+	w = gemtest.NewRecorder()
+	h.ServeGemini(w, r)
+	require.Equal(t, gemproto.StatusNotFound, w.Code)
 	require.Equal(t, "open blablabla.example: no such file or directory", w.Meta)
 }
 
@@ -90,3 +101,195 @@ func TestFileServerMeta2(t *testing.T) {
 	require.Equal(t, gemproto.StatusOK, r.StatusCode)
 	require.Equal(t, "this file does not exist", r.Meta)
 }
+
+// unorderedFS is a virtual file system whose files cannot list themselves
+// and whose directory entries are returned in reverse order.
+type unorderedFS struct {
+	fstest.MapFS
+}
+
+func (fsys unorderedFS) Open(name string) (fs.File, error) {
+	f, err := fsys.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ fs.File }{f}, nil
+}
+
+func (fsys unorderedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fsys.MapFS.ReadDir(name)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() > entries[j].Name() })
+	return entries, err
+}
+
+func TestFileServerListDirsModTime(t *testing.T) {
+	t.Parallel()
+
+	modtime := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	fsys := unorderedFS{fstest.MapFS{
+		"a.gmi":     {Data: []byte("a"), ModTime: modtime},
+		"b.gmi":     {Data: []byte("bb"), ModTime: modtime},
+		"c/d.gmi":   {Data: []byte("d")},
+		".hidden":   {},
+		"c/.hidden": {},
+	}}
+
+	h := gemproto.FileServer(fsys, gemproto.ListDirs|gemproto.ShowModTime)
+	w := gemtest.NewRecorder()
+	r := gemtest.NewRequest("/")
+	h.ServeGemini(w, r)
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "# /\n"+
+		"=> a.gmi a.gmi (1B, 2022-10-01)\n"+
+		"=> b.gmi b.gmi (2B, 2022-10-01)\n"+
+		"=> c/ c/ (0B)\n", w.Body.String())
+}
+
+func TestFileServerFileSystems(t *testing.T) {
+	t.Parallel()
+
+	sub, err := fs.Sub(testfiles, "testfiles")
+	require.NoError(t, err)
+
+	hello, err := fs.ReadFile(sub, "hello.gmi")
+	require.NoError(t, err)
+
+	for name, fsys := range map[string]fs.FS{
+		"embed":  sub,
+		"dirfs":  os.DirFS("testfiles"),
+		"dir":    gemproto.Dir("testfiles"),
+		"mapfs":  fstest.MapFS{"hello.gmi": {Data: hello}},
+		"nested": mustSub(t, fstest.MapFS{"a/b/hello.gmi": {Data: hello}}, "a/b"),
+	} {
+		h := gemproto.FileServer(fsys, gemproto.ListDirs)
+
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, gemtest.NewRequest("/hello.gmi"))
+		require.Equal(t, gemproto.StatusOK, w.Code, name)
+		require.Equal(t, string(hello), w.Body.String(), name)
+
+		w = gemtest.NewRecorder()
+		h.ServeGemini(w, gemtest.NewRequest("/"))
+		require.Equal(t, gemproto.StatusOK, w.Code, name)
+		require.True(t, strings.Contains(w.Body.String(), "=> hello.gmi "), name)
+	}
+}
+
+func mustSub(t *testing.T, fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	require.NoError(t, err)
+	return sub
+}
+
+func TestFileServerDeny(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"index.txt":     {Data: []byte("index")},
+		"server.key":    {Data: []byte("secret")},
+		"SERVER.KEY":    {Data: []byte("secret")},
+		"notes.gmi.bak": {Data: []byte("backup")},
+		".git/config":   {Data: []byte("config")},
+		"drafts/a.gmi":  {Data: []byte("draft")},
+	}
+
+	serve := func(h gemproto.Handler, path string) *gemtest.ResponseRecorder {
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, gemtest.NewRequest(path))
+		return w
+	}
+
+	h := gemproto.FileServer(fsys, gemproto.ListDirs|gemproto.ShowHiddenFiles)
+	require.Equal(t, gemproto.StatusOK, serve(h, "/index.txt").Code)
+	require.Equal(t, gemproto.StatusNotFound, serve(h, "/server.key").Code)
+	require.Equal(t, gemproto.StatusNotFound, serve(h, "/SERVER.KEY").Code)
+	require.Equal(t, gemproto.StatusNotFound, serve(h, "/notes.gmi.bak").Code)
+	require.Equal(t, gemproto.StatusNotFound, serve(h, "/.git/config").Code)
+	require.Equal(t, "# /\n"+
+		"=> drafts/ drafts/ (0B)\n"+
+		"=> index.txt index.txt (5B)\n", serve(h, "/").Body.String())
+
+	deny := []string{"Drafts/"}
+	h = gemproto.FileServerWithOptions(fsys, gemproto.FileServerOptions{
+		Deny: deny,
+	})
+	deny[0] = "index.txt"
+	require.Equal(t, gemproto.StatusOK, serve(h, "/server.key").Code)
+	require.Equal(t, gemproto.StatusOK, serve(h, "/index.txt").Code)
+	require.Equal(t, gemproto.StatusNotFound, serve(h, "/drafts/a.gmi").Code)
+}
+
+func TestFileServerHideScheduled(t *testing.T) {
+	t.Parallel()
+
+	future := time.Now().Add(time.Hour)
+
+	fsys := fstest.MapFS{
+		"2000-01-01-past.gmi":     {Data: []byte("past")},
+		"2999-01-01-future.gmi":   {Data: []byte("future")},
+		"2999-01-01/post.gmi":     {Data: []byte("future")},
+		"20000-01-01-invalid.gmi": {Data: []byte("invalid")},
+		"touched.gmi":             {Data: []byte("touched"), ModTime: future},
+	}
+
+	serve := func(h gemproto.Handler, path string) *gemtest.ResponseRecorder {
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, gemtest.NewRequest(path))
+		return w
+	}
+
+	h := gemproto.FileServer(fsys, gemproto.ListDirs|gemproto.HideScheduled)
+	require.Equal(t, gemproto.StatusOK, serve(h, "/2000-01-01-past.gmi").Code)
+	require.Equal(t, gemproto.StatusOK, serve(h, "/20000-01-01-invalid.gmi").Code)
+	require.Equal(t, gemproto.StatusNotFound, serve(h, "/2999-01-01-future.gmi").Code)
+	require.Equal(t, gemproto.StatusNotFound, serve(h, "/2999-01-01/post.gmi").Code)
+	require.Equal(t, gemproto.StatusNotFound, serve(h, "/touched.gmi").Code)
+	require.Equal(t, "# /\n"+
+		"=> 2000-01-01-past.gmi 2000-01-01-past.gmi (4B)\n"+
+		"=> 20000-01-01-invalid.gmi 20000-01-01-invalid.gmi (7B)\n", serve(h, "/").Body.String())
+
+	h = gemproto.FileServer(fsys, 0)
+	require.Equal(t, gemproto.StatusOK, serve(h, "/2999-01-01-future.gmi").Code)
+}
+
+func TestFileServerNavigation(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"index.gmi":           {Data: []byte("# Home\n")},
+		"gemlog/index.gmi":    {Data: []byte("# Gemlog\n")},
+		"gemlog/2022/a.gmi":   {Data: []byte("a")},
+		"projects/readme.txt": {Data: []byte("readme")},
+		".private/x.gmi":      {Data: []byte("x")},
+	}
+
+	serve := func(h gemproto.Handler, path string) *gemtest.ResponseRecorder {
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, gemtest.NewRequest(path))
+		return w
+	}
+
+	h := gemproto.FileServerWithOptions(fsys, gemproto.FileServerOptions{
+		Flags:      gemproto.ListDirs,
+		Navigation: gemproto.DefaultNavigation,
+	})
+
+	require.Equal(t, "=> gemlog/ gemlog/\n"+
+		"=> projects/ projects/\n"+
+		"\n"+
+		"# Home\n", serve(h, "/").Body.String())
+
+	require.Equal(t, "=> ../ ..\n"+
+		"=> ../projects/ projects/\n"+
+		"=> 2022/ 2022/\n"+
+		"\n"+
+		"# Gemlog\n", serve(h, "/gemlog/").Body.String())
+
+	require.Equal(t, "=> ../ ..\n"+
+		"=> ../gemlog/ gemlog/\n"+
+		"\n"+
+		"# /projects/\n"+
+		"=> readme.txt readme.txt (6B)\n", serve(h, "/projects/").Body.String())
+
+	require.Equal(t, "a", serve(h, "/gemlog/2022/a.gmi").Body.String())
+}
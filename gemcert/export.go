@@ -0,0 +1,756 @@
+package gemcert
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+// EncodeDER returns the DER encoding of the certificate
+// and the PKCS #8 DER encoding of its private key.
+func EncodeDER(cert tls.Certificate) (certDER, keyDER []byte, err error) {
+	if len(cert.Certificate) == 0 {
+		return nil, nil, errors.New("gemcert: no certificate")
+	}
+
+	keyDER, err = x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert.Certificate[0], keyDER, nil
+}
+
+// ParseDER parses a DER encoded certificate and
+// a PKCS #8 DER encoded private key into a key pair.
+// Certificate.Leaf will contain the parsed form of the certificate.
+func ParseDER(certDER, keyDER []byte) (tls.Certificate, error) {
+	leaf, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	priv, err := x509.ParsePKCS8PrivateKey(keyDER)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{certDER},
+		PrivateKey:  priv,
+		Leaf:        leaf,
+	}, nil
+}
+
+// SSHFingerprint returns the OpenSSH style fingerprint of the
+// certificate's public key, as printed by ssh-keygen -l.
+// Ed25519, ECDSA and RSA keys are supported.
+func SSHFingerprint(cert *x509.Certificate) (string, error) {
+	wire, err := sshPublicKey(cert.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(wire)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(h[:]), nil
+}
+
+// sshPublicKey returns the public key in the SSH wire format.
+func sshPublicKey(pub any) ([]byte, error) {
+	var b []byte
+
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		b = appendSSHString(b, []byte("ssh-ed25519"))
+		b = appendSSHString(b, pub)
+	case *ecdsa.PublicKey:
+		var curve string
+		switch pub.Curve {
+		case elliptic.P256():
+			curve = "nistp256"
+		case elliptic.P384():
+			curve = "nistp384"
+		case elliptic.P521():
+			curve = "nistp521"
+		default:
+			return nil, errors.New("gemcert: unsupported elliptic curve")
+		}
+		b = appendSSHString(b, []byte("ecdsa-sha2-"+curve))
+		b = appendSSHString(b, []byte(curve))
+		b = appendSSHString(b, elliptic.Marshal(pub.Curve, pub.X, pub.Y))
+	case *rsa.PublicKey:
+		b = appendSSHString(b, []byte("ssh-rsa"))
+		b = appendSSHMPInt(b, big.NewInt(int64(pub.E)))
+		b = appendSSHMPInt(b, pub.N)
+	default:
+		return nil, fmt.Errorf("gemcert: unsupported public key type %T", pub)
+	}
+
+	return b, nil
+}
+
+func appendSSHString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func appendSSHMPInt(b []byte, n *big.Int) []byte {
+	p := n.Bytes()
+	if len(p) > 0 && p[0]&0x80 != 0 {
+		p = append([]byte{0}, p...)
+	}
+	return appendSSHString(b, p)
+}
+
+var (
+	oidData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidX509Certificate     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1        = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA1                = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	asn1NULL               = asn1.RawValue{Tag: asn1.TagNull}
+	errPKCS12NoCertificate = errors.New("gemcert: no certificate")
+)
+
+// ErrPKCS12Password is returned by DecodePKCS12 if the password is incorrect.
+var ErrPKCS12Password = errors.New("gemcert: incorrect PKCS #12 password")
+
+const (
+	pkcs12Iterations = 2048
+	pkcs12SaltLen    = 16
+)
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID     asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12CertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pkcs12DigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12MacData struct {
+	Mac        pkcs12DigestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  pkcs12MacData
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo struct {
+		ContentType                asn1.ObjectIdentifier
+		ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+		EncryptedContent           []byte `asn1:"tag:0,optional"`
+	}
+}
+
+// EncodePKCS12 encodes the certificate, its private key and any
+// intermediate certificates into a password protected PKCS #12 bundle
+// that can be imported into other Gemini clients and browsers.
+//
+// The private key is encrypted with PBES2 using AES-256-CBC and PBKDF2
+// with HMAC-SHA-256. The bundle is authenticated with HMAC-SHA-256.
+// The certificates are not encrypted.
+func EncodePKCS12(cert tls.Certificate, password string) ([]byte, error) {
+	if len(cert.Certificate) == 0 {
+		return nil, errPKCS12NoCertificate
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// the local key id links the key to the leaf certificate
+	localKeyID := sha256.Sum256(cert.Certificate[0])
+	localKeyIDValue, err := asn1.Marshal(localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	keyIDAttrs := []pkcs12Attribute{{
+		ID:     oidLocalKeyID,
+		Values: []asn1.RawValue{{FullBytes: localKeyIDValue}},
+	}}
+
+	var bags []pkcs12SafeBag
+
+	for i, der := range cert.Certificate {
+		certBag, err := asn1.Marshal(pkcs12CertBag{
+			ID:   oidX509Certificate,
+			Data: der,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		bag := pkcs12SafeBag{
+			ID:    oidCertBag,
+			Value: asn1.RawValue{FullBytes: explicitTag0(certBag)},
+		}
+
+		if i == 0 {
+			bag.Attributes = keyIDAttrs
+		}
+
+		bags = append(bags, bag)
+	}
+
+	shroudedKey, err := encryptPBES2(keyDER, password)
+	if err != nil {
+		return nil, err
+	}
+
+	bags = append(bags, pkcs12SafeBag{
+		ID:         oidShroudedKeyBag,
+		Value:      asn1.RawValue{FullBytes: explicitTag0(shroudedKey)},
+		Attributes: keyIDAttrs,
+	})
+
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return nil, err
+	}
+
+	dataContentInfo, err := marshalDataContentInfo(safeContents)
+	if err != nil {
+		return nil, err
+	}
+
+	authSafe, err := asn1.Marshal([]asn1.RawValue{{FullBytes: dataContentInfo}})
+	if err != nil {
+		return nil, err
+	}
+
+	macSalt := make([]byte, pkcs12SaltLen)
+	if _, err := rand.Read(macSalt); err != nil {
+		return nil, err
+	}
+
+	macKey := pkcs12KDF(sha256.New, 64, bmpString(password), macSalt, 3, pkcs12Iterations, 32)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(authSafe)
+
+	authSafeOctets, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs12PFX{
+		Version: 3,
+		AuthSafe: pkcs12ContentInfo{
+			ContentType: oidData,
+			Content:     asn1.RawValue{FullBytes: explicitTag0(authSafeOctets)},
+		},
+		MacData: pkcs12MacData{
+			Mac: pkcs12DigestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1NULL},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    macSalt,
+			Iterations: pkcs12Iterations,
+		},
+	})
+}
+
+func marshalDataContentInfo(data []byte) ([]byte, error) {
+	octets, err := asn1.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs12ContentInfo{
+		ContentType: oidData,
+		Content:     asn1.RawValue{FullBytes: explicitTag0(octets)},
+	})
+}
+
+// explicitTag0 wraps the DER encoded value in an explicit [0] tag.
+func explicitTag0(der []byte) []byte {
+	b, _ := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      der,
+	})
+	return b
+}
+
+func encryptPBES2(plaintext []byte, password string) ([]byte, error) {
+	salt := make([]byte, pkcs12SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2(sha256.New, []byte(password), salt, pkcs12Iterations, 32)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS #7 padding
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	ciphertext := make([]byte, len(plaintext)+padding)
+	copy(ciphertext, plaintext)
+	for i := len(plaintext); i < len(ciphertext); i++ {
+		ciphertext[i] = byte(padding)
+	}
+
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pkcs12Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1NULL},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBKDF2,
+			Parameters: asn1.RawValue{FullBytes: kdfParams},
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  oidAES256CBC,
+			Parameters: asn1.RawValue{FullBytes: ivParams},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBES2,
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		EncryptedData: ciphertext,
+	})
+}
+
+// DecodePKCS12 decodes a password protected PKCS #12 bundle as exported
+// by EncodePKCS12, OpenSSL and other Gemini clients and browsers.
+// The certificate that matches the private key is the leaf and
+// any other certificates are returned as its chain.
+// Certificate.Leaf will contain the parsed form of the leaf.
+//
+// Bags encrypted with PBES2 using AES-CBC and PBKDF2 with HMAC-SHA-1 or
+// HMAC-SHA-256 are supported, as well as MACs using SHA-1 and SHA-256.
+// Legacy algorithms such as RC2 and 3DES are not.
+func DecodePKCS12(data []byte, password string) (tls.Certificate, error) {
+	var pfx pkcs12PFX
+	if err := unmarshalDER(data, &pfx); err != nil {
+		return tls.Certificate{}, err
+	} else if pfx.Version != 3 {
+		return tls.Certificate{}, fmt.Errorf("gemcert: unsupported PKCS #12 version %d", pfx.Version)
+	}
+
+	authSafe, err := unmarshalDataContentInfo(pfx.AuthSafe)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if err := verifyPKCS12MAC(pfx.MacData, authSafe, password); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	var contentInfos []pkcs12ContentInfo
+	if err := unmarshalDER(authSafe, &contentInfos); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	var certs []*x509.Certificate
+	var priv any
+
+	for _, ci := range contentInfos {
+		var safeContents []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			safeContents, err = unmarshalDataContentInfo(ci)
+		case ci.ContentType.Equal(oidEncryptedData):
+			var ed pkcs12EncryptedData
+			if err = unmarshalDER(ci.Content.Bytes, &ed); err == nil {
+				eci := ed.EncryptedContentInfo
+				safeContents, err = decryptPBES2(eci.ContentEncryptionAlgorithm, eci.EncryptedContent, password)
+			}
+		default:
+			err = fmt.Errorf("gemcert: unsupported PKCS #12 content type %s", ci.ContentType)
+		}
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+
+		var bags []pkcs12SafeBag
+		if err := unmarshalDER(safeContents, &bags); err != nil {
+			return tls.Certificate{}, err
+		}
+
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb pkcs12CertBag
+				if err := unmarshalDER(bag.Value.Bytes, &cb); err != nil {
+					return tls.Certificate{}, err
+				} else if !cb.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return tls.Certificate{}, err
+				}
+				certs = append(certs, cert)
+			case bag.ID.Equal(oidKeyBag):
+				if priv, err = x509.ParsePKCS8PrivateKey(bag.Value.Bytes); err != nil {
+					return tls.Certificate{}, err
+				}
+			case bag.ID.Equal(oidShroudedKeyBag):
+				var epki encryptedPrivateKeyInfo
+				if err := unmarshalDER(bag.Value.Bytes, &epki); err != nil {
+					return tls.Certificate{}, err
+				}
+				keyDER, err := decryptPBES2(epki.Algorithm, epki.EncryptedData, password)
+				if err != nil {
+					return tls.Certificate{}, err
+				}
+				if priv, err = x509.ParsePKCS8PrivateKey(keyDER); err != nil {
+					return tls.Certificate{}, err
+				}
+			}
+		}
+	}
+
+	if priv == nil {
+		return tls.Certificate{}, errors.New("gemcert: no private key")
+	}
+
+	pub, ok := priv.(interface{ Public() crypto.PublicKey })
+	if !ok {
+		return tls.Certificate{}, fmt.Errorf("gemcert: unsupported private key type %T", priv)
+	}
+
+	// the leaf is the certificate of the private key
+	cert := tls.Certificate{PrivateKey: priv}
+	for i, c := range certs {
+		if k, ok := c.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && k.Equal(pub.Public()) {
+			cert.Leaf = c
+			certs = append(certs[:i:i], certs[i+1:]...)
+			break
+		}
+	}
+
+	if cert.Leaf == nil {
+		return tls.Certificate{}, errPKCS12NoCertificate
+	}
+
+	cert.Certificate = append(cert.Certificate, cert.Leaf.Raw)
+	for _, c := range certs {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+
+	return cert, nil
+}
+
+// unmarshalDER unmarshals der into v and rejects trailing data.
+func unmarshalDER(der []byte, v any) error {
+	rest, err := asn1.Unmarshal(der, v)
+	if err != nil {
+		return err
+	} else if len(rest) != 0 {
+		return errors.New("gemcert: trailing data after ASN.1 value")
+	}
+	return nil
+}
+
+func unmarshalDataContentInfo(ci pkcs12ContentInfo) ([]byte, error) {
+	if !ci.ContentType.Equal(oidData) {
+		return nil, fmt.Errorf("gemcert: unsupported PKCS #12 content type %s", ci.ContentType)
+	}
+
+	var data []byte
+	if err := unmarshalDER(ci.Content.Bytes, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func verifyPKCS12MAC(md pkcs12MacData, authSafe []byte, password string) error {
+	var h func() hash.Hash
+	switch alg := md.Mac.Algorithm.Algorithm; {
+	case alg.Equal(oidSHA1):
+		h = sha1.New
+	case alg.Equal(oidSHA256):
+		h = sha256.New
+	default:
+		return fmt.Errorf("gemcert: unsupported PKCS #12 MAC algorithm %s", alg)
+	}
+
+	iterations := md.Iterations
+	if iterations == 0 {
+		iterations = 1
+	}
+
+	macKey := pkcs12KDF(h, 64, bmpString(password), md.MacSalt, 3, iterations, h().Size())
+	mac := hmac.New(h, macKey)
+	mac.Write(authSafe)
+
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return ErrPKCS12Password
+	}
+	return nil
+}
+
+func decryptPBES2(alg pkix.AlgorithmIdentifier, ciphertext []byte, password string) ([]byte, error) {
+	if !alg.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("gemcert: unsupported PKCS #12 encryption algorithm %s", alg.Algorithm)
+	}
+
+	var params pbes2Params
+	if err := unmarshalDER(alg.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	} else if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("gemcert: unsupported key derivation function %s", params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdfParams pbkdf2Params
+	if err := unmarshalDER(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, err
+	}
+
+	var h func() hash.Hash
+	switch prf := kdfParams.PRF.Algorithm; {
+	case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+		h = sha1.New
+	case prf.Equal(oidHMACWithSHA256):
+		h = sha256.New
+	default:
+		return nil, fmt.Errorf("gemcert: unsupported pseudorandom function %s", prf)
+	}
+
+	var keyLen int
+	switch enc := params.EncryptionScheme.Algorithm; {
+	case enc.Equal(oidAES128CBC):
+		keyLen = 16
+	case enc.Equal(oidAES192CBC):
+		keyLen = 24
+	case enc.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("gemcert: unsupported encryption scheme %s", enc)
+	}
+
+	var iv []byte
+	if err := unmarshalDER(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	} else if len(iv) != aes.BlockSize {
+		return nil, errors.New("gemcert: invalid AES-CBC IV")
+	}
+
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("gemcert: invalid ciphertext length")
+	}
+
+	key := pbkdf2(h, []byte(password), kdfParams.Salt, kdfParams.Iterations, keyLen)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// PKCS #7 padding
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrPKCS12Password
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if subtle.ConstantTimeByteEq(b, byte(padding)) == 0 {
+			return nil, ErrPKCS12Password
+		}
+	}
+
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// pbkdf2 implements the PBKDF2 key derivation function of RFC 8018.
+func pbkdf2(h func() hash.Hash, password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
+
+// pkcs12KDF implements the PKCS #12 key derivation function of RFC 7292 appendix B.
+func pkcs12KDF(h func() hash.Hash, v int, password, salt []byte, id byte, iter, size int) []byte {
+	fill := func(p []byte) []byte {
+		if len(p) == 0 {
+			return nil
+		}
+		n := v * ((len(p) + v - 1) / v)
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = p[i%len(p)]
+		}
+		return out
+	}
+
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+
+	in := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		hh := h()
+		hh.Write(d)
+		hh.Write(in)
+		a := hh.Sum(nil)
+		for i := 1; i < iter; i++ {
+			hh.Reset()
+			hh.Write(a)
+			a = hh.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// B = A repeated to v bytes, plus one
+		b := new(big.Int).SetBytes(fill(a)[:v])
+		b.Add(b, big.NewInt(1))
+
+		// add B to each v-byte block of I modulo 2^(8v)
+		for j := 0; j < len(in); j += v {
+			ij := new(big.Int).SetBytes(in[j : j+v])
+			ij.Add(ij, b)
+			ijb := ij.Bytes()
+			if len(ijb) > v {
+				ijb = ijb[len(ijb)-v:]
+			}
+			block := in[j : j+v]
+			for k := range block {
+				block[k] = 0
+			}
+			copy(block[v-len(ijb):], ijb)
+		}
+	}
+
+	return out[:size]
+}
+
+// bmpString encodes s as a null-terminated big-endian UTF-16 string.
+func bmpString(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 0, 2*len(u)+2)
+	for _, r := range u {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return append(b, 0, 0)
+}
@@ -34,13 +34,19 @@ import (
 	"crypto/x509/pkix"
 	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"io"
+	"io/fs"
 	"math/big"
 	"net"
 	"os"
 	"time"
 )
 
+// ErrNoSubjectAltName is returned when creating a certificate that requires
+// at least one Subject Alternative Name but has none.
+var ErrNoSubjectAltName = errors.New("gemcert: certificate has no subject alternative names")
+
 // CreateOptions configures the creation of a TLS certificate
 // generated with the Ed25519 signature algorithm.
 type CreateOptions struct {
@@ -67,9 +73,73 @@ type CreateOptions struct {
 	// Parent is the optional certificate to sign with.
 	// If nil, the certificate will be self-signed.
 	Parent *x509.Certificate
+
+	// ParentKey is the private key of Parent.
+	// It is required if Parent is set.
+	ParentKey crypto.PrivateKey
+
+	// IsCA marks the certificate as a certificate authority
+	// that can sign other certificates.
+	IsCA bool
+
+	// ListenAddr is the optional host:port address that the server
+	// listens on. Its host is added to IPAddresses if it is an IP address
+	// or to DNSNames otherwise. If the host is empty or unspecified
+	// (such as 0.0.0.0 or ::), the addresses of all network interfaces
+	// are added to IPAddresses.
+	ListenAddr string
+
+	// RequireSAN makes certificate creation fail with ErrNoSubjectAltName
+	// if the certificate has no DNSNames and no IPAddresses.
+	// Server certificates should always have a Subject Alternative Name
+	// because clients increasingly reject certificates with only a Common Name.
+	RequireSAN bool
+}
+
+// listenAddrSANs returns the Subject Alternative Names for a listen address.
+func listenAddrSANs(addr string) (dnsNames []string, ips []net.IP, err error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	if host != "" {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return []string{host}, nil, nil
+		} else if !ip.IsUnspecified() {
+			return nil, []net.IP{ip}, nil
+		}
+	}
+
+	ifaddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, ifaddr := range ifaddrs {
+		if ipnet, ok := ifaddr.(*net.IPNet); ok {
+			ips = append(ips, ipnet.IP)
+		}
+	}
+
+	return nil, ips, nil
 }
 
 func newX509KeyPair(options CreateOptions) (*x509.Certificate, crypto.PrivateKey, error) {
+	if options.ListenAddr != "" {
+		dnsNames, ips, err := listenAddrSANs(options.ListenAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		options.DNSNames = append(options.DNSNames[:len(options.DNSNames):len(options.DNSNames)], dnsNames...)
+		options.IPAddresses = append(options.IPAddresses[:len(options.IPAddresses):len(options.IPAddresses)], ips...)
+	}
+
+	if options.RequireSAN && len(options.DNSNames) == 0 && len(options.IPAddresses) == 0 {
+		return nil, nil, ErrNoSubjectAltName
+	}
+
 	randr := rand.Reader
 	if options.Rand != nil {
 		randr = options.Rand
@@ -99,17 +169,24 @@ func newX509KeyPair(options CreateOptions) (*x509.Certificate, crypto.PrivateKey
 		KeyUsage:              x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
+		IsCA:                  options.IsCA,
 		IPAddresses:           options.IPAddresses,
 		DNSNames:              options.DNSNames,
 		Subject:               options.Subject,
 	}
 
-	parent := options.Parent
+	if options.IsCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	parent, signer := options.Parent, options.ParentKey
 	if parent == nil {
-		parent = &template
+		parent, signer = &template, priv
+	} else if signer == nil {
+		return nil, nil, errors.New("gemcert: parent has no private key")
 	}
 
-	crt, err := x509.CreateCertificate(randr, &template, parent, pub, priv)
+	crt, err := x509.CreateCertificate(randr, &template, parent, pub, signer)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -123,6 +200,7 @@ func newX509KeyPair(options CreateOptions) (*x509.Certificate, crypto.PrivateKey
 }
 
 // CreateX509KeyPair creates a new TLS certificate.
+// If options.Parent is set, it is appended to the certificate chain.
 func CreateX509KeyPair(options CreateOptions) (tls.Certificate, error) {
 	crt, priv, err := newX509KeyPair(options)
 	if err != nil {
@@ -131,12 +209,49 @@ func CreateX509KeyPair(options CreateOptions) (tls.Certificate, error) {
 	var cert tls.Certificate
 	cert.Leaf = crt
 	cert.Certificate = append(cert.Certificate, crt.Raw)
+	if options.Parent != nil {
+		cert.Certificate = append(cert.Certificate, options.Parent.Raw)
+	}
+	cert.PrivateKey = priv
+	return cert, nil
+}
+
+// SignCertificate creates a new TLS certificate signed by parent.
+// The certificate chain consists of the new certificate followed by
+// the chain of parent, so that servers present the complete chain
+// to clients that validate certificates against a CA.
+func SignCertificate(options CreateOptions, parent tls.Certificate) (tls.Certificate, error) {
+	if parent.Leaf == nil {
+		if len(parent.Certificate) == 0 {
+			return tls.Certificate{}, errors.New("gemcert: parent has no certificate")
+		}
+
+		leaf, err := x509.ParseCertificate(parent.Certificate[0])
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		parent.Leaf = leaf
+	}
+
+	options.Parent = parent.Leaf
+	options.ParentKey = parent.PrivateKey
+
+	crt, priv, err := newX509KeyPair(options)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	var cert tls.Certificate
+	cert.Leaf = crt
+	cert.Certificate = append(cert.Certificate, crt.Raw)
+	cert.Certificate = append(cert.Certificate, parent.Certificate...)
 	cert.PrivateKey = priv
 	return cert, nil
 }
 
 // StoreX509KeyPair stores the public and private keys of
 // the provided certificate in their respective files.
+// The certificate file contains the complete certificate chain.
 func StoreX509KeyPair(cert tls.Certificate, certFile, keyFile string) error {
 	certOut, err := os.OpenFile(certFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
@@ -150,11 +265,13 @@ func StoreX509KeyPair(cert tls.Certificate, certFile, keyFile string) error {
 	}
 	defer keyOut.Close()
 
-	if err := pem.Encode(certOut, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: cert.Leaf.Raw,
-	}); err != nil {
-		return err
+	for _, der := range cert.Certificate {
+		if err := pem.Encode(certOut, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: der,
+		}); err != nil {
+			return err
+		}
 	}
 
 	privBytes, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
@@ -183,6 +300,62 @@ func LoadX509KeyPair(certFile, keyFile string) (cert tls.Certificate, err error)
 	return cert, err
 }
 
+// LoadOrCreateX509KeyPair loads the key pair from the pair of files
+// like LoadX509KeyPair. If neither file exists, it creates a self-signed
+// certificate with the options and stores it in the files first,
+// which allows quick deployments to start without generating
+// a certificate by hand. It fails if only one of the files exists,
+// so that an existing key is never overwritten.
+func LoadOrCreateX509KeyPair(certFile, keyFile string, options CreateOptions) (tls.Certificate, error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+
+	if !errors.Is(certErr, fs.ErrNotExist) || !errors.Is(keyErr, fs.ErrNotExist) {
+		return LoadX509KeyPair(certFile, keyFile)
+	}
+
+	cert, err := CreateX509KeyPair(options)
+	if err != nil {
+		return cert, err
+	}
+
+	if err := StoreX509KeyPair(cert, certFile, keyFile); err != nil {
+		return cert, err
+	}
+
+	return cert, nil
+}
+
+// LoadX509KeyPairWithChain reads and parses a public/private key pair
+// from a pair of files and appends the intermediate certificates
+// read from chainFile to the certificate chain.
+// The files must be PEM encoded.
+// Certificate.Leaf will contain the parsed form of the certificate.
+func LoadX509KeyPairWithChain(certFile, keyFile, chainFile string) (cert tls.Certificate, err error) {
+	if cert, err = LoadX509KeyPair(certFile, keyFile); err != nil {
+		return cert, err
+	}
+
+	chain, err := os.ReadFile(chainFile)
+	if err != nil {
+		return cert, err
+	}
+
+	for {
+		var block *pem.Block
+		if block, chain = pem.Decode(chain); block == nil {
+			break
+		} else if block.Type == "CERTIFICATE" {
+			if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+				return cert, err
+			}
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+
+	return cert, nil
+}
+
 // Fingerprint returns the hexadecimal encoding of the sha256 hash
 // of the given certificate's Subject Public Key Info (SPKI) section.
 func Fingerprint(cert *x509.Certificate) string {
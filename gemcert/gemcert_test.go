@@ -0,0 +1,207 @@
+package gemcert_test
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestDER(t *testing.T) {
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+	require.NoError(t, err)
+
+	certDER, keyDER, err := gemcert.EncodeDER(cert)
+	require.NoError(t, err)
+
+	cert2, err := gemcert.ParseDER(certDER, keyDER)
+	require.NoError(t, err)
+	require.Equal(t, cert.Leaf.Raw, cert2.Leaf.Raw)
+	require.Equal(t, cert.PrivateKey, cert2.PrivateKey)
+}
+
+func TestSSHFingerprint(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	cert := x509.Certificate{PublicKey: priv.Public()}
+
+	fp, err := gemcert.SSHFingerprint(&cert)
+	require.NoError(t, err)
+	require.Equal(t, "SHA256:tAXFyTXI8xtDaujAEcwJslAYc9/6FKcUkd2Lw0xDhPo", fp)
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	ca, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		Duration: time.Hour,
+		Subject:  pkix.Name{CommonName: "ca"},
+		IsCA:     true,
+	})
+	require.NoError(t, err)
+
+	cert, err := gemcert.SignCertificate(gemcert.CreateOptions{
+		Duration: time.Hour,
+		Subject:  pkix.Name{CommonName: "localhost"},
+	}, ca)
+	require.NoError(t, err)
+
+	p12, err := gemcert.EncodePKCS12(cert, "secret")
+	require.NoError(t, err)
+
+	decoded, err := gemcert.DecodePKCS12(p12, "secret")
+	require.NoError(t, err)
+	require.Equal(t, cert.Certificate, decoded.Certificate)
+	require.Equal(t, cert.PrivateKey, decoded.PrivateKey)
+
+	_, err = gemcert.DecodePKCS12(p12, "wrong")
+	require.ErrorIs(t, err, gemcert.ErrPKCS12Password)
+
+	// decode the bundle with an independent implementation
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+
+	p12File := filepath.Join(t.TempDir(), "cert.p12")
+	require.NoError(t, os.WriteFile(p12File, p12, 0600))
+
+	out, err := exec.Command(openssl, "pkcs12", "-in", p12File, "-passin", "pass:secret", "-nodes").Output()
+	require.NoError(t, err)
+
+	var certs [][]byte
+	var keyDER []byte
+	for block, rest := pem.Decode(out); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			certs = append(certs, block.Bytes)
+		case "PRIVATE KEY":
+			keyDER = block.Bytes
+		}
+	}
+
+	require.Equal(t, cert.Certificate, certs)
+	priv, err := x509.ParsePKCS8PrivateKey(keyDER)
+	require.NoError(t, err)
+	require.Equal(t, cert.PrivateKey, priv)
+}
+
+func TestDecodePKCS12(t *testing.T) {
+	// generated by OpenSSL 3.0 from testdata/cert.pem and testdata/key.pem:
+	//   openssl pkcs12 -export -passout pass:secret -out openssl.p12
+	//   openssl pkcs12 -export -passout pass:secret -out openssl-sha1.p12 \
+	//     -keypbe AES-128-CBC -certpbe NONE -macalg sha1
+	cert, err := tls.LoadX509KeyPair("testdata/cert.pem", "testdata/key.pem")
+	require.NoError(t, err)
+
+	for _, name := range []string{"openssl.p12", "openssl-sha1.p12"} {
+		p12, err := os.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+
+		decoded, err := gemcert.DecodePKCS12(p12, "secret")
+		require.NoError(t, err, name)
+		require.Equal(t, cert.Certificate, decoded.Certificate, name)
+		require.Equal(t, cert.PrivateKey, decoded.PrivateKey, name)
+		require.Equal(t, "gemcert", decoded.Leaf.Subject.CommonName, name)
+
+		_, err = gemcert.DecodePKCS12(p12, "wrong")
+		require.ErrorIs(t, err, gemcert.ErrPKCS12Password)
+	}
+}
+
+func TestSignCertificate(t *testing.T) {
+	ca, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		Duration: time.Hour,
+		Subject:  pkix.Name{CommonName: "ca"},
+		IsCA:     true,
+	})
+	require.NoError(t, err)
+
+	cert, err := gemcert.SignCertificate(gemcert.CreateOptions{
+		Duration: time.Hour,
+		DNSNames: []string{"localhost"},
+		Subject:  pkix.Name{CommonName: "localhost"},
+	}, ca)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(cert.Certificate))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{
+		DNSName: "localhost",
+		Roots:   roots,
+	})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "leaf.crt")
+	keyFile := filepath.Join(dir, "leaf.key")
+	chainFile := filepath.Join(dir, "chain.crt")
+
+	leafOnly := cert
+	leafOnly.Certificate = cert.Certificate[:1]
+	require.NoError(t, gemcert.StoreX509KeyPair(leafOnly, certFile, keyFile))
+	require.NoError(t, os.WriteFile(chainFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ca.Leaf.Raw,
+	}), 0600))
+
+	loaded, err := gemcert.LoadX509KeyPairWithChain(certFile, keyFile, chainFile)
+	require.NoError(t, err)
+	require.Equal(t, cert.Certificate, loaded.Certificate)
+
+	_, err = gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		Duration: time.Hour,
+		DNSNames: []string{"localhost"},
+		Parent:   ca.Leaf,
+	})
+	require.True(t, err != nil, "parent without private key")
+}
+
+func TestCreateListenAddr(t *testing.T) {
+	_, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{RequireSAN: true})
+	require.ErrorIs(t, err, gemcert.ErrNoSubjectAltName)
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		ListenAddr: "192.0.2.1:1965",
+		RequireSAN: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(cert.Leaf.IPAddresses))
+	require.NoError(t, cert.Leaf.VerifyHostname("192.0.2.1"))
+
+	cert, err = gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		ListenAddr: ":1965",
+	})
+	require.NoError(t, err)
+	require.True(t, len(cert.Leaf.IPAddresses) != 0)
+}
+
+func TestLoadOrCreateX509KeyPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+
+	options := gemcert.CreateOptions{
+		Duration: time.Hour,
+		DNSNames: []string{"localhost"},
+	}
+
+	created, err := gemcert.LoadOrCreateX509KeyPair(certFile, keyFile, options)
+	require.NoError(t, err)
+
+	loaded, err := gemcert.LoadOrCreateX509KeyPair(certFile, keyFile, options)
+	require.NoError(t, err)
+	require.Equal(t, gemcert.Fingerprint(created.Leaf), gemcert.Fingerprint(loaded.Leaf))
+
+	// an orphaned key is not overwritten
+	require.NoError(t, os.Remove(certFile))
+	_, err = gemcert.LoadOrCreateX509KeyPair(certFile, keyFile, options)
+	require.True(t, err != nil)
+}
@@ -0,0 +1,138 @@
+package gemcert
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate loaded from a pair of files
+// and reloads it when the files change, so that renewing
+// a certificate does not require restarting the server.
+//
+// CertReloader is intended to be used with a server
+// by setting the GetCertificate field of the TLS configuration:
+//
+//	reloader, err := gemcert.NewCertReloader("server.crt", "server.key")
+//	if err != nil {
+//	  // handle error
+//	}
+//	go reloader.Watch(ctx, time.Minute)
+//	srv := gemproto.Server{
+//	  TLSConfig: &tls.Config{
+//	    GetCertificate: reloader.GetCertificate,
+//	  },
+//	}
+//
+// CertReloader is safe to use concurrently.
+type CertReloader struct {
+	// OnError is optional and called by Watch when reloading fails.
+	// The previous certificate continues to be served.
+	OnError func(err error)
+
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	certMod  time.Time
+	keyMod   time.Time
+	mu       sync.RWMutex
+}
+
+// NewCertReloader loads the certificate from the pair of files.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload loads the certificate from the files.
+// The current certificate is kept if loading fails.
+func (r *CertReloader) Reload() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.certMod, r.keyMod = certMod, keyMod
+	return nil
+}
+
+// Watch polls the modification times of the files at every interval
+// and reloads the certificate when either file changed.
+// It returns when ctx is done.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.reloadIfModified(); err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+	}
+}
+
+func (r *CertReloader) reloadIfModified() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	modified := !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+	r.mu.RUnlock()
+
+	if !modified {
+		return nil
+	}
+
+	return r.Reload()
+}
+
+func (r *CertReloader) modTimes() (certMod, keyMod time.Time, err error) {
+	fi, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	fi2, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return fi.ModTime(), fi2.ModTime(), nil
+}
+
+// Certificate returns the current certificate.
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// GetCertificate implements the tls.Config.GetCertificate callback.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
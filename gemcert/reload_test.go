@@ -0,0 +1,70 @@
+package gemcert_test
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+
+	store := func(name string, modTime time.Time) {
+		cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+			Subject:  pkix.Name{CommonName: name},
+			Duration: time.Hour,
+		})
+		require.NoError(t, err)
+		require.NoError(t, gemcert.StoreX509KeyPair(cert, certFile, keyFile))
+		require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+		require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	}
+
+	store("old", time.Now().Add(-time.Hour))
+
+	reloader, err := gemcert.NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "old", cert.Leaf.Subject.CommonName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	reloader.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	store("new", time.Now())
+
+	deadline := time.Now().Add(3 * time.Second)
+	for reloader.Certificate().Leaf.Subject.CommonName != "new" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, "new", reloader.Certificate().Leaf.Subject.CommonName)
+
+	// the current certificate is kept if the files are invalid
+	require.NoError(t, os.WriteFile(keyFile, []byte("invalid"), 0600))
+	select {
+	case err := <-errs:
+		require.True(t, err != nil)
+	case <-time.After(3 * time.Second):
+		t.Fatal("no reload error")
+	}
+	require.Equal(t, "new", reloader.Certificate().Leaf.Subject.CommonName)
+}
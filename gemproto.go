@@ -5,7 +5,10 @@ package gemproto
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/url"
 )
 
@@ -45,6 +48,8 @@ type Request struct {
 
 	// Host is the Server Name Indication (SNI) passed by the client.
 	// It is automatically set by Server when it receives a request.
+	// If the client sent no SNI, Server sets it to the host of the
+	// request URL or to Server.Hostname.
 	// It must be set manually to use SNI in Client requests,
 	// otherwise it defaults to URL.Host.
 	Host string
@@ -52,7 +57,9 @@ type Request struct {
 	// TLS holds the basic TLS connection details.
 	TLS *tls.ConnectionState
 
-	ctx context.Context
+	ctx   context.Context
+	hooks *responseHooks
+	probe bool
 }
 
 // NewRequestWithContext creates a new request with a context.
@@ -79,8 +86,24 @@ func NewRequest(rawURL string) (*Request, error) {
 }
 
 // Context returns the request context.
+// It returns the background context if the request has no context.
 func (r *Request) Context() context.Context {
-	return r.ctx
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns a shallow copy of r with its context changed to ctx.
+// The provided ctx must be non-nil.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("gemproto: nil context")
+	}
+	r2 := new(Request)
+	*r2 = *r
+	r2.ctx = ctx
+	return r2
 }
 
 // GetInput returns the unescaped query string.
@@ -107,8 +130,53 @@ type Response struct {
 
 	// Body is the request body.
 	// It is never nil and must be Closed.
+	// Closing the body closes the underlying connection.
+	//
+	// The body of a response received over the network also implements
+	// SetReadDeadline(time.Time) error to adjust the read deadline
+	// of the underlying connection.
 	Body io.ReadCloser
 
 	// TLS holds the basic TLS connection details.
 	TLS *tls.ConnectionState
+
+	// Encoding is the name of the compression that Client removed
+	// from the body if Client.Decoders is set. It is empty otherwise.
+	Encoding string
+
+	// RawMeta is the meta sent by the server if Client replaced Meta
+	// with the mimetype of the decompressed body.
+	RawMeta string
+
+	// OnProgress is optionally called after every read from Body
+	// that returned data. It can be set by the caller
+	// to render progress bars and estimate remaining time.
+	OnProgress func(Progress)
+
+	body *responseBody
+}
+
+// Progress returns the transfer progress of the body.
+// It is safe to call concurrently with reading the body.
+// It returns the zero Progress if the response has no body
+// or was not received over the network.
+func (r *Response) Progress() Progress {
+	if r.body == nil {
+		return Progress{}
+	}
+	return r.body.progress()
+}
+
+// DecodeJSON decodes the JSON encoded response body into v.
+// It returns an error if the response is not 20 application/json.
+func (r *Response) DecodeJSON(v any) error {
+	if r.StatusCode/10 != 2 {
+		return errors.New("gemproto: response status is not success")
+	}
+
+	if mediatype, _, err := mime.ParseMediaType(r.Meta); err != nil || mediatype != "application/json" {
+		return errors.New("gemproto: response mimetype is not application/json")
+	}
+
+	return json.NewDecoder(r.Body).Decode(v)
 }
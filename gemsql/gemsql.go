@@ -0,0 +1,142 @@
+// Package gemsql integrates database/sql with Gemini handlers.
+//
+// Queries should always be issued with the request context so that
+// they are cancelled when the client disconnects or the request times out.
+// The helpers in this package take care of that and respond with
+// 40 TEMPORARY FAILURE when a query is cancelled or fails:
+//
+//	mux.Handle("/guestbook", gemsql.Timeout(5*time.Second)(
+//	  gemsql.WithTx(db, nil, func(w gemproto.ResponseWriter, r *gemproto.Request, tx *sql.Tx) error {
+//	    rows, err := tx.QueryContext(r.Context(), "SELECT message FROM guestbook")
+//	    if err != nil {
+//	      return err
+//	    }
+//	    defer rows.Close()
+//	    // ...
+//	    return rows.Err()
+//	  })))
+package gemsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+)
+
+// TxFunc handles a request within a database transaction.
+type TxFunc func(w gemproto.ResponseWriter, r *gemproto.Request, tx *sql.Tx) error
+
+// Timeout returns a middleware that limits the duration of requests
+// by setting a deadline on the request context.
+// Queries issued with the request context are cancelled after d.
+func Timeout(d time.Duration) func(gemproto.Handler) gemproto.Handler {
+	return func(next gemproto.Handler) gemproto.Handler {
+		return gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeGemini(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// WithTx returns a Handler that calls fn within a transaction
+// that is bound to the request context.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+// Errors are reported to the client with Error.
+func WithTx(db *sql.DB, opts *sql.TxOptions, fn TxFunc) gemproto.Handler {
+	return gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		tx, err := db.BeginTx(r.Context(), opts)
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		if err := fn(w, r, tx); err != nil {
+			_ = tx.Rollback()
+			Error(w, r, err)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			Error(w, r, err)
+		}
+	})
+}
+
+// Error responds with 40 TEMPORARY FAILURE.
+// The meta reports a timeout if err was caused by the request context
+// being cancelled and a generic failure otherwise.
+// The error itself is never sent to the client.
+func Error(w gemproto.ResponseWriter, r *gemproto.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || r.Context().Err() != nil {
+		w.WriteHeader(gemproto.StatusTemporaryFailure, "Request timed out")
+		return
+	}
+	w.WriteHeader(gemproto.StatusTemporaryFailure, "Database error")
+}
+
+// ChangeStore is a gemproto.ChangeStore that persists the records
+// in the gemproto_changes table of a database, such as SQLite.
+// Queries use ? placeholders.
+type ChangeStore struct {
+	db *sql.DB
+}
+
+// NewChangeStore creates the gemproto_changes table if it does not exist
+// and returns a ChangeStore that uses it.
+func NewChangeStore(ctx context.Context, db *sql.DB) (*ChangeStore, error) {
+	const query = `CREATE TABLE IF NOT EXISTS gemproto_changes (
+	url TEXT PRIMARY KEY,
+	version INTEGER NOT NULL,
+	hash TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	checked TIMESTAMP NOT NULL
+)`
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return nil, err
+	}
+
+	return &ChangeStore{db: db}, nil
+}
+
+// LoadChange implements gemproto.ChangeStore.
+func (s *ChangeStore) LoadChange(url string) (gemproto.ChangeRecord, bool, error) {
+	rec := gemproto.ChangeRecord{URL: url}
+	err := s.db.QueryRow(
+		"SELECT version, hash, status, checked FROM gemproto_changes WHERE url = ?", url,
+	).Scan(&rec.Version, &rec.Hash, &rec.StatusCode, &rec.Checked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return gemproto.ChangeRecord{}, false, nil
+	} else if err != nil {
+		return gemproto.ChangeRecord{}, false, err
+	}
+	return rec, true, nil
+}
+
+// StoreChange implements gemproto.ChangeStore.
+// The record is replaced in a transaction.
+func (s *ChangeStore) StoreChange(rec gemproto.ChangeRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM gemproto_changes WHERE url = ?", rec.URL); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO gemproto_changes (url, version, hash, status, checked) VALUES (?, ?, ?, ?, ?)",
+		rec.URL, rec.Version, rec.Hash, rec.StatusCode, rec.Checked,
+	); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,174 @@
+package gemsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemsql"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+// mockDriver records the outcome of transactions.
+type mockDriver struct {
+	commits, rollbacks int
+}
+
+func (d *mockDriver) Open(string) (driver.Conn, error) { return &mockConn{d}, nil }
+
+type mockConn struct{ d *mockDriver }
+
+func (c *mockConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *mockConn) Close() error                        { return nil }
+func (c *mockConn) Begin() (driver.Tx, error)           { return &mockTx{c.d}, nil }
+
+type mockTx struct{ d *mockDriver }
+
+func (tx *mockTx) Commit() error   { tx.d.commits++; return nil }
+func (tx *mockTx) Rollback() error { tx.d.rollbacks++; return nil }
+
+var mock = &mockDriver{}
+
+func init() {
+	sql.Register("gemsql_mock", mock)
+}
+
+func TestWithTx(t *testing.T) {
+	d := mock
+	*d = mockDriver{}
+	db, err := sql.Open("gemsql_mock", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	h := gemsql.WithTx(db, nil, func(w gemproto.ResponseWriter, r *gemproto.Request, tx *sql.Tx) error {
+		if r.URL.Path == "/fail" {
+			return errors.New("fail")
+		}
+		fmt.Fprint(w, "ok")
+		return nil
+	})
+
+	w := gemtest.NewRecorder()
+	h.ServeGemini(w, gemtest.NewRequest("/ok"))
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, 1, d.commits)
+
+	w = gemtest.NewRecorder()
+	h.ServeGemini(w, gemtest.NewRequest("/fail"))
+	require.Equal(t, gemproto.StatusTemporaryFailure, w.Code)
+	require.Equal(t, "Database error", w.Meta)
+	require.Equal(t, 1, d.rollbacks)
+
+	// the transaction cannot begin with an expired context
+	h = gemsql.Timeout(time.Nanosecond)(h)
+	w = gemtest.NewRecorder()
+	h.ServeGemini(w, gemtest.NewRequest("/ok"))
+	require.Equal(t, gemproto.StatusTemporaryFailure, w.Code)
+	require.Equal(t, "Request timed out", w.Meta)
+}
+
+// changesDriver is an in-memory gemproto_changes table
+// that understands the queries issued by ChangeStore.
+type changesDriver struct {
+	rows map[string][]driver.Value
+}
+
+func (d *changesDriver) Open(string) (driver.Conn, error) { return &changesConn{d}, nil }
+
+type changesConn struct{ d *changesDriver }
+
+func (c *changesConn) Prepare(query string) (driver.Stmt, error) {
+	return &changesStmt{c.d, query}, nil
+}
+func (c *changesConn) Close() error              { return nil }
+func (c *changesConn) Begin() (driver.Tx, error) { return changesTx{}, nil }
+
+type changesTx struct{}
+
+func (changesTx) Commit() error   { return nil }
+func (changesTx) Rollback() error { return nil }
+
+type changesStmt struct {
+	d     *changesDriver
+	query string
+}
+
+func (s *changesStmt) Close() error  { return nil }
+func (s *changesStmt) NumInput() int { return -1 }
+
+func (s *changesStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, "CREATE"):
+	case strings.HasPrefix(s.query, "DELETE"):
+		delete(s.d.rows, args[0].(string))
+	case strings.HasPrefix(s.query, "INSERT"):
+		s.d.rows[args[0].(string)] = args[1:]
+	default:
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *changesStmt) Query(args []driver.Value) (driver.Rows, error) {
+	row, ok := s.d.rows[args[0].(string)]
+	return &changesRows{row: row, done: !ok}, nil
+}
+
+type changesRows struct {
+	row  []driver.Value
+	done bool
+}
+
+func (r *changesRows) Columns() []string {
+	return []string{"version", "hash", "status", "checked"}
+}
+func (r *changesRows) Close() error { return nil }
+
+func (r *changesRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.row)
+	return nil
+}
+
+func init() {
+	sql.Register("gemsql_changes", &changesDriver{rows: map[string][]driver.Value{}})
+}
+
+func TestChangeStore(t *testing.T) {
+	db, err := sql.Open("gemsql_changes", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err := gemsql.NewChangeStore(context.Background(), db)
+	require.NoError(t, err)
+
+	_, found, err := store.LoadChange("gemini://example.com/")
+	require.NoError(t, err)
+	require.True(t, !found)
+
+	checked := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	rec := gemproto.ChangeRecord{
+		URL:        "gemini://example.com/",
+		Version:    3,
+		Hash:       "abc",
+		StatusCode: gemproto.StatusOK,
+		Checked:    checked,
+	}
+	require.NoError(t, store.StoreChange(rec))
+
+	loaded, found, err := store.LoadChange("gemini://example.com/")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, rec, loaded)
+}
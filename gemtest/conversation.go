@@ -0,0 +1,174 @@
+package gemtest
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemurl"
+)
+
+// Step is a request and its expected response in a Conversation.
+type Step struct {
+	// Request is the URL of the request, resolved against Conversation.URL.
+	// It defaults to the URL of the previous step without its query,
+	// so that a step answering an INPUT prompt only needs to set Input.
+	Request string
+
+	// Input is optional and sent as the escaped query of the request.
+	Input string
+
+	// Status is the expected status code.
+	Status int
+
+	// Meta is the expected meta. It is not checked if empty.
+	Meta string
+
+	// Body is the expected body. It is not checked if empty.
+	Body string
+}
+
+// Conversation is a scripted sequence of requests and expected responses,
+// which makes integration tests of multi-step flows such as INPUT prompts concise:
+//
+//	gemtest.Conversation{
+//	  Handler: h,
+//	  Steps: []gemtest.Step{
+//	    {Request: "/search", Status: gemproto.StatusInput, Meta: "Query"},
+//	    {Input: "gemini", Status: gemproto.StatusOK, Body: "1 result\n"},
+//	  },
+//	}.Run(t)
+type Conversation struct {
+	// Handler serves the requests in-process if it is not nil.
+	// Otherwise the requests are sent over the network to URL.
+	Handler gemproto.Handler
+
+	// URL is the base URL of the requests, such as Server.URL.
+	// It defaults to gemini://localhost/.
+	URL string
+
+	// Client sends the requests over the network.
+	// It defaults to a zero Client.
+	Client *gemproto.Client
+
+	// Steps are the requests and expected responses in order.
+	Steps []Step
+}
+
+// Run performs the steps in order and reports every difference between
+// the actual and expected response of a step as a test error.
+// It stops at the first failing step because later steps usually depend on it.
+func (c Conversation) Run(tb testing.TB) {
+	tb.Helper()
+
+	base, err := url.Parse(c.URL)
+	if c.URL == "" {
+		base, err = url.Parse("gemini://localhost/")
+	}
+	if err != nil {
+		tb.Fatalf("gemtest: invalid base url: %s", err)
+		return
+	}
+
+	prev := base
+
+	for i, step := range c.Steps {
+		u := prev
+		if step.Request != "" {
+			ref, err := url.Parse(step.Request)
+			if err != nil {
+				tb.Fatalf("gemtest: step %d: invalid url: %s", i+1, err)
+				return
+			}
+			u = base.ResolveReference(ref)
+		}
+
+		u2 := *u
+		if step.Request == "" {
+			u2.RawQuery = ""
+		}
+		if step.Input != "" {
+			u2.RawQuery = gemurl.QueryEscape(step.Input)
+		}
+		prev = &u2
+
+		code, meta, body, err := c.do(u2.String())
+		if err != nil {
+			tb.Errorf("gemtest: step %d: %s: %s", i+1, u2.String(), err)
+			return
+		}
+
+		if msg := diffResponse(step, code, meta, body); msg != "" {
+			tb.Errorf("gemtest: step %d: %s:\n%s", i+1, u2.String(), msg)
+			return
+		}
+	}
+}
+
+// do sends a request and returns the response.
+func (c Conversation) do(rawURL string) (code int, meta, body string, err error) {
+	if c.Handler != nil {
+		w := NewRecorder()
+		c.Handler.ServeGemini(w, NewRequest(rawURL))
+		return w.Code, w.Meta, w.Body.String(), nil
+	}
+
+	client := c.Client
+	if client == nil {
+		client = &gemproto.Client{}
+	}
+
+	res, err := client.Get(rawURL)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	return res.StatusCode, res.Meta, string(b), err
+}
+
+// diffResponse describes the differences between the
+// expected and actual response, or returns the empty string.
+func diffResponse(step Step, code int, meta, body string) string {
+	var sb strings.Builder
+
+	if code != step.Status {
+		fmt.Fprintf(&sb, "  status: got %d, want %d\n", code, step.Status)
+	}
+
+	if step.Meta != "" && meta != step.Meta {
+		fmt.Fprintf(&sb, "  meta: got %q, want %q\n", meta, step.Meta)
+	}
+
+	if step.Body != "" && body != step.Body {
+		sb.WriteString("  body (-want +got):\n")
+		writeLineDiff(&sb, step.Body, body)
+	}
+
+	return sb.String()
+}
+
+// writeLineDiff writes the lines of want and got side by side,
+// marking lines that differ with - and +.
+func writeLineDiff(sb *strings.Builder, want, got string) {
+	wl := strings.Split(want, "\n")
+	gl := strings.Split(got, "\n")
+
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		switch {
+		case i >= len(gl):
+			fmt.Fprintf(sb, "    - %s\n", wl[i])
+		case i >= len(wl):
+			fmt.Fprintf(sb, "    + %s\n", gl[i])
+		case wl[i] == gl[i]:
+			fmt.Fprintf(sb, "      %s\n", wl[i])
+		default:
+			fmt.Fprintf(sb, "    - %s\n", wl[i])
+			fmt.Fprintf(sb, "    + %s\n", gl[i])
+		}
+	}
+}
@@ -79,9 +79,10 @@ func NewServer(h gemproto.Handler) *Server {
 }
 
 type ResponseRecorder struct {
-	Body bytes.Buffer
-	Code int
-	Meta string
+	Body    bytes.Buffer
+	Code    int
+	Meta    string
+	Flushed bool
 
 	wroteHeader bool
 }
@@ -94,8 +95,8 @@ func NewRecorder() *ResponseRecorder {
 }
 
 func (r *ResponseRecorder) WriteHeader(statusCode int, meta string) {
+	// like the Server, the header can be changed until the first Write
 	if !r.wroteHeader {
-		r.wroteHeader = true
 		r.Code = statusCode
 		r.Meta = meta
 	}
@@ -106,6 +107,28 @@ func (r *ResponseRecorder) Write(p []byte) (int, error) {
 	return r.Body.Write(p)
 }
 
+// Status implements gemproto.ResponseStats.
+func (r *ResponseRecorder) Status() (int, string) {
+	return r.Code, r.Meta
+}
+
+// BytesWritten implements gemproto.ResponseStats.
+func (r *ResponseRecorder) BytesWritten() int64 {
+	return int64(r.Body.Len())
+}
+
+// Flush implements gemproto.Flusher.
+func (r *ResponseRecorder) Flush() {
+	r.wroteHeader = true
+	r.Flushed = true
+}
+
+// WriteString implements io.StringWriter.
+func (r *ResponseRecorder) WriteString(s string) (int, error) {
+	r.wroteHeader = true
+	return r.Body.WriteString(s)
+}
+
 func NewRequest(rawURL string) *gemproto.Request {
 	req, err := gemproto.NewRequest(rawURL)
 	if err != nil {
@@ -1,6 +1,8 @@
 package gemtest_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/askeladdk/gemproto"
@@ -36,3 +38,104 @@ func TestResponseRecorder(t *testing.T) {
 	require.Equal(t, "text/plain", w.Meta)
 	require.Equal(t, "hello world", w.Body.String())
 }
+
+type leakTB struct {
+	testing.TB
+	cleanup func()
+	errors  int
+}
+
+func (tb *leakTB) Helper()                           {}
+func (tb *leakTB) Cleanup(f func())                  { tb.cleanup = f }
+func (tb *leakTB) Errorf(format string, args ...any) { tb.errors++ }
+
+func TestVerifyNoLeaks(t *testing.T) {
+	gemtest.VerifyNoLeaks(t)
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+	defer server.Close()
+
+	client := gemproto.Client{}
+
+	// ensure the server is running
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	tb := leakTB{TB: t}
+	gemtest.VerifyNoLeaks(&tb)
+	res, err = client.Get(server.URL)
+	require.NoError(t, err)
+	tb.cleanup()
+	require.Equal(t, 1, tb.errors)
+
+	res.Body.Close()
+}
+
+type errorTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *errorTB) Helper() {}
+func (tb *errorTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func TestConversation(t *testing.T) {
+	t.Parallel()
+
+	h := gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		name, ok := r.GetInput()
+		if !ok {
+			w.WriteHeader(gemproto.StatusInput, "Name")
+			return
+		}
+		fmt.Fprintf(w, "Hello, %s!\n", name)
+	})
+
+	steps := []gemtest.Step{
+		{Request: "/greet", Status: gemproto.StatusInput, Meta: "Name"},
+		{Input: "Gemini user", Status: gemproto.StatusOK, Body: "Hello, Gemini user!\n"},
+	}
+
+	gemtest.Conversation{Handler: h, Steps: steps}.Run(t)
+
+	server := gemtest.NewServer(h)
+	defer server.Close()
+	gemtest.Conversation{URL: server.URL, Steps: steps}.Run(t)
+
+	tb := errorTB{TB: t}
+	gemtest.Conversation{
+		Handler: h,
+		Steps: []gemtest.Step{
+			{Request: "/greet?world", Status: gemproto.StatusOK, Body: "Hello, World!\n"},
+			{Request: "/never", Status: gemproto.StatusOK},
+		},
+	}.Run(&tb)
+
+	require.Equal(t, []string{"gemtest: step 1: gemini://localhost/greet?world:\n" +
+		"  body (-want +got):\n" +
+		"    - Hello, World!\n" +
+		"    + Hello, world!\n" +
+		"      \n"}, tb.errors)
+}
+
+func TestRequests(t *testing.T) {
+	t.Parallel()
+
+	reqs := gemtest.Requests("/blog/", "example.com/about")
+	require.Equal(t, 19, len(reqs))
+	require.Equal(t, "gemini://localhost/blog/", reqs[0].URL.String())
+	require.Equal(t, "localhost", reqs[0].Host)
+
+	mux := gemproto.NewServeMux()
+	mux.HandleFunc("/blog/", func(w gemproto.ResponseWriter, r *gemproto.Request) {})
+
+	dist := gemtest.Distribution(mux, reqs)
+	require.Equal(t, 6, len(dist[gemproto.StatusOK]))
+	require.Equal(t, 6, len(dist[gemproto.StatusPermanentRedirect]))
+	require.Equal(t, 7, len(dist[gemproto.StatusNotFound]))
+	require.Equal(t, "gemini://localhost/blog", dist[gemproto.StatusPermanentRedirect][0])
+	require.True(t, strings.HasPrefix(dist.String(), "20: 6\n  gemini://localhost/blog/\n"))
+}
@@ -0,0 +1,91 @@
+package gemtest
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto/internal/leakcheck"
+)
+
+// VerifyNoLeaks checks at the end of the test that all client response bodies
+// opened during the test have been closed and that no goroutines started by
+// gemproto during the test are still running.
+// It should be called at the start of the test.
+//
+// Leaks are detected by comparing global state,
+// so VerifyNoLeaks must not be used in parallel tests.
+func VerifyNoLeaks(tb testing.TB) {
+	tb.Helper()
+
+	bodies := leakcheck.OpenBodies()
+	before := goroutines()
+
+	tb.Cleanup(func() {
+		tb.Helper()
+
+		// give servers and connections some time to shut down
+		var leaked []string
+		deadline := time.Now().Add(time.Second)
+		for {
+			leaked = leakedGoroutines(before)
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if n := leakcheck.OpenBodies() - bodies; n > 0 {
+			tb.Errorf("gemtest: %d response bodies not closed", n)
+		}
+
+		for _, g := range leaked {
+			tb.Errorf("gemtest: leaked goroutine:\n%s", g)
+		}
+	})
+}
+
+// goroutines returns the stack traces of all goroutines keyed by goroutine id.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	gs := make(map[string]string)
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		stack := string(g)
+		if id, _, ok := strings.Cut(stack, " ["); ok {
+			gs[id] = stack
+		}
+	}
+	return gs
+}
+
+func leakedGoroutines(before map[string]string) []string {
+	const pkg = "github.com/askeladdk/gemproto."
+
+	var leaked []string
+	for id, stack := range goroutines() {
+		if _, exists := before[id]; exists {
+			continue
+		}
+
+		// ignore the goroutine running the cleanup
+		if strings.Contains(stack, "gemtest.VerifyNoLeaks") {
+			continue
+		}
+
+		if strings.Contains(stack, pkg) {
+			leaked = append(leaked, stack)
+		}
+	}
+	return leaked
+}
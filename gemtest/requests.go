@@ -0,0 +1,96 @@
+package gemtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/askeladdk/gemproto"
+)
+
+// foreignHost is the host of requests meant for another server.
+const foreignHost = "example.invalid"
+
+// Requests generates a matrix of edge-case requests for every pattern,
+// which helps handler authors discover routing surprises.
+// Patterns have the same form as ServeMux patterns, such as "/blog/"
+// or "example.com/blog/". Patterns without a host are requested at localhost.
+//
+// The variants of each pattern toggle the trailing slash, add
+// encoded characters, exceed any sensible path length, request
+// a foreign host and send an empty query. Duplicates are omitted.
+func Requests(patterns ...string) []*gemproto.Request {
+	var reqs []*gemproto.Request
+	seen := map[string]bool{}
+
+	add := func(rawURL string) {
+		if !seen[rawURL] {
+			seen[rawURL] = true
+			reqs = append(reqs, NewRequest(rawURL))
+		}
+	}
+
+	for _, pattern := range patterns {
+		host, path := "localhost", pattern
+		if i := strings.IndexByte(pattern, '/'); i > 0 {
+			host, path = pattern[:i], pattern[i:]
+		} else if i < 0 {
+			host, path = pattern, "/"
+		}
+
+		base := "gemini://" + host
+		trimmed := strings.TrimSuffix(path, "/")
+
+		add(base + path)
+		if strings.HasSuffix(path, "/") {
+			add(base + trimmed)
+			add(base + path + "/")
+		} else {
+			add(base + path + "/")
+		}
+
+		add(base + trimmed + "/a%20b")
+		add(base + trimmed + "/%2F")
+		add(base + trimmed + "/%2e%2e/")
+		add(base + trimmed + "/caf%C3%A9")
+		add(base + trimmed + "/" + strings.Repeat("a", 1000))
+		add("gemini://" + foreignHost + path)
+		add(base + path + "?")
+	}
+
+	return reqs
+}
+
+// StatusDistribution maps status codes to the URLs of
+// the requests that were answered with that status.
+type StatusDistribution map[int][]string
+
+// Distribution serves every request with h and collects the status codes.
+func Distribution(h gemproto.Handler, reqs []*gemproto.Request) StatusDistribution {
+	dist := StatusDistribution{}
+	for _, r := range reqs {
+		w := NewRecorder()
+		h.ServeGemini(w, r)
+		dist[w.Code] = append(dist[w.Code], r.URL.String())
+	}
+	return dist
+}
+
+// String formats the distribution as one line per status code
+// in ascending order, followed by the URLs indented.
+func (d StatusDistribution) String() string {
+	codes := make([]int, 0, len(d))
+	for code := range d {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	var sb strings.Builder
+	for _, code := range codes {
+		fmt.Fprintf(&sb, "%d: %d\n", code, len(d[code]))
+		for _, u := range d[code] {
+			fmt.Fprintf(&sb, "  %s\n", u)
+		}
+	}
+	return sb.String()
+}
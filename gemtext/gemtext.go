@@ -4,6 +4,7 @@ package gemtext
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
@@ -22,9 +23,22 @@ func init() {
 	_ = mime.AddExtensionType(".gemini", MIMEType)
 }
 
+// ErrNestedPreformat is reported by Builder.Err if a preformatted block
+// was opened inside another one or would be closed early by its text.
+var ErrNestedPreformat = errors.New("gemtext: nested preformatted block")
+
+// ErrUnclosedPreformat is reported by Builder.Err
+// if a preformatted block has not been closed.
+var ErrUnclosedPreformat = errors.New("gemtext: unclosed preformatted block")
+
 // Builder is used to efficiently build a gemtext file using the provided methods.
+//
+// Builder validates that preformatted blocks are balanced.
+// Call Err after building to check that the gemtext is well-formed.
 type Builder struct {
-	b *bytes.Buffer
+	b   *bytes.Buffer
+	pre bool
+	err error
 }
 
 // NewBuilder returns a new Builder.
@@ -47,6 +61,25 @@ func (b *Builder) String() string {
 // Reset resets the builder to empty but retains the underlying storage.
 func (b *Builder) Reset() {
 	b.b.Reset()
+	b.pre = false
+	b.err = nil
+}
+
+// Err returns the first validation error that occurred while building,
+// or ErrUnclosedPreformat if a preformatted block is still open.
+func (b *Builder) Err() error {
+	if b.err != nil {
+		return b.err
+	} else if b.pre {
+		return ErrUnclosedPreformat
+	}
+	return nil
+}
+
+func (b *Builder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
 }
 
 // WriteTo writes the accumulated gemtext to w.
@@ -75,15 +108,51 @@ func (b *Builder) Point(text string) {
 }
 
 // Pre toggles a preformatted block.
+// The alt text only applies to the line that opens the block.
+// Passing alt text when closing a block is reported as
+// ErrNestedPreformat, because gemtext blocks cannot be nested.
 func (b *Builder) Pre(alt string) {
+	if b.pre && alt != "" {
+		b.setErr(ErrNestedPreformat)
+	}
+	b.pre = !b.pre
 	fmt.Fprintf(b.b, "```%s\n", alt)
 }
 
+// Preformatted writes a complete preformatted block with the alt text.
+// Text may contain multiple lines delimited by newlines.
+// Lines of text that would close the block are reported as ErrNestedPreformat.
+func (b *Builder) Preformatted(alt, text string) {
+	if b.pre {
+		b.setErr(ErrNestedPreformat)
+	}
+	b.Pre(alt)
+	b.Paragraph(text)
+	b.Pre("")
+}
+
 // Paragraph writes a paragraph of plain text.
+// Inside a preformatted block, lines of text that would close
+// the block are reported as ErrNestedPreformat.
 func (b *Builder) Paragraph(text string) {
+	if b.pre && hasToggleLine(text) {
+		b.setErr(ErrNestedPreformat)
+	}
 	fmt.Fprintf(b.b, "%s\n", text)
 }
 
+// hasToggleLine reports whether any line of text is a preformat toggle line.
+func hasToggleLine(text string) bool {
+	for text != "" {
+		var line string
+		line, text, _ = strings.Cut(text, "\n")
+		if strings.HasPrefix(line, "```") {
+			return true
+		}
+	}
+	return false
+}
+
 // Newline writes a newline.
 func (b *Builder) Newline() {
 	b.b.WriteByte('\n')
@@ -33,6 +33,21 @@ func TestBuilder(t *testing.T) {
 	b.Paragraph(`print("hello world")`)
 	b.Pre("")
 	require.Equal(t, b.String(), "```code sample\nprint(\"hello world\")\n```\n")
+	require.NoError(t, b.Err())
+	b.Reset()
+	b.Preformatted("art", " /\\_/\\\n( o.o )")
+	require.Equal(t, b.String(), "```art\n /\\_/\\\n( o.o )\n```\n")
+	require.NoError(t, b.Err())
+	b.Reset()
+	b.Pre("outer")
+	require.ErrorIs(t, b.Err(), ErrUnclosedPreformat)
+	b.Paragraph("```inner")
+	require.ErrorIs(t, b.Err(), ErrNestedPreformat)
+	b.Reset()
+	require.NoError(t, b.Err())
+	b.Pre("outer")
+	b.Pre("inner")
+	require.ErrorIs(t, b.Err(), ErrNestedPreformat)
 	b.Reset()
 	b.Quote("Tempore et quasi dolorum et.\nCorporis quis ut consectetur.\nAliquam omnis id aperiam ut fuga pariatur fugit aliquam")
 	b.Newline()
@@ -0,0 +1,108 @@
+package gemtext
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// htmlLinkSchemes are the URL schemes that WriteHTML renders as links.
+var htmlLinkSchemes = map[string]bool{
+	"":       true,
+	"gemini": true,
+	"http":   true,
+	"https":  true,
+	"gopher": true,
+	"mailto": true,
+}
+
+// safeLink reports whether rawURL is relative
+// or has one of the schemes in htmlLinkSchemes.
+func safeLink(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && htmlLinkSchemes[strings.ToLower(u.Scheme)]
+}
+
+// WriteHTML converts the gemtext read from r to an HTML fragment written to w.
+//
+// Headings, links, list items, quotes, preformatted blocks and paragraphs
+// are converted to their HTML equivalents. Consecutive list items are
+// grouped in a single list. The alt text of preformatted blocks
+// is used as their title and accessible label. All text is escaped.
+// Links are only rendered as anchors if they are relative or use the
+// gemini, http, https, gopher or mailto scheme, so that documents cannot
+// inject javascript: and similar URLs. Other links are rendered as text.
+func WriteHTML(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	sc := NewScanner(r)
+
+	var pre, list bool
+
+	for sc.Scan() {
+		line := sc.Line()
+
+		isItem := line.Type == ListLine
+		if list && !isItem {
+			list = false
+			bw.WriteString("</ul>\n")
+		} else if !list && isItem {
+			list = true
+			bw.WriteString("<ul>\n")
+		}
+
+		text := html.EscapeString(line.Text)
+
+		switch line.Type {
+		case PreformatToggleLine:
+			if pre {
+				bw.WriteString("</pre>\n")
+			} else if text != "" {
+				fmt.Fprintf(bw, "<pre title=\"%s\" aria-label=\"%s\">", text, text)
+			} else {
+				bw.WriteString("<pre>")
+			}
+			pre = !pre
+		case PreformattedLine:
+			bw.WriteString(text)
+			bw.WriteByte('\n')
+		case ListLine:
+			fmt.Fprintf(bw, "<li>%s</li>\n", text)
+		case LinkLine:
+			if text == "" {
+				text = html.EscapeString(line.URL)
+			}
+			if safeLink(line.URL) {
+				fmt.Fprintf(bw, "<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(line.URL), text)
+			} else {
+				fmt.Fprintf(bw, "<p>%s</p>\n", text)
+			}
+		case SubSubHeadingLine:
+			fmt.Fprintf(bw, "<h3>%s</h3>\n", text)
+		case SubHeadingLine:
+			fmt.Fprintf(bw, "<h2>%s</h2>\n", text)
+		case HeadingLine:
+			fmt.Fprintf(bw, "<h1>%s</h1>\n", text)
+		case QuoteLine:
+			fmt.Fprintf(bw, "<blockquote>%s</blockquote>\n", text)
+		default:
+			if text != "" {
+				fmt.Fprintf(bw, "<p>%s</p>\n", text)
+			}
+		}
+	}
+
+	if pre {
+		bw.WriteString("</pre>\n")
+	} else if list {
+		bw.WriteString("</ul>\n")
+	}
+
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,56 @@
+package gemtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestWriteHTML(t *testing.T) {
+	input := "# Title\n" +
+		"Hello <world>\n" +
+		"\n" +
+		"=> gemini://example.com Example\n" +
+		"=> /about.gmi\n" +
+		"* one\n" +
+		"* two\n" +
+		"> quote\n" +
+		"```alt\n" +
+		"a < b\n" +
+		"```\n" +
+		"## Sub\n" +
+		"### SubSub\n"
+
+	expected := "<h1>Title</h1>\n" +
+		"<p>Hello &lt;world&gt;</p>\n" +
+		"<p><a href=\"gemini://example.com\">Example</a></p>\n" +
+		"<p><a href=\"/about.gmi\">/about.gmi</a></p>\n" +
+		"<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n" +
+		"<blockquote>quote</blockquote>\n" +
+		"<pre title=\"alt\" aria-label=\"alt\">a &lt; b\n</pre>\n" +
+		"<h2>Sub</h2>\n" +
+		"<h3>SubSub</h3>\n"
+
+	var sb strings.Builder
+	require.NoError(t, WriteHTML(&sb, strings.NewReader(input)))
+	require.Equal(t, expected, sb.String())
+}
+
+func TestWriteHTMLUnsafeLinks(t *testing.T) {
+	input := "=> javascript:alert(1) Click me\n" +
+		"=> JavaScript:alert(1)\n" +
+		"=> data:text/html,<script>alert(1)</script> Data\n" +
+		"=> mailto:me@example.com Mail\n" +
+		"=> HTTPS://example.com Web\n"
+
+	expected := "<p>Click me</p>\n" +
+		"<p>JavaScript:alert(1)</p>\n" +
+		"<p>Data</p>\n" +
+		"<p><a href=\"mailto:me@example.com\">Mail</a></p>\n" +
+		"<p><a href=\"HTTPS://example.com\">Web</a></p>\n"
+
+	var sb strings.Builder
+	require.NoError(t, WriteHTML(&sb, strings.NewReader(input)))
+	require.Equal(t, expected, sb.String())
+}
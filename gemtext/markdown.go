@@ -0,0 +1,122 @@
+package gemtext
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdLinkRE     = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	mdEmphasisRE = regexp.MustCompile(`\*\*|__`)
+	mdListRE     = regexp.MustCompile(`^\s*[-*+]\s+`)
+	mdRuleRE     = regexp.MustCompile(`^(?:-[ \t]*){3,}$|^(?:\*[ \t]*){3,}$|^(?:_[ \t]*){3,}$`)
+)
+
+// FromMarkdown converts the Markdown read from r to gemtext written to w.
+//
+// The conversion is line based and covers the subset of Markdown that maps
+// onto gemtext: headings, list items, block quotes and fenced code blocks.
+// Consecutive lines of a paragraph are joined into a single line.
+// Inline links and images are replaced by their text and listed as link lines
+// after the block they appear in. Bold markers and horizontal rules are removed.
+func FromMarkdown(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	sc := bufio.NewScanner(r)
+
+	var (
+		pre       bool
+		paragraph []string
+		links     []Line
+	)
+
+	flush := func() {
+		if len(paragraph) != 0 {
+			bw.WriteString(strings.Join(paragraph, " "))
+			bw.WriteByte('\n')
+			paragraph = paragraph[:0]
+		}
+		for _, link := range links {
+			bw.WriteString(link.String())
+			bw.WriteByte('\n')
+		}
+		links = links[:0]
+	}
+
+	// inline extracts the links from text and strips the markup.
+	inline := func(text string) string {
+		for _, m := range mdLinkRE.FindAllStringSubmatch(text, -1) {
+			links = append(links, Line{Type: LinkLine, URL: m[2], Text: m[1]})
+		}
+		text = mdLinkRE.ReplaceAllString(text, "$1")
+		return mdEmphasisRE.ReplaceAllString(text, "")
+	}
+
+	for sc.Scan() {
+		text := strings.TrimRight(sc.Text(), " \t\r")
+		trimmed := strings.TrimSpace(text)
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			if !pre {
+				flush()
+			}
+			bw.WriteString("```")
+			if !pre {
+				bw.WriteString(strings.TrimSpace(trimmed[3:]))
+			}
+			bw.WriteByte('\n')
+			pre = !pre
+			continue
+		} else if pre {
+			bw.WriteString(text)
+			bw.WriteByte('\n')
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+			bw.WriteByte('\n')
+		case mdRuleRE.MatchString(trimmed):
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			flush()
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			if level > 3 {
+				level = 3
+			}
+			heading := inline(strings.TrimSpace(strings.TrimLeft(trimmed, "#")))
+			bw.WriteString(Line{Type: HeadingLine + LineType(level-1), Text: heading}.String())
+			bw.WriteByte('\n')
+		case mdListRE.MatchString(text):
+			if len(paragraph) != 0 {
+				flush()
+			}
+			item := inline(mdListRE.ReplaceAllString(text, ""))
+			bw.WriteString(Line{Type: ListLine, Text: item}.String())
+			bw.WriteByte('\n')
+		case strings.HasPrefix(trimmed, ">"):
+			if len(paragraph) != 0 {
+				flush()
+			}
+			quote := inline(strings.TrimSpace(strings.TrimLeft(trimmed, ">")))
+			bw.WriteString(Line{Type: QuoteLine, Text: quote}.String())
+			bw.WriteByte('\n')
+		default:
+			paragraph = append(paragraph, inline(trimmed))
+		}
+	}
+
+	if pre {
+		bw.WriteString("```\n")
+	} else {
+		flush()
+	}
+
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
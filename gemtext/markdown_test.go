@@ -0,0 +1,40 @@
+package gemtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestFromMarkdown(t *testing.T) {
+	input := "# Title\n" +
+		"Some **bold** text with a [link](https://example.com)\n" +
+		"that continues here.\n" +
+		"\n" +
+		"- one\n" +
+		"+ two\n" +
+		"> quote\n" +
+		"---\n" +
+		"```go\n" +
+		"# not a heading\n" +
+		"```\n" +
+		"#### Deep ![image](/img.png \"title\")\n"
+
+	expected := "# Title\n" +
+		"Some bold text with a link that continues here.\n" +
+		"=> https://example.com link\n" +
+		"\n" +
+		"* one\n" +
+		"* two\n" +
+		"> quote\n" +
+		"```go\n" +
+		"# not a heading\n" +
+		"```\n" +
+		"### Deep image\n" +
+		"=> /img.png image\n"
+
+	var sb strings.Builder
+	require.NoError(t, FromMarkdown(&sb, strings.NewReader(input)))
+	require.Equal(t, expected, sb.String())
+}
@@ -0,0 +1,88 @@
+package gemtext
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Normalize reads gemtext from r and writes it in canonical form to w.
+//
+// Line type prefixes are followed by a single space and trailing whitespace
+// is removed. The labels of consecutive link lines are aligned by padding
+// the URLs with spaces, so that link lists read as a table in the source.
+// Headings are preceded by a blank line, consecutive blank lines are
+// collapsed and leading and trailing blank lines are removed.
+// The contents of preformatted blocks are left untouched.
+func Normalize(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	sc := NewScanner(r)
+
+	var started, blank bool
+	var links []Line
+
+	for sc.Scan() {
+		line := sc.Line()
+
+		switch line.Type {
+		case PreformattedLine:
+		case TextLine, ListLine, QuoteLine:
+			line.Text = strings.TrimRight(line.Text, " \t")
+			if line.Type == TextLine && line.Text == "" {
+				blank = started
+				continue
+			}
+		case HeadingLine, SubHeadingLine, SubSubHeadingLine:
+			blank = started
+		}
+
+		if blank || line.Type != LinkLine {
+			writeLinks(bw, links)
+			links = links[:0]
+		}
+
+		if blank {
+			bw.WriteByte('\n')
+			blank = false
+		}
+
+		started = true
+
+		if line.Type == LinkLine {
+			links = append(links, line)
+			continue
+		}
+
+		bw.WriteString(line.String())
+		bw.WriteByte('\n')
+	}
+
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	writeLinks(bw, links)
+
+	return bw.Flush()
+}
+
+// writeLinks writes consecutive link lines with their labels aligned.
+func writeLinks(bw *bufio.Writer, links []Line) {
+	var width int
+	for _, link := range links {
+		if n := utf8.RuneCountInString(link.URL); link.Text != "" && n > width {
+			width = n
+		}
+	}
+
+	for _, link := range links {
+		bw.WriteString("=> ")
+		bw.WriteString(link.URL)
+		if link.Text != "" {
+			bw.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(link.URL)+1))
+			bw.WriteString(link.Text)
+		}
+		bw.WriteByte('\n')
+	}
+}
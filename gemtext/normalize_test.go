@@ -0,0 +1,77 @@
+package gemtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestNormalize(t *testing.T) {
+	input := "\n" +
+		"#Title  \n" +
+		"Hello world \t\n" +
+		"\n" +
+		"\n" +
+		"=>gemini://example.com \t Example\n" +
+		"=>  /about.gmi\n" +
+		"##Sub\n" +
+		">quote \n" +
+		"```alt\n" +
+		"  keep  \n" +
+		"\n" +
+		"\n" +
+		"```\n" +
+		"\n"
+
+	expected := "# Title\n" +
+		"Hello world\n" +
+		"\n" +
+		"=> gemini://example.com Example\n" +
+		"=> /about.gmi\n" +
+		"\n" +
+		"## Sub\n" +
+		"> quote\n" +
+		"```alt\n" +
+		"  keep  \n" +
+		"\n" +
+		"\n" +
+		"```\n"
+
+	var sb strings.Builder
+	require.NoError(t, Normalize(&sb, strings.NewReader(input)))
+	require.Equal(t, expected, sb.String())
+
+	// normalizing is idempotent
+	var sb2 strings.Builder
+	require.NoError(t, Normalize(&sb2, strings.NewReader(sb.String())))
+	require.Equal(t, expected, sb2.String())
+}
+
+func TestNormalizeAlignLinks(t *testing.T) {
+	input := "=> /a.gmi A\n" +
+		"=>  gemini://example.com/long.gmi   Long\n" +
+		"=> /plain.gmi\n" +
+		"=> /ü.gmi Ü\n" +
+		"\n" +
+		"=> /next.gmi Next\n" +
+		"text\n" +
+		"=> /x.gmi X\n"
+
+	expected := "=> /a.gmi                        A\n" +
+		"=> gemini://example.com/long.gmi Long\n" +
+		"=> /plain.gmi\n" +
+		"=> /ü.gmi                        Ü\n" +
+		"\n" +
+		"=> /next.gmi Next\n" +
+		"text\n" +
+		"=> /x.gmi X\n"
+
+	var sb strings.Builder
+	require.NoError(t, Normalize(&sb, strings.NewReader(input)))
+	require.Equal(t, expected, sb.String())
+
+	var sb2 strings.Builder
+	require.NoError(t, Normalize(&sb2, strings.NewReader(sb.String())))
+	require.Equal(t, expected, sb2.String())
+}
@@ -0,0 +1,122 @@
+package gemtext
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Heading is an entry in the outline of a gemtext document.
+type Heading struct {
+	// Level is 1, 2 or 3 for '#', '##' and '###' headings.
+	Level int
+
+	// Text is the text of the heading.
+	Text string
+
+	// Anchor is the unique fragment identifier of the heading.
+	Anchor string
+
+	// Line is the zero-based line number of the heading in the document.
+	Line int
+}
+
+// Slug returns the fragment identifier of a heading text.
+// Letters and digits are lowercased and kept, and all other runs
+// of characters are replaced by a single hyphen.
+// The same text always results in the same slug.
+//
+//	Slug("Hello, World!") == "hello-world"
+func Slug(text string) string {
+	var sb strings.Builder
+	hyphen := false
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			hyphen = false
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			hyphen = true
+		}
+	}
+
+	return sb.String()
+}
+
+// Outline returns the headings of the gemtext read from r.
+// Anchors are generated by Slug and made unique by suffixing
+// repeated slugs with -1, -2 and so on, in document order.
+// Headings without letters or digits are anchored as "section".
+func Outline(r io.Reader) ([]Heading, error) {
+	var headings []Heading
+	seen := make(map[string]int)
+
+	sc := NewScanner(r)
+	for n := 0; sc.Scan(); n++ {
+		line := sc.Line()
+
+		var level int
+		switch line.Type {
+		case HeadingLine:
+			level = 1
+		case SubHeadingLine:
+			level = 2
+		case SubSubHeadingLine:
+			level = 3
+		default:
+			continue
+		}
+
+		slug := Slug(line.Text)
+		if slug == "" {
+			slug = "section"
+		}
+
+		anchor := slug
+		for seen[anchor] != 0 {
+			anchor = slug + "-" + strconv.Itoa(seen[slug])
+			seen[slug]++
+		}
+		seen[anchor]++
+
+		headings = append(headings, Heading{
+			Level:  level,
+			Text:   line.Text,
+			Anchor: anchor,
+			Line:   n,
+		})
+	}
+
+	return headings, sc.Err()
+}
+
+// ResolveFragment returns the heading that the URL fragment refers to.
+// The fragment may be escaped and prefixed by '#'. It matches the anchor
+// of a heading, or otherwise the first heading with the same slug,
+// so that links written by hand such as "#Hello World" also resolve.
+func ResolveFragment(headings []Heading, fragment string) (Heading, bool) {
+	fragment = strings.TrimPrefix(fragment, "#")
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+
+	for _, h := range headings {
+		if h.Anchor == fragment {
+			return h, true
+		}
+	}
+
+	slug := Slug(fragment)
+	for _, h := range headings {
+		if slug != "" && Slug(h.Text) == slug {
+			return h, true
+		}
+	}
+
+	return Heading{}, false
+}
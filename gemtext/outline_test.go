@@ -0,0 +1,61 @@
+package gemtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestSlug(t *testing.T) {
+	for _, tt := range []struct {
+		text, slug string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  leading and trailing  ", "leading-and-trailing"},
+		{"Ünïcödé 42", "ünïcödé-42"},
+		{"C++ & Go", "c-go"},
+		{"!!!", ""},
+	} {
+		require.Equal(t, tt.slug, Slug(tt.text), tt.text)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	input := "# Title\n" +
+		"text\n" +
+		"## Usage\n" +
+		"```\n" +
+		"# not a heading\n" +
+		"```\n" +
+		"### Usage\n" +
+		"## Usage\n" +
+		"## ???\n"
+
+	headings, err := Outline(strings.NewReader(input))
+	require.NoError(t, err)
+
+	expected := []Heading{
+		{Level: 1, Text: "Title", Anchor: "title", Line: 0},
+		{Level: 2, Text: "Usage", Anchor: "usage", Line: 2},
+		{Level: 3, Text: "Usage", Anchor: "usage-1", Line: 6},
+		{Level: 2, Text: "Usage", Anchor: "usage-2", Line: 7},
+		{Level: 2, Text: "???", Anchor: "section", Line: 8},
+	}
+
+	require.Equal(t, len(expected), len(headings))
+	for i := range expected {
+		require.Equal(t, expected[i], headings[i])
+	}
+
+	h, ok := ResolveFragment(headings, "#usage-1")
+	require.True(t, ok)
+	require.Equal(t, 6, h.Line)
+
+	h, ok = ResolveFragment(headings, "Usage")
+	require.True(t, ok)
+	require.Equal(t, 2, h.Line)
+
+	_, ok = ResolveFragment(headings, "missing")
+	require.True(t, !ok)
+}
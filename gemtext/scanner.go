@@ -0,0 +1,178 @@
+package gemtext
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// LineType is the type of a gemtext line.
+type LineType int
+
+// Gemtext line types.
+const (
+	// TextLine is a line of plain text.
+	TextLine LineType = iota
+
+	// LinkLine is a '=>' link line.
+	LinkLine
+
+	// HeadingLine is a '#' heading line.
+	HeadingLine
+
+	// SubHeadingLine is a '##' heading line.
+	SubHeadingLine
+
+	// SubSubHeadingLine is a '###' heading line.
+	SubSubHeadingLine
+
+	// ListLine is a '*' list item line.
+	ListLine
+
+	// QuoteLine is a '>' quote line.
+	QuoteLine
+
+	// PreformatToggleLine is a '```' line that opens or closes a preformatted block.
+	PreformatToggleLine
+
+	// PreformattedLine is a line inside a preformatted block.
+	PreformattedLine
+)
+
+// Line is a parsed gemtext line.
+type Line struct {
+	// Type is the type of the line.
+	Type LineType
+
+	// Text is the content of the line without the line type prefix.
+	// It is the label of link lines and the alt text
+	// of preformat toggle lines.
+	Text string
+
+	// URL is the URL of link lines.
+	URL string
+
+	// Alt is the alt text of the preformatted block that the line
+	// opens, closes or belongs to. It is set by Scanner for
+	// preformat toggle lines and preformatted lines, so that
+	// renderers can use it as a caption or accessible label.
+	Alt string
+}
+
+// String formats the line in its canonical gemtext form.
+func (l Line) String() string {
+	switch l.Type {
+	case LinkLine:
+		if l.Text == "" {
+			return "=> " + l.URL
+		}
+		return "=> " + l.URL + " " + l.Text
+	case HeadingLine:
+		return "# " + l.Text
+	case SubHeadingLine:
+		return "## " + l.Text
+	case SubSubHeadingLine:
+		return "### " + l.Text
+	case ListLine:
+		return "* " + l.Text
+	case QuoteLine:
+		return "> " + l.Text
+	case PreformatToggleLine:
+		return "```" + l.Text
+	default:
+		return l.Text
+	}
+}
+
+// Scanner reads gemtext line by line.
+type Scanner struct {
+	sc   *bufio.Scanner
+	line Line
+	pre  bool
+	alt  string
+}
+
+// NewScanner returns a new Scanner that reads from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{
+		sc: bufio.NewScanner(r),
+	}
+}
+
+// Scan advances the Scanner to the next line,
+// which will then be available through the Line method.
+// It returns false when the scan stops,
+// either by reaching the end of the input or an error.
+func (s *Scanner) Scan() bool {
+	if !s.sc.Scan() {
+		return false
+	}
+	s.line = ParseLine(strings.TrimSuffix(s.sc.Text(), "\r"), s.pre)
+	if s.line.Type == PreformatToggleLine {
+		// the alt text of the opening line applies to the whole block
+		if s.pre = !s.pre; s.pre {
+			s.alt = s.line.Text
+		}
+		s.line.Alt = s.alt
+	} else if s.line.Type == PreformattedLine {
+		s.line.Alt = s.alt
+	}
+	return true
+}
+
+// Line returns the most recent line parsed by Scan.
+func (s *Scanner) Line() Line {
+	return s.line
+}
+
+// Err returns the first non-EOF error that was encountered by the Scanner.
+func (s *Scanner) Err() error {
+	return s.sc.Err()
+}
+
+// ParseLine parses a single line of gemtext.
+// The pre argument reports whether the line is inside a preformatted block.
+func ParseLine(text string, pre bool) Line {
+	switch {
+	case strings.HasPrefix(text, "```"):
+		return Line{Type: PreformatToggleLine, Text: strings.TrimSpace(text[3:])}
+	case pre:
+		return Line{Type: PreformattedLine, Text: text}
+	case strings.HasPrefix(text, "=>"):
+		url, label := splitLink(text[2:])
+		return Line{Type: LinkLine, Text: label, URL: url}
+	case strings.HasPrefix(text, "###"):
+		return Line{Type: SubSubHeadingLine, Text: strings.TrimSpace(text[3:])}
+	case strings.HasPrefix(text, "##"):
+		return Line{Type: SubHeadingLine, Text: strings.TrimSpace(text[2:])}
+	case strings.HasPrefix(text, "#"):
+		return Line{Type: HeadingLine, Text: strings.TrimSpace(text[1:])}
+	case strings.HasPrefix(text, "* "):
+		return Line{Type: ListLine, Text: text[2:]}
+	case strings.HasPrefix(text, ">"):
+		return Line{Type: QuoteLine, Text: strings.TrimSpace(text[1:])}
+	default:
+		return Line{Type: TextLine, Text: text}
+	}
+}
+
+// splitLink splits the text following a '=>' into its url and label.
+func splitLink(text string) (url, label string) {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		return text[:i], strings.TrimSpace(text[i+1:])
+	}
+	return text, ""
+}
+
+// Links returns the URLs of all link lines read from r.
+func Links(r io.Reader) ([]string, error) {
+	var links []string
+	sc := NewScanner(r)
+	for sc.Scan() {
+		if line := sc.Line(); line.Type == LinkLine && line.URL != "" {
+			links = append(links, line.URL)
+		}
+	}
+	return links, sc.Err()
+}
@@ -0,0 +1,34 @@
+package gemtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestScanner(t *testing.T) {
+	input := "# Title\n=> gemini://example.com Example\n```alt\n=> not a link\n```\n* item\n> quote\ntext\n"
+
+	var lines []Line
+	sc := NewScanner(strings.NewReader(input))
+	for sc.Scan() {
+		lines = append(lines, sc.Line())
+	}
+	require.NoError(t, sc.Err())
+
+	require.Equal(t, []Line{
+		{Type: HeadingLine, Text: "Title"},
+		{Type: LinkLine, Text: "Example", URL: "gemini://example.com"},
+		{Type: PreformatToggleLine, Text: "alt", Alt: "alt"},
+		{Type: PreformattedLine, Text: "=> not a link", Alt: "alt"},
+		{Type: PreformatToggleLine, Alt: "alt"},
+		{Type: ListLine, Text: "item"},
+		{Type: QuoteLine, Text: "quote"},
+		{Type: TextLine, Text: "text"},
+	}, lines)
+
+	links, err := Links(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []string{"gemini://example.com"}, links)
+}
@@ -0,0 +1,53 @@
+package gemtext
+
+import (
+	"bufio"
+	"io"
+)
+
+// WriteText converts the gemtext read from r to plain text written to w.
+//
+// Line type prefixes are removed, except that list items are prefixed
+// with a dash and quotes keep their '>' marker. Links are written as
+// their label followed by the URL in angle brackets.
+// Preformat toggle lines are removed, except that the alt text
+// of a preformatted block is written as a caption in brackets
+// on the line before the block.
+func WriteText(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	sc := NewScanner(r)
+
+	var pre bool
+
+	for sc.Scan() {
+		line := sc.Line()
+
+		switch line.Type {
+		case PreformatToggleLine:
+			if pre = !pre; !pre || line.Alt == "" {
+				continue
+			}
+			bw.WriteString("[" + line.Alt + "]")
+		case LinkLine:
+			if line.Text != "" {
+				bw.WriteString(line.Text)
+				bw.WriteByte(' ')
+			}
+			bw.WriteString("<" + line.URL + ">")
+		case ListLine:
+			bw.WriteString("- " + line.Text)
+		case QuoteLine:
+			bw.WriteString("> " + line.Text)
+		default:
+			bw.WriteString(line.Text)
+		}
+
+		bw.WriteByte('\n')
+	}
+
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,31 @@
+package gemtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestWriteText(t *testing.T) {
+	input := "# Title\n" +
+		"=> gemini://example.com Example\n" +
+		"=> /about.gmi\n" +
+		"* item\n" +
+		"> quote\n" +
+		"```alt\n" +
+		"# pre\n" +
+		"```\n"
+
+	expected := "Title\n" +
+		"Example <gemini://example.com>\n" +
+		"</about.gmi>\n" +
+		"- item\n" +
+		"> quote\n" +
+		"[alt]\n" +
+		"# pre\n"
+
+	var sb strings.Builder
+	require.NoError(t, WriteText(&sb, strings.NewReader(input)))
+	require.Equal(t, expected, sb.String())
+}
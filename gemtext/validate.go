@@ -0,0 +1,69 @@
+package gemtext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Problem is a structural problem in a gemtext document.
+type Problem struct {
+	// Line is the line number, starting at 1.
+	Line int
+
+	// Message describes the problem.
+	Message string
+}
+
+// String formats the problem as "line N: message".
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+// Validate reads gemtext from r and reports structural problems:
+// lines that are not valid UTF-8, link lines without a URL,
+// preformatted blocks that are not closed and lines longer than
+// maxLineLength characters. Line lengths are not checked if
+// maxLineLength is not positive.
+func Validate(r io.Reader, maxLineLength int) ([]Problem, error) {
+	var problems []Problem
+	var pre bool
+	var preStart, n int
+
+	report := func(line int, format string, args ...any) {
+		problems = append(problems, Problem{line, fmt.Sprintf(format, args...)})
+	}
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		n++
+		text := strings.TrimSuffix(sc.Text(), "\r")
+
+		if !utf8.ValidString(text) {
+			report(n, "invalid UTF-8")
+		}
+
+		if maxLineLength > 0 && utf8.RuneCountInString(text) > maxLineLength {
+			report(n, "line longer than %d characters", maxLineLength)
+		}
+
+		switch line := ParseLine(text, pre); line.Type {
+		case PreformatToggleLine:
+			if pre = !pre; pre {
+				preStart = n
+			}
+		case LinkLine:
+			if line.URL == "" {
+				report(n, "link without URL")
+			}
+		}
+	}
+
+	if pre {
+		report(preStart, "unterminated preformatted block")
+	}
+
+	return problems, sc.Err()
+}
@@ -0,0 +1,34 @@
+package gemtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestValidate(t *testing.T) {
+	input := "# Title\n" +
+		"=>\n" +
+		"caf\xe9\n" +
+		"a very long line\n" +
+		"```\n" +
+		"=>\n" +
+		"```\n" +
+		"```unterminated\n"
+
+	problems, err := Validate(strings.NewReader(input), 10)
+	require.NoError(t, err)
+	require.Equal(t, []Problem{
+		{2, "link without URL"},
+		{3, "invalid UTF-8"},
+		{4, "line longer than 10 characters"},
+		{8, "line longer than 10 characters"},
+		{8, "unterminated preformatted block"},
+	}, problems)
+	require.Equal(t, "line 2: link without URL", problems[0].String())
+
+	problems, err = Validate(strings.NewReader("# Fine\n```\ntext\n```\n"), 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(problems))
+}
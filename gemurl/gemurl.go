@@ -0,0 +1,76 @@
+// Package gemurl constructs gemini:// URLs.
+//
+// Gemini servers receive user input as the entire query string,
+// which they unescape with percent-decoding only. The url.QueryEscape and
+// url.Values.Encode functions of the standard library encode spaces as '+',
+// which such servers pass on literally, so this package encodes them as %20:
+//
+//	gemurl.Build("example.com", "/search", "hello world")
+//	// gemini://example.com/search?hello%20world
+package gemurl
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// QueryEscape escapes s so that it can be safely used as the query of
+// a Gemini URL. It is like url.QueryEscape, but encodes spaces as %20.
+func QueryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// Encode encodes the values into key=value pairs separated by '&'
+// sorted by key. It is like url.Values.Encode, but encodes spaces as %20.
+func Encode(v url.Values) string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		for _, val := range v[k] {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(QueryEscape(k))
+			sb.WriteByte('=')
+			sb.WriteString(QueryEscape(val))
+		}
+	}
+	return sb.String()
+}
+
+// Build returns a gemini URL for the path on host, which may include a port.
+// The path is escaped as needed. The query parts are escaped with
+// QueryEscape and joined by '&', so a single part is the user input.
+// Use Encode to build key=value queries and set them on the returned URL.
+func Build(host, path string, query ...string) *url.URL {
+	if path == "" {
+		path = "/"
+	} else if path[0] != '/' {
+		path = "/" + path
+	}
+
+	parts := make([]string, len(query))
+	for i, q := range query {
+		parts[i] = QueryEscape(q)
+	}
+
+	return &url.URL{
+		Scheme:   "gemini",
+		Host:     host,
+		Path:     path,
+		RawQuery: strings.Join(parts, "&"),
+	}
+}
+
+// WithInput returns a copy of u with its query set to the escaped input.
+func WithInput(u *url.URL, input string) *url.URL {
+	u2 := *u
+	u2.RawQuery = QueryEscape(input)
+	return &u2
+}
@@ -0,0 +1,48 @@
+package gemurl_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/askeladdk/gemproto/gemurl"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestQueryEscape(t *testing.T) {
+	t.Parallel()
+
+	for _, testcase := range []struct {
+		In, Out string
+	}{
+		{"", ""},
+		{"hello world", "hello%20world"},
+		{"1+1=2", "1%2B1%3D2"},
+		{"a&b?c#d/e", "a%26b%3Fc%23d%2Fe"},
+		{"héllo", "h%C3%A9llo"},
+	} {
+		out := gemurl.QueryEscape(testcase.In)
+		require.Equal(t, testcase.Out, out, testcase.In)
+		in, err := url.PathUnescape(out)
+		require.NoError(t, err)
+		require.Equal(t, testcase.In, in)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	v := url.Values{"q": {"a b"}, "lang": {"en", "nl"}}
+	require.Equal(t, "lang=en&lang=nl&q=a%20b", gemurl.Encode(v))
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "gemini://example.com/", gemurl.Build("example.com", "").String())
+	require.Equal(t, "gemini://example.com:1966/a%20b/c", gemurl.Build("example.com:1966", "a b/c").String())
+	require.Equal(t, "gemini://example.com/search?hello%20world", gemurl.Build("example.com", "/search", "hello world").String())
+	require.Equal(t, "gemini://example.com/?a&b%20c", gemurl.Build("example.com", "/", "a", "b c").String())
+
+	u := gemurl.WithInput(gemurl.Build("example.com", "/search", "old"), "new input")
+	require.Equal(t, "gemini://example.com/search?new%20input", u.String())
+}
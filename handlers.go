@@ -1,8 +1,17 @@
 package gemproto
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
 	urlpkg "net/url"
+	"path"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/askeladdk/gemproto/gemtext"
 )
 
 // Redirect responds with a 3x redirection to the given URL.
@@ -17,6 +26,33 @@ func RedirectHandler(url string, code int) Handler {
 	})
 }
 
+// SanitizeMeta makes meta text that is derived from user input safe
+// to send in a response header. Control characters, including CR and LF
+// which would terminate the header early, are replaced by spaces,
+// invalid UTF-8 is removed and the result is truncated to 1024 bytes.
+func SanitizeMeta(meta string) string {
+	const maxlen = 1024
+
+	meta = strings.ToValidUTF8(meta, "")
+	meta = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, meta)
+
+	if len(meta) > maxlen {
+		// truncate at a rune boundary
+		i := maxlen
+		for i > 0 && !utf8.RuneStart(meta[i]) {
+			i--
+		}
+		meta = meta[:i]
+	}
+
+	return meta
+}
+
 // NotFound responds with 51 Not Found.
 func NotFound(w ResponseWriter, r *Request) {
 	w.WriteHeader(StatusNotFound, "Not Found")
@@ -67,3 +103,86 @@ func Input(prompt string) func(Handler) Handler {
 		})
 	}
 }
+
+// Page is an in-memory resource served by MapHandler.
+type Page struct {
+	// MIMEType is the mimetype of the page.
+	// It is derived from the path extension if empty.
+	MIMEType string
+
+	// Data is the content of the page.
+	Data []byte
+}
+
+type mapHandler map[string]Page
+
+// MapHandler returns a Handler that serves the pages in m
+// by exact match of the request URL path.
+// Requests for paths not in m are answered with 51 Not Found.
+//
+// The map is copied so the returned handler is safe to use concurrently
+// and is not affected by later modifications to m.
+func MapHandler(m map[string]Page) Handler {
+	h := make(mapHandler, len(m))
+	for k, v := range m {
+		h[k] = v
+	}
+	return h
+}
+
+func (h mapHandler) ServeGemini(w ResponseWriter, r *Request) {
+	upath := r.URL.Path
+	if upath == "" {
+		upath = "/"
+	}
+
+	page, ok := h[upath]
+	if !ok {
+		NotFound(w, r)
+		return
+	}
+
+	mimetype := page.MIMEType
+	if mimetype == "" {
+		mimetype = mime.TypeByExtension(path.Ext(upath))
+		if mimetype == "" {
+			mimetype = "application/octet-stream"
+		}
+	}
+
+	w.WriteHeader(StatusOK, mimetype)
+	_, _ = w.Write(page.Data)
+}
+
+// JSON marshals v and responds with 20 application/json.
+// If v cannot be marshalled, it responds with 40 Temporary Failure
+// and returns the error.
+func JSON(w ResponseWriter, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(StatusTemporaryFailure, "Error encoding JSON")
+		return err
+	}
+
+	w.WriteHeader(StatusOK, "application/json")
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteString writes s to w.
+// It avoids converting s to a byte slice if w implements io.StringWriter.
+func WriteString(w ResponseWriter, s string) (int, error) {
+	return io.WriteString(w, s)
+}
+
+// Text formats according to a format specifier and writes to w.
+func Text(w ResponseWriter, format string, args ...any) (int, error) {
+	return fmt.Fprintf(w, format, args...)
+}
+
+// Gemtext responds with 20 text/gemini and the contents of b.
+func Gemtext(w ResponseWriter, b *gemtext.Builder) error {
+	w.WriteHeader(StatusOK, gemtext.MIMEType)
+	_, err := b.WriteTo(w)
+	return err
+}
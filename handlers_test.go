@@ -2,10 +2,12 @@ package gemproto_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/askeladdk/gemproto"
 	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/gemtext"
 	"github.com/askeladdk/gemproto/internal/require"
 )
 
@@ -57,3 +59,58 @@ func TestNotFoundHandler(t *testing.T) {
 	mux.ServeGemini(w, r)
 	require.Equal(t, gemproto.StatusNotFound, w.Code)
 }
+
+func TestMapHandler(t *testing.T) {
+	t.Parallel()
+
+	h := gemproto.MapHandler(map[string]gemproto.Page{
+		"/index.gmi": {Data: []byte("# hello\n")},
+		"/data":      {MIMEType: "text/plain", Data: []byte("data")},
+	})
+
+	for _, testcase := range []struct {
+		URL  string
+		Code int
+		Meta string
+		Body string
+	}{
+		{"/index.gmi", gemproto.StatusOK, gemtext.MIMEType, "# hello\n"},
+		{"/data", gemproto.StatusOK, "text/plain", "data"},
+		{"/missing", gemproto.StatusNotFound, "Not Found", ""},
+	} {
+		w := gemtest.NewRecorder()
+		r := gemtest.NewRequest(testcase.URL)
+		h.ServeGemini(w, r)
+		require.Equal(t, testcase.Code, w.Code, testcase.URL)
+		require.Equal(t, testcase.Meta, w.Meta, testcase.URL)
+		require.Equal(t, testcase.Body, w.Body.String(), testcase.URL)
+	}
+}
+
+func TestSanitizeMeta(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "hello  world", gemproto.SanitizeMeta("hello\r\nworld"))
+	require.Equal(t, "abc", gemproto.SanitizeMeta("a\xffbc"))
+	require.Equal(t, 1024, len(gemproto.SanitizeMeta(strings.Repeat("x", 2000))))
+	require.Equal(t, 1023, len(gemproto.SanitizeMeta("x"+strings.Repeat("é", 600))))
+}
+
+func TestWriteHelpers(t *testing.T) {
+	t.Parallel()
+
+	w := gemtest.NewRecorder()
+	_, err := gemproto.WriteString(w, "hello ")
+	require.NoError(t, err)
+	_, err = gemproto.Text(w, "%s %d", "world", 42)
+	require.NoError(t, err)
+	require.Equal(t, "hello world 42", w.Body.String())
+
+	b := gemtext.NewBuilder(nil)
+	b.Heading("hello")
+	w = gemtest.NewRecorder()
+	w.WriteHeader(gemproto.StatusOK, "text/plain")
+	require.NoError(t, gemproto.Gemtext(w, b))
+	require.Equal(t, gemtext.MIMEType, w.Meta)
+	require.Equal(t, "# hello\n", w.Body.String())
+}
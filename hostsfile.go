@@ -16,6 +16,18 @@ import (
 
 var ErrCertificateNotTrusted = errors.New("gemproto: certificate not trusted")
 
+// UnknownHostError is returned when a host is verified in pinning mode
+// but it is not recorded in the HostsFile.
+type UnknownHostError struct {
+	// Addr is the domain:port of the remote host.
+	Addr string
+}
+
+// Error implements the error interface.
+func (err UnknownHostError) Error() string {
+	return fmt.Sprintf("gemproto: unknown host: %s", err.Addr)
+}
+
 // Host is an entry in HostsFile.
 type Host struct {
 	// Addr is the domain:port of the remote host.
@@ -60,7 +72,42 @@ type Host struct {
 type HostsFile struct {
 	hosts map[string]Host
 	w     io.Writer
+	audit io.Writer
 	mu    sync.RWMutex
+
+	// gen counts the calls to SetHost that changed an entry and
+	// setAt records the generation at which each entry was last set,
+	// so that ReadFrom does not overwrite entries set while parsing.
+	gen   uint64
+	setAt map[string]uint64
+}
+
+// TrustDecision is a decision made by HostsFile.TrustCertificate.
+type TrustDecision int
+
+// Trust decisions recorded in the audit log.
+const (
+	// TrustAccepted is the decision to trust an unknown host on first use.
+	TrustAccepted TrustDecision = iota + 1
+
+	// TrustRenewed is the decision to replace the entry of a known host
+	// because its certificate has expired or its expiry date has changed.
+	TrustRenewed
+
+	// TrustRejected is the decision to reject a certificate that does
+	// not match the unexpired entry of a known host.
+	TrustRejected
+)
+
+var trustDecisionNames = map[TrustDecision]string{
+	TrustAccepted: "accepted",
+	TrustRenewed:  "renewed",
+	TrustRejected: "rejected",
+}
+
+// String implements fmt.Stringer.
+func (d TrustDecision) String() string {
+	return trustDecisionNames[d]
 }
 
 // NewHostsFile returns a new HostsFile.
@@ -73,6 +120,53 @@ func NewHostsFile(w io.Writer) *HostsFile {
 	}
 }
 
+// SetAuditLog sets the writer that records every trust decision made by
+// TrustCertificate. Records are written and flushed, if w implements
+// `Flush() error`, before the decision takes effect. Hosts are not trusted
+// if the record cannot be written, so that the audit log is never behind
+// the hostsfile. Auditing is disabled if w is nil.
+//
+// Each record is a line consisting of six fields separated by spaces:
+//
+// time<SPACE>decision<SPACE>address<SPACE>algorithm<SPACE>old-fingerprint<SPACE>new-fingerprint<LF>
+//
+//   - time is the time of the decision in RFC 3339 format.
+//   - decision is one of accepted, renewed or rejected.
+//   - address is the domain:port of the remote host.
+//   - algorithm is the hashing algorithm used to compute the fingerprints.
+//   - old-fingerprint is the fingerprint of the known host or - if the host was unknown.
+//   - new-fingerprint is the fingerprint of the presented certificate.
+func (hf *HostsFile) SetAuditLog(w io.Writer) {
+	hf.mu.Lock()
+	defer hf.mu.Unlock()
+	hf.audit = w
+}
+
+func (hf *HostsFile) writeAudit(d TrustDecision, addr, algo, oldfp, newfp string) error {
+	hf.mu.Lock()
+	defer hf.mu.Unlock()
+
+	if hf.audit == nil {
+		return nil
+	}
+
+	if oldfp == "" {
+		oldfp = "-"
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := fmt.Fprintf(hf.audit, "%s %s %s %s %s %s\n",
+		now, d, addr, algo, oldfp, newfp); err != nil {
+		return err
+	}
+
+	if flusher, ok := hf.audit.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
 // Host returns the Host associated with the domain:port address.
 func (hf *HostsFile) Host(addr string) (h Host, exists bool) {
 	hf.mu.RLock()
@@ -92,6 +186,12 @@ func (hf *HostsFile) SetHost(h Host) error {
 
 	hf.hosts[h.Addr] = h
 
+	hf.gen++
+	if hf.setAt == nil {
+		hf.setAt = make(map[string]uint64)
+	}
+	hf.setAt[h.Addr] = hf.gen
+
 	notAfter := h.NotAfter.Format(time.RFC3339)
 	if _, err := fmt.Fprintf(hf.w, "%s %s %s %s\n",
 		h.Addr, h.Algorithm, h.Fingerprint, notAfter); err != nil {
@@ -115,7 +215,12 @@ func (hf *HostsFile) TrustCertificate(cert *x509.Certificate, addr string) error
 	notAfter := cert.NotAfter.UTC()
 	fp := gemcert.Fingerprint(cert)
 
-	if h, ok := hf.Host(addr); ok {
+	decision := TrustAccepted
+	h, ok := hf.Host(addr)
+
+	if ok {
+		decision = TrustRenewed
+
 		// fingerprint mismatch
 		if algo != h.Algorithm || fp != h.Fingerprint {
 			// stored certificate has expired, renew it
@@ -124,6 +229,7 @@ func (hf *HostsFile) TrustCertificate(cert *x509.Certificate, addr string) error
 			}
 
 			// fingerprint mismatch but cert not expired
+			_ = hf.writeAudit(TrustRejected, addr, algo, h.Fingerprint, fp)
 			return ErrCertificateNotTrusted
 		}
 
@@ -139,6 +245,10 @@ renew:
 		return err
 	}
 
+	if err := hf.writeAudit(decision, addr, algo, h.Fingerprint, fp); err != nil {
+		return err
+	}
+
 	return hf.SetHost(Host{
 		Addr:        addr,
 		Algorithm:   algo,
@@ -147,11 +257,42 @@ renew:
 	})
 }
 
+// VerifyPinned verifies the certificate of the remote host address
+// against the pinned entry without trusting unknown hosts.
+// It returns UnknownHostError if the host is not pinned
+// and ErrCertificateNotTrusted if the fingerprint does not match
+// or the pinned certificate has expired.
+// The hostsfile is never updated.
+func (hf *HostsFile) VerifyPinned(cert *x509.Certificate, addr string) error {
+	h, ok := hf.Host(addr)
+	if !ok {
+		return UnknownHostError{Addr: addr}
+	}
+
+	if h.Algorithm != "sha256" || h.Fingerprint != gemcert.Fingerprint(cert) {
+		return ErrCertificateNotTrusted
+	} else if time.Now().UTC().After(h.NotAfter) {
+		return ErrCertificateNotTrusted
+	}
+
+	host, _ := splitHostPort(addr)
+	return verifyHostname(cert, host)
+}
+
 // ReadFrom parses a hostsfile and stores the entries in memory.
 // Later entries overwrite earlier ones.
+//
+// The hostsfile is parsed without holding the lock, so that trust checks
+// are not blocked while reading large files. The entries are merged
+// in a single step after parsing, and are not stored if parsing fails.
+// Entries that were set by SetHost or TrustCertificate while parsing
+// take precedence over the entries read from r.
 func (hf *HostsFile) ReadFrom(r io.Reader) (n int64, err error) {
-	hf.mu.Lock()
-	defer hf.mu.Unlock()
+	hf.mu.RLock()
+	start := hf.gen
+	hf.mu.RUnlock()
+
+	hosts := make(map[string]Host)
 
 	cr := countReader{r: r}
 	sc := bufio.NewScanner(&cr)
@@ -173,12 +314,29 @@ func (hf *HostsFile) ReadFrom(r io.Reader) (n int64, err error) {
 					Fingerprint: fields[2],
 					NotAfter:    notAfter.UTC(),
 				}
-				hf.hosts[h.Addr] = h
+				hosts[h.Addr] = h
+			}
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return cr.n, err
+	}
+
+	hf.mu.Lock()
+	defer hf.mu.Unlock()
+
+	if len(hf.hosts) == 0 {
+		hf.hosts = hosts
+	} else {
+		for addr, h := range hosts {
+			if hf.setAt[addr] <= start {
+				hf.hosts[addr] = h
 			}
 		}
 	}
 
-	return cr.n, sc.Err()
+	return cr.n, nil
 }
 
 func verifyHostname(cert *x509.Certificate, hostname string) error {
@@ -1,7 +1,9 @@
 package gemproto_test
 
 import (
+	"crypto/x509"
 	"crypto/x509/pkix"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -75,6 +77,49 @@ func TestHostsFileTOFU(t *testing.T) {
 	})
 }
 
+func TestHostsFileAuditLog(t *testing.T) {
+	t.Parallel()
+
+	var audit strings.Builder
+	hf := gemproto.NewHostsFile(io.Discard)
+	hf.SetAuditLog(&audit)
+
+	create := func(d time.Duration) *x509.Certificate {
+		cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+			DNSNames: []string{"localhost"},
+			Duration: d,
+			Subject: pkix.Name{
+				CommonName: "localhost",
+			},
+		})
+		require.NoError(t, err)
+		return cert.Leaf
+	}
+
+	expired, renewed, mismatch := create(0), create(time.Hour), create(time.Hour)
+	require.NoError(t, hf.TrustCertificate(expired, "localhost"))
+	require.NoError(t, hf.TrustCertificate(renewed, "localhost"))
+	require.NoError(t, hf.TrustCertificate(renewed, "localhost"))
+	require.ErrorIs(t, hf.TrustCertificate(mismatch, "localhost"), gemproto.ErrCertificateNotTrusted)
+
+	lines := strings.Split(strings.TrimSuffix(audit.String(), "\n"), "\n")
+	require.Equal(t, 3, len(lines))
+
+	expected := [][]string{
+		{"accepted", "localhost", "sha256", "-", gemcert.Fingerprint(expired)},
+		{"renewed", "localhost", "sha256", gemcert.Fingerprint(expired), gemcert.Fingerprint(renewed)},
+		{"rejected", "localhost", "sha256", gemcert.Fingerprint(renewed), gemcert.Fingerprint(mismatch)},
+	}
+
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		require.Equal(t, 6, len(fields), line)
+		_, err := time.Parse(time.RFC3339, fields[0])
+		require.NoError(t, err)
+		require.Equal(t, strings.Join(expected[i], " "), strings.Join(fields[1:], " "))
+	}
+}
+
 func TestHostsFileReadFrom(t *testing.T) {
 	t.Parallel()
 
@@ -100,3 +145,63 @@ func TestOpenHostsFile(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, f.Close())
 }
+
+func TestHostsFileReadFromUnlocked(t *testing.T) {
+	t.Parallel()
+
+	hf := gemproto.NewHostsFile(io.Discard)
+	require.NoError(t, hf.SetHost(gemproto.Host{Addr: "known:1965"}))
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := hf.ReadFrom(pr)
+		require.NoError(t, err)
+	}()
+
+	_, err := io.WriteString(pw, "new:1965 sha256 abcdef 2050-12-31T00:00:00Z\n")
+	require.NoError(t, err)
+
+	// lookups are not blocked while the hostsfile is being read
+	_, exists := hf.Host("known:1965")
+	require.True(t, exists)
+	_, exists = hf.Host("new:1965")
+	require.True(t, !exists)
+
+	// entries set while the hostsfile is being read are not overwritten
+	require.NoError(t, hf.SetHost(gemproto.Host{Addr: "set:1965", Fingerprint: "fresh"}))
+	_, err = io.WriteString(pw, "set:1965 sha256 stale 2050-12-31T00:00:00Z\n")
+	require.NoError(t, err)
+
+	pw.Close()
+	<-done
+
+	_, exists = hf.Host("known:1965")
+	require.True(t, exists)
+	_, exists = hf.Host("new:1965")
+	require.True(t, exists)
+	h, _ := hf.Host("set:1965")
+	require.Equal(t, "fresh", h.Fingerprint)
+}
+
+func BenchmarkHostsFileReadFrom(b *testing.B) {
+	const lines = 1_000_000
+	const hosts = 10_000
+
+	var sb strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&sb, "host%d.example:1965 sha256 %064x 2050-12-31T00:00:00Z\n", i%hosts, i)
+	}
+	data := sb.String()
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		hf := gemproto.NewHostsFile(io.Discard)
+		if _, err := hf.ReadFrom(strings.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
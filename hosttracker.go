@@ -0,0 +1,230 @@
+package gemproto
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrHostBackoff matches HostBackoffError with errors.Is,
+// so that callers can tell a refused request from a network failure.
+var ErrHostBackoff = errors.New("gemproto: host is backing off")
+
+// HostBackoffError is returned by Client.Do, wrapped in a URLError
+// with Op "backoff", when a request is refused without dialing
+// because its host failed recently and is backing off.
+type HostBackoffError struct {
+	// Host is the host of the request.
+	Host string
+
+	// Until is the time at which the host is tried again.
+	Until time.Time
+}
+
+// Error implements the error interface.
+func (err *HostBackoffError) Error() string {
+	return fmt.Sprintf("gemproto: host %s is backing off until %s", err.Host, err.Until.Format(time.RFC3339))
+}
+
+// Is reports whether target is ErrHostBackoff.
+func (err *HostBackoffError) Is(target error) bool {
+	return target == ErrHostBackoff
+}
+
+// HostStats are the outbound statistics of a host.
+type HostStats struct {
+	// Host is the lowercase host:port of the requests.
+	Host string
+
+	// Successes is the number of successful requests.
+	Successes int64
+
+	// Failures is the number of failed requests.
+	Failures int64
+
+	// ConsecutiveFailures is the number of failures since the last success.
+	ConsecutiveFailures int
+
+	// Latency is the mean time until the response header was received.
+	Latency time.Duration
+
+	// LastError describes the most recent failure.
+	LastError string
+
+	// BackoffUntil is the time until which requests are refused.
+	// It is zero if the host is not backing off.
+	BackoffUntil time.Time
+
+	totalLatency time.Duration
+}
+
+// HostTracker records the outcome of requests per host and backs off
+// exponentially from hosts that keep failing, so that crawlers do not
+// waste time on dead hosts. Requests fail if they return an error,
+// 41 SERVER UNAVAILABLE or 44 SLOW DOWN, in which case the host backs off
+// for the number of seconds in the meta. Other responses succeed.
+// Hosts are identified by their lowercase host:port, where the port
+// defaults to 1965. Redirects are attributed to the host of the
+// original request.
+//
+//	client := gemproto.Client{
+//	  HostTracker: &gemproto.HostTracker{},
+//	}
+//
+// HostTracker is safe to use concurrently.
+type HostTracker struct {
+	// Backoff is the backoff after the first consecutive failure,
+	// which doubles with every further failure. Defaults to 1 second.
+	Backoff time.Duration
+
+	// MaxBackoff limits the backoff, including the backoff
+	// requested by 44 SLOW DOWN. Defaults to 1 hour.
+	MaxBackoff time.Duration
+
+	hosts map[string]*HostStats
+	mu    sync.Mutex
+}
+
+// hostKey normalizes host to a lowercase host:port.
+func hostKey(host string) string {
+	host = strings.ToLower(host)
+	if h, port := splitHostPort(host); port != "" {
+		return net.JoinHostPort(h, port)
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), "1965")
+}
+
+// backoff returns the backoff after the given number of consecutive
+// failures, or wait if the host asked to wait that long.
+func (t *HostTracker) backoff(failures int, wait time.Duration) time.Duration {
+	backoff, limit := t.Backoff, t.MaxBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if limit <= 0 {
+		limit = time.Hour
+	}
+
+	if wait > 0 {
+		backoff = wait
+	} else {
+		for i := 1; i < failures && backoff < limit; i++ {
+			backoff *= 2
+		}
+	}
+
+	if backoff > limit {
+		return limit
+	}
+	return backoff
+}
+
+func (t *HostTracker) stats(host string) *HostStats {
+	if t.hosts == nil {
+		t.hosts = make(map[string]*HostStats)
+	}
+	s, ok := t.hosts[host]
+	if !ok {
+		s = &HostStats{Host: host}
+		t.hosts[host] = s
+	}
+	return s
+}
+
+// Allow reports whether requests to host may be sent.
+// It returns a *HostBackoffError if the host is backing off.
+func (t *HostTracker) Allow(host string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	host = hostKey(host)
+	if s, ok := t.hosts[host]; ok && time.Now().Before(s.BackoffUntil) {
+		return &HostBackoffError{Host: host, Until: s.BackoffUntil}
+	}
+	return nil
+}
+
+// Record records the outcome of a request to host
+// that took latency until the response header was received.
+// A nil err means that the request succeeded.
+func (t *HostTracker) Record(host string, latency time.Duration, err error) {
+	t.record(host, latency, err, 0)
+}
+
+// record records the outcome of a request, where wait
+// is the backoff requested by the host if positive.
+func (t *HostTracker) record(host string, latency time.Duration, err error, wait time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stats(hostKey(host))
+	s.totalLatency += latency
+
+	if err == nil {
+		s.Successes++
+		s.ConsecutiveFailures = 0
+		s.BackoffUntil = time.Time{}
+	} else {
+		s.Failures++
+		s.ConsecutiveFailures++
+		s.LastError = err.Error()
+		s.BackoffUntil = time.Now().Add(t.backoff(s.ConsecutiveFailures, wait))
+	}
+
+	s.Latency = s.totalLatency / time.Duration(s.Successes+s.Failures)
+}
+
+// Snapshot returns the statistics of all hosts sorted by host.
+func (t *HostTracker) Snapshot() []HostStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := make([]HostStats, 0, len(t.hosts))
+	for _, s := range t.hosts {
+		snapshot = append(snapshot, *s)
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Host < snapshot[j].Host
+	})
+
+	return snapshot
+}
+
+// track records the outcome of the requests sent by next.
+func (t *HostTracker) track(next DoFunc) DoFunc {
+	return func(req *Request) (*Response, error) {
+		if req.URL == nil || req.URL.Host == "" {
+			return next(req)
+		}
+
+		host := req.URL.Host
+		if err := t.Allow(host); err != nil {
+			return nil, &URLError{"backoff", req.URL.String(), err}
+		}
+
+		start := time.Now()
+		res, err := next(req)
+
+		outcome, wait := err, time.Duration(0)
+		if err == nil {
+			switch res.StatusCode {
+			case StatusSlowDown:
+				if seconds, err := strconv.Atoi(strings.TrimSpace(res.Meta)); err == nil && seconds > 0 {
+					wait = time.Duration(seconds) * time.Second
+				}
+				fallthrough
+			case StatusServerUnavailable:
+				outcome = fmt.Errorf("%d %s", res.StatusCode, res.Meta)
+			}
+		}
+
+		t.record(host, time.Since(start), outcome, wait)
+		return res, err
+	}
+}
@@ -0,0 +1,116 @@
+package gemproto_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestHostTracker(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		if r.URL.Path == "/busy" {
+			w.WriteHeader(gemproto.StatusServerUnavailable, "Busy")
+		}
+	}))
+	defer server.Close()
+
+	tracker := gemproto.HostTracker{Backoff: time.Hour}
+	client := gemproto.Client{HostTracker: &tracker}
+
+	res, err := client.Get(server.URL + "/")
+	require.NoError(t, err)
+	res.Body.Close()
+
+	res, err = client.Get(server.URL + "/busy")
+	require.NoError(t, err)
+	require.Equal(t, gemproto.StatusServerUnavailable, res.StatusCode)
+	res.Body.Close()
+
+	// the host is backing off after the temporary failure
+	_, err = client.Get(server.URL + "/")
+	var backoff *gemproto.HostBackoffError
+	require.True(t, errors.As(err, &backoff), err)
+	require.ErrorIs(t, err, gemproto.ErrHostBackoff)
+	var urlErr *gemproto.URLError
+	require.True(t, errors.As(err, &urlErr) && urlErr.Op == "backoff", err)
+
+	u, _ := url.Parse(server.URL)
+	require.Equal(t, u.Host, backoff.Host)
+
+	snapshot := tracker.Snapshot()
+	require.Equal(t, 1, len(snapshot))
+	require.Equal(t, int64(1), snapshot[0].Successes)
+	require.Equal(t, int64(1), snapshot[0].Failures)
+	require.Equal(t, 1, snapshot[0].ConsecutiveFailures)
+	require.Equal(t, "41 Busy", snapshot[0].LastError)
+	require.True(t, snapshot[0].Latency > 0)
+	require.True(t, time.Until(snapshot[0].BackoffUntil) > 59*time.Minute)
+}
+
+func TestHostTrackerBackoff(t *testing.T) {
+	t.Parallel()
+
+	tracker := gemproto.HostTracker{
+		Backoff:    time.Minute,
+		MaxBackoff: 3 * time.Minute,
+	}
+
+	until := func() time.Duration {
+		return time.Until(tracker.Snapshot()[0].BackoffUntil).Round(time.Minute)
+	}
+
+	failure := errors.New("connection refused")
+
+	tracker.Record("Example.com", time.Millisecond, failure)
+	require.Equal(t, time.Minute, until())
+	tracker.Record("example.com:1965", time.Millisecond, failure)
+	require.Equal(t, 2*time.Minute, until())
+	tracker.Record("example.com", time.Millisecond, failure)
+	require.Equal(t, 3*time.Minute, until())
+	require.True(t, tracker.Allow("example.com") != nil)
+
+	tracker.Record("example.com", time.Millisecond, nil)
+	require.NoError(t, tracker.Allow("example.com"))
+	require.Equal(t, 0, tracker.Snapshot()[0].ConsecutiveFailures)
+	require.Equal(t, 1, len(tracker.Snapshot()))
+	require.Equal(t, "example.com:1965", tracker.Snapshot()[0].Host)
+}
+
+func TestHostTrackerSlowDown(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(gemproto.StatusTemporaryFailure, "Failure")
+		case "/slow":
+			w.WriteHeader(gemproto.StatusSlowDown, "120")
+		}
+	}))
+	defer server.Close()
+
+	tracker := gemproto.HostTracker{Backoff: time.Second}
+	client := gemproto.Client{HostTracker: &tracker}
+
+	// failures of the handler do not count against the host
+	res, err := client.Get(server.URL + "/fail")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, int64(1), tracker.Snapshot()[0].Successes)
+
+	res, err = client.Get(server.URL + "/slow")
+	require.NoError(t, err)
+	res.Body.Close()
+
+	// the host backs off for as long as it asked
+	snapshot := tracker.Snapshot()
+	require.Equal(t, int64(1), snapshot[0].Failures)
+	require.Equal(t, 2*time.Minute, time.Until(snapshot[0].BackoffUntil).Round(time.Minute))
+}
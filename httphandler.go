@@ -0,0 +1,228 @@
+package gemproto
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/askeladdk/gemproto/gemtext"
+	"github.com/askeladdk/gemproto/gemurl"
+)
+
+// HTTPInputField is the name of the HTML form field used by HTTPHandler
+// to submit user input in response to 10 INPUT and 11 SENSITIVE INPUT.
+const HTTPInputField = "input"
+
+// HTTPHandler returns an http.Handler that serves HTTP requests
+// with the Gemini Handler h, so that the same implementation
+// can power both a website and a capsule.
+//
+// Gemini responses are mapped to HTTP as follows:
+//
+//   - 10 and 11 render an HTML form that prompts for input.
+//     The input is submitted as the query string of the next request.
+//   - 2x responses are served with the meta as Content-Type.
+//     Gemtext is converted to HTML.
+//   - 3x responses redirect to the target URL.
+//     Gemini URLs on the same host are converted to paths,
+//     other URLs are kept as they are.
+//   - 4x, 5x and 6x responses are mapped to the nearest HTTP error code
+//     with the meta as the error message.
+func HTTPHandler(h Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := url.URL{
+			Scheme:   "gemini",
+			Host:     r.Host,
+			Path:     r.URL.Path,
+			RawPath:  r.URL.RawPath,
+			RawQuery: r.URL.RawQuery,
+		}
+
+		// convert the submitted form field to a gemini query string
+		if q := r.URL.Query(); len(q) == 1 && q.Has(HTTPInputField) {
+			u.RawQuery = gemurl.QueryEscape(q.Get(HTTPInputField))
+		}
+
+		host, _ := splitHostPort(r.Host)
+
+		req := Request{
+			URL:        &u,
+			RequestURI: u.String(),
+			RemoteAddr: r.RemoteAddr,
+			Host:       host,
+			TLS:        r.TLS,
+			ctx:        r.Context(),
+		}
+
+		rw := httpResponseWriter{
+			w:          w,
+			statusCode: StatusOK,
+			metadata:   gemtext.MIMEType,
+		}
+
+		h.ServeGemini(&rw, &req)
+		rw.finish(&req)
+	})
+}
+
+type httpResponseWriter struct {
+	w           http.ResponseWriter
+	buf         bytes.Buffer
+	statusCode  int
+	metadata    string
+	wroteHeader bool
+	buffered    bool
+}
+
+func (rw *httpResponseWriter) WriteHeader(statusCode int, meta string) {
+	rw.statusCode, rw.metadata = statusCode, meta
+}
+
+func (rw *httpResponseWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		// gemtext must be converted and raw headers must be parsed,
+		// so the output is buffered until the handler returns
+		rw.buffered = rw.statusCode < 10 || rw.statusCode/10 != 2 || isGemtext(rw.metadata)
+		if !rw.buffered {
+			rw.w.Header().Set("Content-Type", rw.metadata)
+			rw.w.WriteHeader(http.StatusOK)
+		}
+	}
+
+	if rw.buffered {
+		return rw.buf.Write(p)
+	}
+
+	return rw.w.Write(p)
+}
+
+// Flush implements Flusher. Buffered responses
+// are not sent until the handler returns.
+func (rw *httpResponseWriter) Flush() {
+	if rw.wroteHeader && !rw.buffered {
+		if f, ok := rw.w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+func (rw *httpResponseWriter) finish(r *Request) {
+	if rw.wroteHeader && !rw.buffered {
+		return
+	}
+
+	code, meta := rw.statusCode, rw.metadata
+
+	// the handler wrote the header itself
+	if code < 10 {
+		status, m, err := readResponseHeader(&rw.buf)
+		if err != nil {
+			http.Error(rw.w, "Bad Gateway", http.StatusBadGateway)
+			return
+		}
+		code, _ = strconv.Atoi(status)
+		meta = m
+	}
+
+	switch code / 10 {
+	case 1:
+		inputType := "text"
+		if code == StatusSensitiveInput {
+			inputType = "password"
+		}
+		rw.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(rw.w, httpInputForm, html.EscapeString(meta),
+			html.EscapeString(meta), inputType, HTTPInputField)
+	case 2:
+		if !isGemtext(meta) {
+			rw.w.Header().Set("Content-Type", meta)
+			_, _ = rw.buf.WriteTo(rw.w)
+			return
+		}
+		rw.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(rw.w, httpPageHeader, html.EscapeString(r.URL.Path))
+		_ = gemtext.WriteHTML(rw.w, &rw.buf)
+		io.WriteString(rw.w, httpPageFooter)
+	case 3:
+		target := absoluteURL(r, meta)
+		// only redirects to the same host can be served over HTTP,
+		// other targets are passed on as absolute URLs
+		if u, err := url.Parse(target); err == nil && u.Scheme == "gemini" && strings.EqualFold(u.Host, r.URL.Host) {
+			target = u.RequestURI()
+		}
+		httpCode := http.StatusFound
+		if code == StatusPermanentRedirect {
+			httpCode = http.StatusMovedPermanently
+		}
+		rw.w.Header().Set("Location", target)
+		rw.w.WriteHeader(httpCode)
+	default:
+		http.Error(rw.w, meta, httpStatusCode(code))
+	}
+}
+
+func isGemtext(meta string) bool {
+	mediatype, _, _ := mime.ParseMediaType(meta)
+	return mediatype == "text/gemini"
+}
+
+// httpStatusCode maps a Gemini failure status code to an HTTP status code.
+func httpStatusCode(code int) int {
+	switch code {
+	case StatusServerUnavailable:
+		return http.StatusServiceUnavailable
+	case StatusCGIError, StatusProxyError:
+		return http.StatusBadGateway
+	case StatusSlowDown:
+		return http.StatusTooManyRequests
+	case StatusNotFound:
+		return http.StatusNotFound
+	case StatusGone:
+		return http.StatusGone
+	case StatusProxyRequestRefused:
+		return http.StatusMisdirectedRequest
+	case StatusBadRequest:
+		return http.StatusBadRequest
+	case StatusClientCertificateRequired:
+		return http.StatusUnauthorized
+	case StatusClientCertificateNotAuthorized, StatusClientCertificateNotValid:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+const httpPageHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+</head>
+<body>
+`
+
+const httpPageFooter = `</body>
+</html>
+`
+
+const httpInputForm = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+</head>
+<body>
+<form method="get">
+<label>%s <input type="%s" name="%s" autofocus></label>
+<button type="submit">Submit</button>
+</form>
+</body>
+</html>
+`
@@ -0,0 +1,58 @@
+package gemproto_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	t.Parallel()
+
+	mux := gemproto.NewServeMux()
+	mux.HandleFunc("/index.gmi", func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		fmt.Fprint(w, "# Hello\n=> /name.gmi Name\n")
+	})
+	mux.Handle("/name.gmi", gemproto.Input("your name?")(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		name, _ := r.GetInput()
+		w.WriteHeader(gemproto.StatusOK, "text/plain")
+		fmt.Fprint(w, "hello ", name)
+	})))
+	mux.Handle("/old.gmi", gemproto.RedirectHandler("/index.gmi", gemproto.StatusPermanentRedirect))
+	mux.Handle("/away.gmi", gemproto.RedirectHandler("gemini://other.example/index.gmi", gemproto.StatusTemporaryRedirect))
+
+	h := gemproto.HTTPHandler(mux)
+
+	for _, testcase := range []struct {
+		URL         string
+		Code        int
+		ContentType string
+		Contains    string
+	}{
+		{"/index.gmi", http.StatusOK, "text/html; charset=utf-8", "<h1>Hello</h1>\n<p><a href=\"/name.gmi\">Name</a></p>"},
+		{"/name.gmi", http.StatusOK, "text/html; charset=utf-8", `name="input"`},
+		{"/name.gmi?input=the+gopher", http.StatusOK, "text/plain", "hello the gopher"},
+		{"/old.gmi", http.StatusMovedPermanently, "", ""},
+		{"/missing", http.StatusNotFound, "text/plain; charset=utf-8", "Not Found"},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+testcase.URL, nil))
+		require.Equal(t, testcase.Code, w.Code, testcase.URL)
+		require.Equal(t, testcase.ContentType, w.Header().Get("Content-Type"), testcase.URL)
+		require.True(t, strings.Contains(w.Body.String(), testcase.Contains), testcase.URL, w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/old.gmi", nil))
+	require.Equal(t, "/index.gmi", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/away.gmi", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "gemini://other.example/index.gmi", w.Header().Get("Location"))
+}
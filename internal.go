@@ -9,10 +9,16 @@ import (
 	"strings"
 )
 
-var errHeaderLineTooLong = errors.New("gemproto: header line too long")
+// ErrHeaderTooLong is returned when a request or response header line
+// exceeds the maximum length allowed by the protocol.
+var ErrHeaderTooLong = errors.New("gemproto: header line too long")
 
 func readHeaderLine(r io.Reader, maxlen int) (string, error) {
-	var buf [2048]byte
+	var arr [2048]byte
+	buf := arr[:]
+	if maxlen > len(buf) {
+		buf = make([]byte, maxlen)
+	}
 
 	for i := 0; i < maxlen; i++ {
 		if _, err := r.Read(buf[i : i+1]); err != nil {
@@ -24,7 +30,7 @@ func readHeaderLine(r io.Reader, maxlen int) (string, error) {
 		}
 	}
 
-	return "", errHeaderLineTooLong
+	return "", ErrHeaderTooLong
 }
 
 // absoluteURL makes the url path absolute by combining
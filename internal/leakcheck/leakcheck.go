@@ -0,0 +1,21 @@
+// Package leakcheck counts open resources so that tests can detect leaks.
+package leakcheck
+
+import "sync/atomic"
+
+var openBodies int64
+
+// AcquireBody records that a response body was opened.
+func AcquireBody() {
+	atomic.AddInt64(&openBodies, 1)
+}
+
+// ReleaseBody records that a response body was closed.
+func ReleaseBody() {
+	atomic.AddInt64(&openBodies, -1)
+}
+
+// OpenBodies returns the number of response bodies that have not been closed.
+func OpenBodies() int64 {
+	return atomic.LoadInt64(&openBodies)
+}
@@ -1,8 +1,12 @@
 package gemproto
 
 import (
+	"context"
+	"log"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestReadHeaderLine(t *testing.T) {
@@ -58,3 +62,31 @@ func TestReadHeaderLine(t *testing.T) {
 		})
 	}
 }
+
+func TestAnalyticsPersist(t *testing.T) {
+	t.Parallel()
+
+	name := filepath.Join(t.TempDir(), "missing", "analytics.json")
+
+	var logs strings.Builder
+	logger := log.New(&logs, "", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tick := make(chan time.Time)
+	done := make(chan error, 1)
+
+	var a Analytics
+	go func() { done <- a.persist(ctx, name, tick, logger) }()
+
+	// failed saves are logged and persisting continues
+	tick <- time.Now()
+	tick <- time.Now()
+	tick <- time.Now()
+	cancel()
+
+	if err := <-done; err == nil {
+		t.Fatal("expected the final save to fail")
+	} else if n := strings.Count(logs.String(), "\n"); n != 3 {
+		t.Fatalf("expected 3 logged errors, got %d: %q", n, logs.String())
+	}
+}
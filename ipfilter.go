@@ -0,0 +1,76 @@
+package gemproto
+
+import "net/netip"
+
+// IPFilter allows or denies requests based on the IP address of the client.
+//
+//	f := gemproto.IPFilter{
+//	  Allow: []netip.Prefix{netip.MustParsePrefix("192.168.0.0/16")},
+//	  Deny:  []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")},
+//	}
+//	srv.Middleware = append(srv.Middleware, f.Middleware)
+type IPFilter struct {
+	// Allow lists the address ranges that are allowed.
+	// All addresses are allowed if it is empty.
+	Allow []netip.Prefix
+
+	// Deny lists the address ranges that are denied.
+	// It takes precedence over Allow.
+	Deny []netip.Prefix
+
+	// Drop closes the connection of denied clients without a response
+	// if the ResponseWriter implements Hijacker.
+	// Otherwise denied clients are answered with 50 PERMANENT FAILURE.
+	Drop bool
+}
+
+// Allowed reports whether the client at remoteAddr is allowed.
+// Addresses that are not IP addresses, such as those of
+// unix sockets, are only allowed if Allow is empty.
+func (f *IPFilter) Allowed(remoteAddr string) bool {
+	host, _ := splitHostPort(remoteAddr)
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return len(f.Allow) == 0
+	}
+	addr = addr.Unmap()
+
+	for _, p := range f.Deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+
+	if len(f.Allow) == 0 {
+		return true
+	}
+
+	for _, p := range f.Allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Middleware filters the requests served by next.
+func (f *IPFilter) Middleware(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if f.Allowed(r.RemoteAddr) {
+			next.ServeGemini(w, r)
+			return
+		}
+
+		if f.Drop {
+			// a status code lower than 10 suppresses the header
+			w.WriteHeader(0, "")
+			if conn, err := hijack(w); err == nil {
+				conn.Close()
+				return
+			}
+		}
+
+		w.WriteHeader(StatusPermanentFailure, "Access Denied")
+	})
+}
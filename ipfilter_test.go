@@ -0,0 +1,62 @@
+package gemproto_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestIPFilter(t *testing.T) {
+	t.Parallel()
+
+	f := gemproto.IPFilter{
+		Allow: []netip.Prefix{
+			netip.MustParsePrefix("192.168.0.0/16"),
+			netip.MustParsePrefix("::1/128"),
+		},
+		Deny: []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")},
+	}
+
+	h := f.Middleware(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+
+	serve := func(remoteAddr string) int {
+		r := gemtest.NewRequest("/")
+		r.RemoteAddr = remoteAddr
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, gemproto.StatusOK, serve("192.168.2.1:1965"))
+	require.Equal(t, gemproto.StatusOK, serve("[::ffff:192.168.2.1]:1965"))
+	require.Equal(t, gemproto.StatusOK, serve("[::1]:1965"))
+	require.Equal(t, gemproto.StatusPermanentFailure, serve("192.168.1.1:1965"))
+	require.Equal(t, gemproto.StatusPermanentFailure, serve("10.0.0.1:1965"))
+	require.Equal(t, gemproto.StatusPermanentFailure, serve("@"))
+
+	open := gemproto.IPFilter{Deny: f.Deny}
+	require.True(t, open.Allowed("10.0.0.1:1965"))
+	require.True(t, open.Allowed("@"))
+	require.True(t, !open.Allowed("192.168.1.1"))
+}
+
+func TestIPFilterDrop(t *testing.T) {
+	t.Parallel()
+
+	f := gemproto.IPFilter{
+		Deny: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+		Drop: true,
+	}
+
+	s := gemproto.Server{
+		Insecure:   true,
+		Handler:    gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}),
+		Middleware: []func(gemproto.Handler) gemproto.Handler{f.Middleware},
+	}
+
+	addr, _ := serveLoopback(t, &s)
+	require.Equal(t, "", request(t, addr, "/"))
+}
@@ -0,0 +1,186 @@
+package gemproto
+
+import (
+	"container/list"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/askeladdk/gemproto/gemcert"
+)
+
+// LanguageNegotiator is middleware that redirects requests to language
+// variants of files that are named by path convention, such as
+// index.en.gmi and index.de.gmi for index.gmi. It is intended to be
+// placed in front of a FileServer of the same Root:
+//
+//	ln := gemproto.LanguageNegotiator{
+//	  Root:      root,
+//	  Languages: []string{"en", "de"},
+//	}
+//	mux.Handle("/", ln.Middleware(gemproto.FileServer(root, 0)))
+//
+// The language is selected by the lang query parameter, as in
+// /index.gmi?lang=de. Clients that present a certificate have their
+// selection remembered, so that subsequent requests without the
+// parameter are redirected to the same language. Requests are
+// redirected to the first language if neither the requested file
+// nor the variant of the selected language exists.
+//
+// Requests are redirected with 31 PERMANENT REDIRECT
+// if the variant exists and are passed to the next handler otherwise.
+type LanguageNegotiator struct {
+	// Root is the file system that is checked for variants.
+	Root fs.FS
+
+	// Languages lists the supported language tags.
+	// The first language is the default.
+	Languages []string
+
+	// Param is the name of the query parameter that selects the language.
+	// Defaults to "lang".
+	Param string
+
+	// MaxPreferences is the maximum number of remembered selections.
+	// The least recently used selection is forgotten when it is exceeded.
+	// Defaults to 10000.
+	MaxPreferences int
+
+	mu    sync.Mutex
+	prefs map[string]*list.Element
+	lru   list.List
+}
+
+type langPreference struct {
+	fingerprint string
+	lang        string
+}
+
+// Preference returns the language that the client with the
+// certificate fingerprint, as computed by gemcert.Fingerprint, last selected.
+func (ln *LanguageNegotiator) Preference(fingerprint string) (string, bool) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	e, ok := ln.prefs[fingerprint]
+	if !ok {
+		return "", false
+	}
+	ln.lru.MoveToFront(e)
+	return e.Value.(*langPreference).lang, true
+}
+
+func (ln *LanguageNegotiator) setPreference(fingerprint, lang string) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if e, ok := ln.prefs[fingerprint]; ok {
+		e.Value.(*langPreference).lang = lang
+		ln.lru.MoveToFront(e)
+		return
+	}
+
+	if ln.prefs == nil {
+		ln.prefs = make(map[string]*list.Element)
+	}
+
+	ln.prefs[fingerprint] = ln.lru.PushFront(&langPreference{fingerprint, lang})
+
+	max := ln.MaxPreferences
+	if max <= 0 {
+		max = 10000
+	}
+
+	for ln.lru.Len() > max {
+		e := ln.lru.Back()
+		ln.lru.Remove(e)
+		delete(ln.prefs, e.Value.(*langPreference).fingerprint)
+	}
+}
+
+func (ln *LanguageNegotiator) supported(lang string) bool {
+	for _, l := range ln.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware redirects requests to next to the negotiated language variant.
+func (ln *LanguageNegotiator) Middleware(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if target, ok := ln.negotiate(r); ok {
+			Redirect(w, r, target, StatusPermanentRedirect)
+			return
+		}
+		next.ServeGemini(w, r)
+	})
+}
+
+// negotiate returns the path of the language variant,
+// relative to the directory of the request, to redirect to.
+func (ln *LanguageNegotiator) negotiate(r *Request) (string, bool) {
+	if len(ln.Languages) == 0 {
+		return "", false
+	}
+
+	param := ln.Param
+	if param == "" {
+		param = "lang"
+	}
+
+	var fingerprint string
+	if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 {
+		fingerprint = gemcert.Fingerprint(r.TLS.PeerCertificates[0])
+	}
+
+	// an explicit selection takes precedence and is remembered
+	lang, explicit := "", false
+	if values, err := url.ParseQuery(r.URL.RawQuery); err == nil {
+		if l := values.Get(param); ln.supported(l) {
+			lang, explicit = l, true
+			if fingerprint != "" {
+				ln.setPreference(fingerprint, lang)
+			}
+		}
+	}
+
+	name := strings.TrimPrefix(cleanPath(r.URL.Path), "/")
+	if name == "" || strings.HasSuffix(name, "/") {
+		name += "index.gmi"
+	}
+
+	dir, file := path.Split(name)
+	ext := path.Ext(file)
+	stem := strings.TrimSuffix(file, ext)
+
+	// requests for a variant are only redirected to select another language
+	if l := path.Ext(stem); l != "" && ln.supported(l[1:]) {
+		if !explicit || l[1:] == lang {
+			return "", false
+		}
+		stem = strings.TrimSuffix(stem, l)
+	} else if !explicit && fingerprint != "" {
+		lang, _ = ln.Preference(fingerprint)
+	}
+
+	if lang != "" {
+		if variant := stem + "." + lang + ext; ln.exists(dir + variant) {
+			return variant, true
+		}
+	}
+
+	// fall back to the default language if the file has no plain version
+	if variant := stem + "." + ln.Languages[0] + ext; !ln.exists(name) && ln.exists(dir+variant) {
+		return variant, true
+	}
+
+	return "", false
+}
+
+func (ln *LanguageNegotiator) exists(name string) bool {
+	_, err := fs.Stat(ln.Root, name)
+	return err == nil
+}
@@ -0,0 +1,111 @@
+package gemproto_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"testing/fstest"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestLanguageNegotiator(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"index.en.gmi":      {Data: []byte("hello")},
+		"index.de.gmi":      {Data: []byte("hallo")},
+		"about.gmi":         {Data: []byte("about")},
+		"about.de.gmi":      {Data: []byte("über")},
+		"docs/index.en.gmi": {Data: []byte("docs")},
+	}
+
+	ln := gemproto.LanguageNegotiator{
+		Root:      fsys,
+		Languages: []string{"en", "de"},
+	}
+
+	h := ln.Middleware(gemproto.FileServer(fsys, 0))
+
+	serve := func(rawURL string, withCert bool) *gemtest.ResponseRecorder {
+		r := gemtest.NewRequest(rawURL)
+		if withCert {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+		}
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	for _, testcase := range []struct {
+		URL      string
+		WithCert bool
+		Code     int
+		Want     string
+	}{
+		{"gemini://localhost/", false, 31, "gemini://localhost/index.en.gmi"},
+		{"gemini://localhost/docs/", false, 31, "gemini://localhost/docs/index.en.gmi"},
+		{"gemini://localhost/about.gmi", false, 20, "about"},
+		{"gemini://localhost/index.en.gmi", false, 20, "hello"},
+		{"gemini://localhost/about.gmi?lang=de", false, 31, "gemini://localhost/about.de.gmi"},
+		{"gemini://localhost/about.gmi?lang=fr", false, 20, "about"},
+		{"gemini://localhost/index.en.gmi?lang=de", false, 31, "gemini://localhost/index.de.gmi"},
+		{"gemini://localhost/about.gmi", true, 20, "about"},
+		{"gemini://localhost/?lang=de", true, 31, "gemini://localhost/index.de.gmi"},
+		{"gemini://localhost/about.gmi", true, 31, "gemini://localhost/about.de.gmi"},
+		{"gemini://localhost/about.de.gmi", true, 20, "über"},
+		{"gemini://localhost/docs/", true, 31, "gemini://localhost/docs/index.en.gmi"},
+	} {
+		w := serve(testcase.URL, testcase.WithCert)
+		require.Equal(t, testcase.Code, w.Code, testcase.URL)
+		if w.Code == gemproto.StatusOK {
+			require.Equal(t, testcase.Want, w.Body.String(), testcase.URL)
+		} else {
+			require.Equal(t, testcase.Want, w.Meta, testcase.URL)
+		}
+	}
+
+	lang, ok := ln.Preference(gemcert.Fingerprint(cert.Leaf))
+	require.True(t, ok)
+	require.Equal(t, "de", lang)
+}
+
+func TestLanguageNegotiatorMaxPreferences(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"index.en.gmi": {Data: []byte("hello")},
+		"index.de.gmi": {Data: []byte("hallo")},
+	}
+
+	ln := gemproto.LanguageNegotiator{
+		Root:           fsys,
+		Languages:      []string{"en", "de"},
+		MaxPreferences: 1,
+	}
+
+	h := ln.Middleware(gemproto.FileServer(fsys, 0))
+
+	var fingerprints []string
+	for i := 0; i < 2; i++ {
+		cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+		require.NoError(t, err)
+		fingerprints = append(fingerprints, gemcert.Fingerprint(cert.Leaf))
+
+		r := gemtest.NewRequest("gemini://localhost/?lang=de")
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+		h.ServeGemini(gemtest.NewRecorder(), r)
+	}
+
+	_, ok := ln.Preference(fingerprints[0])
+	require.True(t, !ok, "oldest preference is forgotten")
+	lang, ok := ln.Preference(fingerprints[1])
+	require.True(t, ok)
+	require.Equal(t, "de", lang)
+}
@@ -0,0 +1,132 @@
+package gemproto
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Maintenance is a middleware that answers requests
+// with 41 SERVER UNAVAILABLE while maintenance is in progress.
+// Maintenance is either toggled at runtime with Enable and Disable
+// or scheduled ahead of time with Schedule.
+//
+//	maint := &gemproto.Maintenance{
+//	  Message: "Upgrading the capsule",
+//	  Allow:   []string{adminFingerprint},
+//	}
+//	srv.Handler = maint.Middleware(mux)
+//	// ...
+//	maint.Enable()
+//
+// Maintenance is safe to use concurrently.
+type Maintenance struct {
+	// Message is sent as the response meta.
+	// Defaults to "Server Unavailable".
+	// A retry hint is appended if the end of maintenance is known.
+	Message string
+
+	// Prefixes restricts maintenance to URL paths with any of these prefixes.
+	// All paths are affected if it is empty.
+	Prefixes []string
+
+	// Allow lets clients with a certificate fingerprint in this list
+	// through during maintenance.
+	Allow []string
+
+	enabled int32
+	windows []maintenanceWindow
+	mu      sync.RWMutex
+}
+
+type maintenanceWindow struct {
+	start, end time.Time
+}
+
+// Enable starts maintenance until Disable is called.
+func (m *Maintenance) Enable() {
+	atomic.StoreInt32(&m.enabled, 1)
+}
+
+// Disable ends maintenance started by Enable.
+// Scheduled maintenance windows are not affected.
+func (m *Maintenance) Disable() {
+	atomic.StoreInt32(&m.enabled, 0)
+}
+
+// Schedule schedules a maintenance window between start and end.
+func (m *Maintenance) Schedule(start, end time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// forget windows that have passed
+	now := time.Now()
+	windows := m.windows[:0]
+	for _, w := range m.windows {
+		if now.Before(w.end) {
+			windows = append(windows, w)
+		}
+	}
+
+	m.windows = append(windows, maintenanceWindow{start, end})
+}
+
+// Active reports whether maintenance is in progress at time t.
+// If maintenance was scheduled, it also returns the end of the window.
+func (m *Maintenance) Active(t time.Time) (active bool, end time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, w := range m.windows {
+		if !t.Before(w.start) && t.Before(w.end) {
+			active = true
+			if w.end.After(end) {
+				end = w.end
+			}
+		}
+	}
+
+	if atomic.LoadInt32(&m.enabled) == 1 {
+		return true, time.Time{}
+	}
+
+	return active, end
+}
+
+func (m *Maintenance) affects(r *Request) bool {
+	if len(m.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range m.Prefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Middleware answers requests to next with 41 SERVER UNAVAILABLE
+// while maintenance is in progress.
+func (m *Maintenance) Middleware(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		active, end := m.Active(time.Now())
+		if !active || !m.affects(r) || authorizedFingerprint(r.TLS, m.Allow) {
+			next.ServeGemini(w, r)
+			return
+		}
+
+		meta := m.Message
+		if meta == "" {
+			meta = "Server Unavailable"
+		}
+
+		if !end.IsZero() {
+			meta += "; retry after " + end.UTC().Format(time.RFC3339)
+		}
+
+		w.WriteHeader(StatusServerUnavailable, meta)
+	})
+}
@@ -0,0 +1,53 @@
+package gemproto_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+	require.NoError(t, err)
+
+	maint := gemproto.Maintenance{
+		Prefixes: []string{"/app/"},
+		Allow:    []string{gemcert.Fingerprint(cert.Leaf)},
+	}
+
+	h := maint.Middleware(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+
+	serve := func(path string, withCert bool) *gemtest.ResponseRecorder {
+		r := gemtest.NewRequest(path)
+		if withCert {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+		}
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	require.Equal(t, gemproto.StatusOK, serve("/app/", false).Code)
+
+	maint.Enable()
+	w := serve("/app/", false)
+	require.Equal(t, gemproto.StatusServerUnavailable, w.Code)
+	require.Equal(t, "Server Unavailable", w.Meta)
+	require.Equal(t, gemproto.StatusOK, serve("/index.gmi", false).Code)
+	require.Equal(t, gemproto.StatusOK, serve("/app/", true).Code)
+	maint.Disable()
+
+	end := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	maint.Schedule(time.Now().Add(-time.Minute), end)
+	w = serve("/app/", false)
+	require.Equal(t, gemproto.StatusServerUnavailable, w.Code)
+	require.Equal(t, "Server Unavailable; retry after 2100-01-01T00:00:00Z", w.Meta)
+}
@@ -0,0 +1,123 @@
+package gemproto
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Navigation is the data passed to FileServerOptions.Navigation.
+// All links are relative to the directory being served,
+// so that they remain valid when the file server is mounted.
+type Navigation struct {
+	// Path is the path of the directory relative to the file server root,
+	// such as / or /gemlog/.
+	Path string
+
+	// Parent links to the parent directory.
+	// It is empty at the root.
+	Parent string
+
+	// Siblings are the directories next to the current one,
+	// including the current one.
+	Siblings []NavLink
+
+	// Children are the subdirectories of the current directory.
+	Children []NavLink
+}
+
+// NavLink is a link to a directory in a Navigation.
+type NavLink struct {
+	// Name is the name of the directory.
+	Name string
+
+	// Link is the relative link to the directory.
+	Link string
+
+	// Current is set if the link is to the directory being served.
+	Current bool
+}
+
+// DefaultNavigation is a navigation template that links to the parent,
+// the sibling and the child directories, followed by a blank line.
+var DefaultNavigation = template.Must(template.New("nav").Parse(
+	`{{if .Parent}}=> {{.Parent}} ..
+{{end}}{{range .Siblings}}{{if not .Current}}=> {{.Link}} {{.Name}}/
+{{end}}{{end}}{{range .Children}}=> {{.Link}} {{.Name}}/
+{{end}}
+`))
+
+// navigation renders the navigation of the rooted directory name.
+func (fsrv fileServer) navigation(fsys fs.FS, name string) ([]byte, error) {
+	dir := strings.TrimSuffix(name, "/") + "/"
+
+	nav := Navigation{Path: dir}
+
+	children, err := fsrv.subdirs(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range children {
+		nav.Children = append(nav.Children, NavLink{Name: child, Link: child + "/"})
+	}
+
+	if dir != "/" {
+		nav.Parent = "../"
+
+		parent := path.Dir(strings.TrimSuffix(dir, "/"))
+		siblings, err := fsrv.subdirs(fsys, parent)
+		if err != nil {
+			return nil, err
+		}
+
+		current := path.Base(dir)
+		for _, sibling := range siblings {
+			nav.Siblings = append(nav.Siblings, NavLink{
+				Name:    sibling,
+				Link:    "../" + sibling + "/",
+				Current: sibling == current,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := fsrv.Nav.Execute(&buf, nav); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// subdirs returns the sorted names of the visible subdirectories of dir.
+func (fsrv fileServer) subdirs(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, path.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() {
+			continue
+		} else if fsrv.Flags&ShowHiddenFiles == 0 && strings.HasPrefix(name, ".") {
+			continue
+		} else if fsrv.denied(path.Join(dir, name), true) {
+			continue
+		} else if fsrv.Flags&HideScheduled != 0 {
+			if fi, err := e.Info(); err != nil || scheduled(name, fi.ModTime(), now) {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,109 @@
+package gemproto
+
+import (
+	"net/url"
+	"strings"
+)
+
+// NormalizeOptions selects the fixes applied by Normalize.
+// The zero value applies no fixes.
+type NormalizeOptions struct {
+	// Scheme adds the gemini scheme to requests that omit it,
+	// such as example.com/path or //example.com/path.
+	Scheme bool
+
+	// QuerySpaces percent-encodes spaces that were sent unencoded in the query.
+	QuerySpaces bool
+
+	// DoubleSlashes collapses repeated slashes in the path.
+	// ServeMux would otherwise redirect to the canonical path,
+	// which some clients do not follow.
+	DoubleSlashes bool
+}
+
+// LegacyClientFixes enables all fixes of Normalize.
+var LegacyClientFixes = NormalizeOptions{
+	Scheme:        true,
+	QuerySpaces:   true,
+	DoubleSlashes: true,
+}
+
+// Normalize returns middleware that rewrites the request URL
+// to fix common quirks of old or buggy clients before routing,
+// so that content remains reachable from such clients.
+// Requests that need no fixing are passed through unchanged.
+func Normalize(opts NormalizeOptions) func(Handler) Handler {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			if u := normalizeURL(r, opts); u != nil {
+				r2 := new(Request)
+				*r2 = *r
+				r2.URL = u
+				r = r2
+			}
+			next.ServeGemini(w, r)
+		})
+	}
+}
+
+// normalizeURL returns the fixed URL of r or nil if it needs no fixing.
+func normalizeURL(r *Request, opts NormalizeOptions) *url.URL {
+	u := *r.URL
+	changed := false
+
+	if opts.Scheme {
+		if u.Scheme == "" && u.Host != "" {
+			u.Scheme, changed = "gemini", true
+		} else if fixed := fixMissingScheme(r.RequestURI); fixed != nil {
+			u, changed = *fixed, true
+		}
+	}
+
+	if opts.QuerySpaces && strings.Contains(u.RawQuery, " ") {
+		u.RawQuery, changed = strings.ReplaceAll(u.RawQuery, " ", "%20"), true
+	}
+
+	if opts.DoubleSlashes && strings.Contains(u.Path, "//") {
+		u.Path, changed = collapseSlashes(u.Path), true
+		if u.RawPath != "" {
+			u.RawPath = collapseSlashes(u.RawPath)
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return &u
+}
+
+// fixMissingScheme parses a raw request URL that has a host but no scheme.
+// It returns nil if the request URL has a scheme or is only a path.
+func fixMissingScheme(rawURL string) *url.URL {
+	if rawURL == "" || strings.Contains(rawURL, "://") {
+		return nil
+	} else if strings.HasPrefix(rawURL, "//") {
+		rawURL = "gemini:" + rawURL
+	} else if rawURL[0] != '/' {
+		rawURL = "gemini://" + rawURL
+	} else {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return u
+}
+
+func collapseSlashes(p string) string {
+	var sb strings.Builder
+	sb.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		sb.WriteByte(p[i])
+	}
+	return sb.String()
+}
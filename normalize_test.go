@@ -0,0 +1,47 @@
+package gemproto_test
+
+import (
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	mux := gemproto.NewServeMux()
+	mux.HandleFunc("/a/b", func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = gemproto.WriteString(w, r.URL.String())
+	})
+
+	serve := func(opts gemproto.NormalizeOptions, requestURI string, url string) *gemtest.ResponseRecorder {
+		r := gemtest.NewRequest(url)
+		r.RequestURI = requestURI
+		w := gemtest.NewRecorder()
+		gemproto.Normalize(opts)(mux).ServeGemini(w, r)
+		return w
+	}
+
+	for _, testcase := range []struct {
+		Name       string
+		RequestURI string
+		URL        string
+		Code       int
+		Body       string
+	}{
+		{"unchanged", "gemini://localhost/a/b?x", "gemini://localhost/a/b?x", 20, "gemini://localhost/a/b?x"},
+		{"no scheme", "localhost/a/b", "gemini://localhost/localhost/a/b", 20, "gemini://localhost/a/b"},
+		{"no scheme slashes", "//localhost/a/b", "gemini://localhost/a/b", 20, "gemini://localhost/a/b"},
+		{"query spaces", "gemini://localhost/a/b?x y", "gemini://localhost/a/b?x y", 20, "gemini://localhost/a/b?x%20y"},
+		{"double slashes", "gemini://localhost//a//b", "gemini://localhost//a//b", 20, "gemini://localhost/a/b"},
+	} {
+		w := serve(gemproto.LegacyClientFixes, testcase.RequestURI, testcase.URL)
+		require.Equal(t, testcase.Code, w.Code, testcase.Name)
+		require.Equal(t, testcase.Body, w.Body.String(), testcase.Name)
+	}
+
+	w := serve(gemproto.NormalizeOptions{}, "gemini://localhost//a//b", "gemini://localhost//a//b")
+	require.Equal(t, gemproto.StatusPermanentRedirect, w.Code)
+}
@@ -0,0 +1,86 @@
+package gemproto
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+	"time"
+)
+
+// PingResult is the outcome of Ping.
+type PingResult struct {
+	// URL is the URL that was requested.
+	URL string
+
+	// Latency is the time elapsed between dialing the host
+	// and receiving the response header.
+	Latency time.Duration
+
+	// StatusCode is the status code of the response.
+	StatusCode int
+
+	// Meta is the meta of the response.
+	Meta string
+
+	// Certificate is the certificate presented by the host.
+	Certificate *x509.Certificate
+}
+
+// Expiry returns the expiry time of the host certificate.
+func (p PingResult) Expiry() time.Time {
+	if p.Certificate == nil {
+		return time.Time{}
+	}
+	return p.Certificate.NotAfter
+}
+
+// Ping checks the health of a host by performing a TLS handshake and
+// requesting its root page. The body of the response is not read.
+// It is intended to be used by uptime monitors.
+//
+// The addr is a host name with an optional port, or a gemini URL.
+// Redirects are not followed and reported as the status code.
+// The host certificate is not verified, but returned in the result
+// so that monitors can check it, for example to warn about expiry.
+// Use ctx to limit the duration of the ping.
+func Ping(ctx context.Context, addr string) (PingResult, error) {
+	rawURL := addr
+	if !strings.HasPrefix(addr, "gemini://") {
+		rawURL = "gemini://" + addr + "/"
+	}
+
+	result := PingResult{URL: rawURL}
+
+	req, err := NewRequestWithContext(ctx, rawURL)
+	if err != nil {
+		return result, err
+	}
+
+	client := Client{
+		CheckRedirect: func(*Request, []*Request) error {
+			return ErrUseLastResponse
+		},
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		client.ReadTimeout, client.WriteTimeout = timeout, timeout
+	}
+
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+
+	result.Latency = time.Since(start)
+	result.StatusCode = res.StatusCode
+	result.Meta = res.Meta
+
+	if res.TLS != nil && len(res.TLS.PeerCertificates) != 0 {
+		result.Certificate = res.TLS.PeerCertificates[0]
+	}
+
+	return result, nil
+}
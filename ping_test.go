@@ -0,0 +1,32 @@
+package gemproto_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	server := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		require.Equal(t, "/", r.URL.Path)
+		gemproto.Redirect(w, r, "/home", gemproto.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := gemproto.Ping(ctx, strings.TrimPrefix(server.URL, "gemini://"))
+	require.NoError(t, err)
+	require.Equal(t, server.URL+"/", result.URL)
+	require.Equal(t, gemproto.StatusTemporaryRedirect, result.StatusCode)
+	require.True(t, result.Latency > 0)
+	require.Equal(t, server.Certificate.Leaf.NotAfter, result.Expiry())
+}
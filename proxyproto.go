@@ -0,0 +1,155 @@
+package gemproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidProxyHeader is returned when reading from a connection
+// that did not start with a valid PROXY protocol header.
+var ErrInvalidProxyHeader = errors.New("gemproto: invalid PROXY protocol header")
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+type proxyListener struct {
+	net.Listener
+}
+
+func (l proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// proxyConn reads the PROXY protocol header on the first Read.
+// The header is not read in Accept so that slow relays
+// cannot block the accept loop.
+type proxyConn struct {
+	net.Conn
+	r          *bufio.Reader
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	mu         sync.Mutex
+}
+
+func (c *proxyConn) Read(p []byte) (int, error) {
+	c.once.Do(func() {
+		addr, err := readProxyHeader(c.r)
+		c.mu.Lock()
+		c.remoteAddr, c.err = addr, err
+		c.mu.Unlock()
+	})
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.r.Read(p)
+}
+
+// RemoteAddr returns the client address from the PROXY header
+// once it has been read, and the address of the relay otherwise.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads a PROXY protocol version 1 or 2 header.
+// It returns a nil address if the header does not carry the client address,
+// such as for health checks sent by the relay itself.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err == nil && bytes.Equal(sig, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	} else if len(sig) >= 6 && string(sig[:6]) == "PROXY " {
+		return readProxyHeaderV1(r)
+	} else if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return nil, ErrInvalidProxyHeader
+}
+
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	// the longest v1 header is 107 bytes including the CRLF
+	line, err := readHeaderLine(r, 107)
+	if errors.Is(err, ErrHeaderTooLong) {
+		return nil, ErrInvalidProxyHeader
+	} else if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	} else if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	version, command := hdr[12]>>4, hdr[12]&0xf
+	family := hdr[13]
+	length := int(binary.BigEndian.Uint16(hdr[14:]))
+
+	if version != 2 || command > 1 {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	// LOCAL connections are established by the relay itself
+	if command == 0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if length < 12 {
+			return nil, ErrInvalidProxyHeader
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:4]),
+			Port: int(binary.BigEndian.Uint16(payload[8:10])),
+		}, nil
+	case 0x21: // TCP over IPv6
+		if length < 36 {
+			return nil, ErrInvalidProxyHeader
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:16]),
+			Port: int(binary.BigEndian.Uint16(payload[32:34])),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,62 @@
+package gemproto_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestServerProxyProtocol(t *testing.T) {
+	t.Parallel()
+
+	s := gemproto.Server{
+		Insecure:      true,
+		ProxyProtocol: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = w.Write([]byte(r.RemoteAddr))
+		}),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	roundTrip := func(header string) string {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte(header + "gemini://localhost/\r\n"))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(conn)
+		return string(body)
+	}
+
+	const header = "20 text/gemini;charset=utf-8\r\n"
+
+	v1 := "PROXY TCP4 192.0.2.1 198.51.100.1 56324 1965\r\n"
+	require.Equal(t, header+"192.0.2.1:56324", roundTrip(v1))
+
+	v1v6 := "PROXY TCP6 2001:db8::1 2001:db8::2 56324 1965\r\n"
+	require.Equal(t, header+"[2001:db8::1]:56324", roundTrip(v1v6))
+
+	v2 := "\r\n\r\n\x00\r\nQUIT\n" + "\x21\x11\x00\x0c" +
+		"\xc0\x00\x02\x01" + "\xc6\x33\x64\x01" + "\xdc\x04" + "\x07\xad"
+	require.Equal(t, header+"192.0.2.1:56324", roundTrip(v2))
+
+	// local connections keep the relay address
+	local := "\r\n\r\n\x00\r\nQUIT\n" + "\x20\x00\x00\x00"
+	body := roundTrip(local)
+	require.True(t, strings.HasPrefix(body, header+"127.0.0.1:"), body)
+
+	// connections without a header are refused
+	require.Equal(t, "", roundTrip(""))
+}
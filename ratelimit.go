@@ -0,0 +1,222 @@
+package gemproto
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemcert"
+)
+
+// RateLimitStore stores the request counters of a RateLimiter.
+// Implementations backed by shared storage allow
+// multiple server instances to enforce a common limit.
+type RateLimitStore interface {
+	// Increment counts a request of key against limit requests per window.
+	// It returns the number of requests that count against the limit,
+	// which exceeds limit if the request is refused, and the time until
+	// the count decreases.
+	Increment(key string, limit int, window time.Duration) (count int, reset time.Duration, err error)
+}
+
+type rateLimitEntry struct {
+	count   int
+	resetAt time.Time
+}
+
+// MemoryRateLimitStore is a RateLimitStore that keeps
+// fixed window counters in memory.
+//
+// MemoryRateLimitStore is safe to use concurrently.
+type MemoryRateLimitStore struct {
+	entries map[string]rateLimitEntry
+	sweepAt time.Time
+	mu      sync.Mutex
+}
+
+// NewMemoryRateLimitStore returns a new MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries: make(map[string]rateLimitEntry),
+	}
+}
+
+// Increment implements RateLimitStore.
+func (s *MemoryRateLimitStore) Increment(key string, limit int, window time.Duration) (int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	// periodically remove expired entries
+	if now.After(s.sweepAt) {
+		for k, e := range s.entries {
+			if now.After(e.resetAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.sweepAt = now.Add(window)
+	}
+
+	e, ok := s.entries[key]
+	if !ok || now.After(e.resetAt) {
+		e = rateLimitEntry{resetAt: now.Add(window)}
+	}
+
+	e.count++
+	s.entries[key] = e
+
+	return e.count, e.resetAt.Sub(now), nil
+}
+
+// RemoteIPKey returns the IP address of the client.
+// It is intended to be used as RateLimiter.Key.
+func RemoteIPKey(r *Request) string {
+	host, _ := splitHostPort(r.RemoteAddr)
+	return host
+}
+
+// CertificateKey returns the fingerprint of the client certificate
+// or the IP address of the client if it did not present a certificate.
+// It is intended to be used as RateLimiter.Key to throttle
+// authenticated users individually rather than per network address.
+func CertificateKey(r *Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 {
+		return "cert:" + gemcert.Fingerprint(r.TLS.PeerCertificates[0])
+	}
+	return "ip:" + RemoteIPKey(r)
+}
+
+// RateLimiter limits the number of requests per client within a time window.
+// Clients exceeding the limit are answered with 44 SLOW DOWN
+// and the number of seconds to wait. Use separate RateLimiters
+// to configure limits per route:
+//
+//	search := &gemproto.RateLimiter{
+//	  Limit:  2,
+//	  Window: 4 * time.Second,
+//	  Store:  gemproto.NewTokenBucketStore(),
+//	}
+//	mux.Handle("/search", search.Middleware(searchHandler))
+type RateLimiter struct {
+	// Limit is the maximum number of requests per window.
+	Limit int
+
+	// Window is the duration of the time window.
+	Window time.Duration
+
+	// Key identifies the client of a request.
+	// Defaults to RemoteIPKey.
+	Key func(*Request) string
+
+	// Store stores the request counters and determines the algorithm.
+	// Defaults to a MemoryRateLimitStore, which counts requests in fixed
+	// windows. A TokenBucketStore allows bursts of Limit requests
+	// while smoothing the sustained rate to Limit requests per Window.
+	Store RateLimitStore
+
+	once sync.Once
+}
+
+// Middleware limits the requests served by next.
+// Requests are allowed if the Store returns an error.
+func (rl *RateLimiter) Middleware(next Handler) Handler {
+	rl.once.Do(func() {
+		if rl.Key == nil {
+			rl.Key = RemoteIPKey
+		}
+		if rl.Store == nil {
+			rl.Store = NewMemoryRateLimitStore()
+		}
+	})
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		count, reset, err := rl.Store.Increment(rl.Key(r), rl.Limit, rl.Window)
+		if err == nil && count > rl.Limit {
+			seconds := int64(math.Ceil(reset.Seconds()))
+			w.WriteHeader(StatusSlowDown, strconv.FormatInt(seconds, 10))
+			return
+		}
+
+		next.ServeGemini(w, r)
+	})
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// TokenBucketStore is a RateLimitStore that keeps token buckets in memory.
+// Each key has a bucket of limit tokens that refills at limit tokens
+// per window, and every request takes one token. Unlike fixed windows,
+// token buckets allow short bursts while smoothing the sustained rate.
+//
+// TokenBucketStore is safe to use concurrently.
+type TokenBucketStore struct {
+	buckets map[string]tokenBucket
+	sweepAt time.Time
+	mu      sync.Mutex
+}
+
+// NewTokenBucketStore returns a new TokenBucketStore.
+func NewTokenBucketStore() *TokenBucketStore {
+	return &TokenBucketStore{
+		buckets: make(map[string]tokenBucket),
+	}
+}
+
+// Increment implements RateLimitStore. The count is the number of tokens
+// taken from the bucket and reset is the time until the next token
+// is available if the bucket is empty.
+func (s *TokenBucketStore) Increment(key string, limit int, window time.Duration) (int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	burst := float64(limit)
+
+	var rate float64
+	if window > 0 {
+		rate = burst / window.Seconds()
+	}
+
+	// periodically remove buckets that have refilled completely
+	if now.After(s.sweepAt) {
+		for k, b := range s.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*rate >= burst {
+				delete(s.buckets, k)
+			}
+		}
+		s.sweepAt = now.Add(window)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = tokenBucket{tokens: burst, last: now}
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		s.buckets[key] = b
+		// the bucket never refills, so report the window as the wait
+		// rather than an unbounded duration
+		if rate <= 0 {
+			if window < 0 {
+				window = 0
+			}
+			return limit + 1, window, nil
+		}
+		return limit + 1, time.Duration((1 - b.tokens) / rate * float64(time.Second)), nil
+	}
+
+	b.tokens--
+	s.buckets[key] = b
+	return limit - int(b.tokens), 0, nil
+}
@@ -0,0 +1,84 @@
+package gemproto_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestRateLimiterCertificateKey(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{})
+	require.NoError(t, err)
+
+	rl := gemproto.RateLimiter{
+		Limit:  1,
+		Window: time.Minute,
+		Key:    gemproto.CertificateKey,
+	}
+
+	h := rl.Middleware(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+
+	serve := func(withCert bool) *gemtest.ResponseRecorder {
+		r := gemtest.NewRequest("/")
+		r.RemoteAddr = "127.0.0.1:1234"
+		if withCert {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+		}
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	require.Equal(t, gemproto.StatusOK, serve(false).Code)
+	w := serve(false)
+	require.Equal(t, gemproto.StatusSlowDown, w.Code)
+	require.Equal(t, "60", w.Meta)
+
+	// same address but authenticated is a different client
+	require.Equal(t, gemproto.StatusOK, serve(true).Code)
+	require.Equal(t, gemproto.StatusSlowDown, serve(true).Code)
+}
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	rl := gemproto.RateLimiter{
+		Limit:  2,
+		Window: 4 * time.Second,
+		Store:  gemproto.NewTokenBucketStore(),
+	}
+
+	h := rl.Middleware(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+
+	serve := func(addr string) *gemtest.ResponseRecorder {
+		r := gemtest.NewRequest("/")
+		r.RemoteAddr = addr
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	require.Equal(t, gemproto.StatusOK, serve("127.0.0.1:1234").Code)
+	require.Equal(t, gemproto.StatusOK, serve("127.0.0.1:1235").Code)
+
+	w := serve("127.0.0.1:1236")
+	require.Equal(t, gemproto.StatusSlowDown, w.Code)
+	require.Equal(t, "2", w.Meta)
+
+	// other clients have their own bucket
+	require.Equal(t, gemproto.StatusOK, serve("127.0.0.2:1234").Code)
+
+	// a bucket without tokens never refills but the wait is bounded
+	count, reset, err := gemproto.NewTokenBucketStore().Increment("key", 0, 4*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, 4*time.Second, reset)
+}
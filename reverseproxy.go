@@ -0,0 +1,126 @@
+package gemproto
+
+import (
+	"io"
+	"net/url"
+	"strings"
+)
+
+// proxyClient is the default Client of ReverseProxy.
+// It passes redirects on to the client instead of following them.
+var proxyClient = &Client{
+	CheckRedirect: func(req *Request, via []*Request) error {
+		return ErrUseLastResponse
+	},
+}
+
+// ReverseProxy is a Handler that forwards requests to another
+// Gemini server and streams the response back to the client,
+// analogous to httputil.ReverseProxy.
+type ReverseProxy struct {
+	// Director rewrites the outgoing request, which is a copy of
+	// the incoming request, to point at the upstream server.
+	// It must set URL.Host and should also set Host,
+	// which is the address that is dialed.
+	Director func(*Request)
+
+	// Client forwards the requests. It defaults to a Client that
+	// does not follow redirects, so that they reach the client.
+	// Custom Clients should set CheckRedirect to return ErrUseLastResponse.
+	Client *Client
+
+	// ModifyResponse is optional and modifies the upstream response
+	// before it is sent to the client. If it returns an error,
+	// the response is discarded and ErrorHandler is called.
+	ModifyResponse func(*Response) error
+
+	// ErrorHandler is optional and handles errors that occur while
+	// forwarding the request. It defaults to answering with 43 PROXY ERROR.
+	ErrorHandler func(w ResponseWriter, r *Request, err error)
+
+	// Breaker is optional and stops forwarding requests while the
+	// upstream is failing. Requests that cannot be sent or are not
+	// answered count as failures, and refused requests are passed
+	// to ErrorHandler with ErrCircuitOpen.
+	Breaker *CircuitBreaker
+}
+
+// NewSingleHostReverseProxy returns a ReverseProxy that forwards
+// requests to target. The path of target is prepended to
+// the request path and its query is used if the request has none.
+func NewSingleHostReverseProxy(target *url.URL) *ReverseProxy {
+	return &ReverseProxy{
+		Director: func(r *Request) {
+			r.URL.Scheme = "gemini"
+			r.URL.Host = target.Host
+			r.URL.Path = joinURLPath(target.Path, r.URL.Path)
+			if r.URL.RawQuery == "" && !r.URL.ForceQuery {
+				r.URL.RawQuery = target.RawQuery
+			}
+			r.Host = target.Host
+		},
+	}
+}
+
+// joinURLPath joins two URL paths with a single slash.
+func joinURLPath(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return strings.TrimSuffix(a, "/") + "/" + strings.TrimPrefix(b, "/")
+}
+
+func (p *ReverseProxy) proxyError(w ResponseWriter, r *Request, err error) {
+	if p.ErrorHandler != nil {
+		p.ErrorHandler(w, r, err)
+		return
+	}
+	w.WriteHeader(StatusProxyError, "Proxy Error")
+}
+
+// ServeGemini implements Handler.
+func (p *ReverseProxy) ServeGemini(w ResponseWriter, r *Request) {
+	u := *r.URL
+	outreq := &Request{
+		URL:  &u,
+		Host: u.Host,
+		ctx:  r.Context(),
+	}
+
+	p.Director(outreq)
+
+	client := p.Client
+	if client == nil {
+		client = proxyClient
+	}
+
+	var done func(bool)
+	if p.Breaker != nil {
+		var err error
+		if done, err = p.Breaker.Allow(); err != nil {
+			p.proxyError(w, r, err)
+			return
+		}
+	}
+
+	res, err := client.Do(outreq)
+	if done != nil {
+		done(err == nil)
+	}
+
+	if err != nil {
+		p.proxyError(w, r, err)
+		return
+	}
+	defer res.Body.Close()
+
+	if p.ModifyResponse != nil {
+		if err := p.ModifyResponse(res); err != nil {
+			p.proxyError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(res.StatusCode, res.Meta)
+	_, _ = io.Copy(w, res.Body)
+}
@@ -0,0 +1,59 @@
+package gemproto_test
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestReverseProxy(t *testing.T) {
+	t.Parallel()
+
+	upstream := gemtest.NewServer(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		switch r.URL.Path {
+		case "/app/old":
+			w.WriteHeader(gemproto.StatusPermanentRedirect, "/app/new")
+		default:
+			w.WriteHeader(gemproto.StatusOK, "text/plain")
+			_, _ = io.WriteString(w, r.URL.Path+"?"+r.URL.RawQuery)
+		}
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL + "/app")
+	require.NoError(t, err)
+
+	proxy := gemproto.NewSingleHostReverseProxy(target)
+
+	serve := func(rawURL string) *gemtest.ResponseRecorder {
+		w := gemtest.NewRecorder()
+		proxy.ServeGemini(w, gemtest.NewRequest(rawURL))
+		return w
+	}
+
+	w := serve("gemini://example.com/page?q")
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "text/plain", w.Meta)
+	require.Equal(t, "/app/page?q", w.Body.String())
+
+	// redirects are passed on to the client
+	w = serve("gemini://example.com/old")
+	require.Equal(t, gemproto.StatusPermanentRedirect, w.Code)
+	require.Equal(t, "/app/new", w.Meta)
+
+	proxy.ModifyResponse = func(res *gemproto.Response) error {
+		return errors.New("rejected")
+	}
+	require.Equal(t, gemproto.StatusProxyError, serve("gemini://example.com/").Code)
+
+	unreachable := gemproto.NewSingleHostReverseProxy(&url.URL{Host: "127.0.0.1:1"})
+	w = gemtest.NewRecorder()
+	unreachable.ServeGemini(w, gemtest.NewRequest("/"))
+	require.Equal(t, gemproto.StatusProxyError, w.Code)
+	require.Equal(t, "Proxy Error", w.Meta)
+}
@@ -8,6 +8,8 @@
 package gemproto
 
 import (
+	"errors"
+	"fmt"
 	"net/url"
 	"path"
 	"sort"
@@ -15,9 +17,78 @@ import (
 	"sync"
 )
 
+// Errors returned by ServeMux.TryHandle.
+var (
+	ErrEmptyPattern          = errors.New("gemproto: empty pattern")
+	ErrNilHandler            = errors.New("gemproto: nil handler")
+	ErrMultipleRegistrations = errors.New("gemproto: multiple registrations")
+)
+
 type muxEntry struct {
 	pattern string
 	handler Handler
+	chained Handler // handler wrapped in the middleware
+	info    RouteInfo
+}
+
+// muxNode is a node in the trie of patterns that end in a slash.
+// Each edge is a path segment including its trailing slash,
+// so that a pattern is a prefix of a path exactly when
+// the segments of the pattern are a prefix of the segments of the path.
+type muxNode struct {
+	entry    *muxEntry
+	children map[string]*muxNode
+}
+
+// insert adds e to the trie under its pattern, replacing any existing entry.
+func (n *muxNode) insert(e muxEntry) {
+	for p := e.pattern; p != ""; {
+		i := strings.IndexByte(p, '/') + 1
+		seg := p[:i]
+		p = p[i:]
+
+		child := n.children[seg]
+		if child == nil {
+			if n.children == nil {
+				n.children = make(map[string]*muxNode)
+			}
+			child = &muxNode{}
+			n.children[seg] = child
+		}
+		n = child
+	}
+	n.entry = &e
+}
+
+// lookup returns the entry with the longest pattern that is a prefix of path.
+func (n *muxNode) lookup(path string) *muxEntry {
+	var found *muxEntry
+	for {
+		if n.entry != nil {
+			found = n.entry
+		}
+		i := strings.IndexByte(path, '/') + 1
+		if i == 0 {
+			return found
+		}
+		if n = n.children[path[:i]]; n == nil {
+			return found
+		}
+		path = path[i:]
+	}
+}
+
+// RouteInfo documents a pattern registered with ServeMux.HandleWithInfo.
+type RouteInfo struct {
+	// Pattern is the registered pattern.
+	// It is set by ServeMux.
+	Pattern string
+
+	// Description is a human-readable description of the route.
+	Description string
+
+	// Hidden excludes the route from generated sitemaps.
+	Hidden bool
 }
 
 // ServeMux is an Gemini request multiplexer.
@@ -28,16 +99,23 @@ type muxEntry struct {
 // It functions just like http.ServeMux.
 type ServeMux struct {
 	exact    map[string]muxEntry
-	entries  []muxEntry
+	prefixes muxNode
 	hosts    bool
+	override bool
 	notFound Handler
+	chained  Handler // notFound wrapped in the middleware
+	defCode  int
+	defMeta  string
+	mws      []func(Handler) Handler
 	mu       sync.RWMutex
 }
 
 // NewServeMux returns a fresh ServeMux.
 func NewServeMux() *ServeMux {
+	notFound := HandlerFunc(NotFound)
 	return &ServeMux{
-		notFound: HandlerFunc(NotFound),
+		notFound: notFound,
+		chained:  notFound,
 	}
 }
 
@@ -55,8 +133,15 @@ func NewServeMux() *ServeMux {
 // If there is no registered handler that applies to the request,
 // Handler returns the handler set by NotFound.
 func (mux *ServeMux) Handler(r *Request) (handler Handler, pattern string) {
+	handler, _, pattern = mux.route(r)
+	return handler, pattern
+}
+
+// route is like Handler but also returns the handler wrapped in the
+// middleware, which is nil if the middleware must still be applied.
+func (mux *ServeMux) route(r *Request) (handler, chained Handler, pattern string) {
 	if r.URL.Scheme != "gemini" {
-		return mux.notFound, ""
+		return mux.notFoundHandler()
 	}
 
 	host, _ := splitHostPort(r.Host)
@@ -64,13 +149,13 @@ func (mux *ServeMux) Handler(r *Request) (handler Handler, pattern string) {
 
 	if mux.shouldRedirect(host, path) {
 		u := url.URL{Path: path + "/", RawQuery: r.URL.RawQuery}
-		return RedirectHandler(u.String(), StatusPermanentRedirect), u.Path
+		return RedirectHandler(u.String(), StatusPermanentRedirect), nil, u.Path
 	}
 
 	if path != r.URL.Path {
-		_, pattern = mux.handler(host, path)
+		_, _, pattern = mux.handler(host, path)
 		u := url.URL{Path: path, RawQuery: r.URL.RawQuery}
-		return RedirectHandler(u.String(), StatusPermanentRedirect), pattern
+		return RedirectHandler(u.String(), StatusPermanentRedirect), nil, pattern
 	}
 
 	return mux.handler(host, path)
@@ -81,36 +166,127 @@ func (mux *ServeMux) Handler(r *Request) (handler Handler, pattern string) {
 func (mux *ServeMux) NotFound(h HandlerFunc) {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
-	mux.notFound = h
+	mux.notFound, mux.chained = nil, nil
+	if h != nil {
+		mux.notFound, mux.chained = h, chain(h, mux.mws)
+	}
+}
+
+// DefaultHeader sets the response header for handlers
+// that never call WriteHeader, overriding the Server default.
+// It is not set if statusCode is zero, which is the default.
+func (mux *ServeMux) DefaultHeader(statusCode int, meta string) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.defCode, mux.defMeta = statusCode, meta
+}
+
+// Use appends middleware that wraps every handler selected by the mux,
+// including the NotFound handler and redirects to canonical paths.
+// The first middleware is the outermost. Middleware applies to
+// handlers regardless of whether they were registered before or after Use.
+// Handlers are wrapped once when they are registered or Use is called,
+// rather than on every request.
+func (mux *ServeMux) Use(middleware ...func(Handler) Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.mws = append(mux.mws, middleware...)
+
+	for pattern, e := range mux.exact {
+		e.chained = chain(e.handler, mux.mws)
+		mux.exact[pattern] = e
+		if pattern[len(pattern)-1] == '/' {
+			mux.prefixes.insert(e)
+		}
+	}
+
+	if mux.notFound != nil {
+		mux.chained = chain(mux.notFound, mux.mws)
+	}
+}
+
+// AllowOverride sets whether registering a pattern that already exists
+// replaces the existing handler instead of failing.
+// It is disabled by default.
+func (mux *ServeMux) AllowOverride(allow bool) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.override = allow
 }
 
 // Handle registers the handler for the given pattern.
-// If a handler already exists for pattern, Handle panics.
+// If a handler already exists for pattern, Handle panics
+// unless overrides are allowed.
 func (mux *ServeMux) Handle(pattern string, handler Handler) {
+	if err := mux.TryHandle(pattern, handler); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryHandle registers the handler for the given pattern.
+// Unlike Handle, it returns an error instead of panicking
+// if the pattern is empty, the handler is nil, or a handler
+// already exists for pattern and overrides are not allowed.
+func (mux *ServeMux) TryHandle(pattern string, handler Handler) error {
+	return mux.register(pattern, handler, RouteInfo{})
+}
+
+// HandleWithInfo registers the handler for the given pattern
+// and attaches documentation metadata to it.
+// It panics under the same conditions as Handle.
+func (mux *ServeMux) HandleWithInfo(pattern string, handler Handler, info RouteInfo) {
+	if err := mux.register(pattern, handler, info); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Routes returns the metadata of all registered patterns sorted by pattern.
+func (mux *ServeMux) Routes() []RouteInfo {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	routes := make([]RouteInfo, 0, len(mux.exact))
+	for _, e := range mux.exact {
+		routes = append(routes, e.info)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Pattern < routes[j].Pattern
+	})
+
+	return routes
+}
+
+func (mux *ServeMux) register(pattern string, handler Handler, info RouteInfo) error {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 
+	_, exist := mux.exact[pattern]
+
 	if pattern == "" {
-		panic("gemproto: empty pattern")
+		return ErrEmptyPattern
 	} else if handler == nil {
-		panic("gemproto: nil handler")
-	} else if _, exist := mux.exact[pattern]; exist {
-		panic("gemproto: multiple registrations for " + pattern)
+		return ErrNilHandler
+	} else if exist && !mux.override {
+		return fmt.Errorf("%w for %s", ErrMultipleRegistrations, pattern)
 	}
 
 	if mux.exact == nil {
 		mux.exact = make(map[string]muxEntry)
 	}
 
-	entry := muxEntry{pattern, handler}
+	info.Pattern = pattern
+	entry := muxEntry{pattern, handler, chain(handler, mux.mws), info}
 
 	mux.exact[pattern] = entry
 
 	if pattern[len(pattern)-1] == '/' {
-		mux.entries = appendSorted(mux.entries, entry)
+		mux.prefixes.insert(entry)
 	}
 
 	mux.hosts = mux.hosts || pattern[0] != '/'
+
+	return nil
 }
 
 // HandleFunc registers the handler function for the given pattern.
@@ -137,44 +313,61 @@ func (mux *ServeMux) Route(pattern string, fn func(*ServeMux)) {
 
 // ServeGemini implements Handler.
 func (mux *ServeMux) ServeGemini(w ResponseWriter, r *Request) {
-	h, _ := mux.Handler(r)
-	h.ServeGemini(w, r)
+	mux.mu.RLock()
+	defCode, defMeta, mws := mux.defCode, mux.defMeta, mux.mws
+	mux.mu.RUnlock()
+
+	if defCode != 0 {
+		w.WriteHeader(defCode, defMeta)
+	}
+
+	h, chained, _ := mux.route(r)
+	if chained == nil {
+		chained = chain(h, mws)
+	}
+	chained.ServeGemini(w, r)
 }
 
-func (mux *ServeMux) handler(host, path string) (h Handler, pattern string) {
+func (mux *ServeMux) handler(host, path string) (h, chained Handler, pattern string) {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
 
 	// Host-specific pattern takes precedence over generic ones
+	var e *muxEntry
 	if mux.hosts {
-		h, pattern = mux.match(host + path)
-	}
-	if h == nil {
-		h, pattern = mux.match(path)
+		e = mux.match(host + path)
 	}
-	if h == nil {
-		h, pattern = mux.notFound, ""
+	if e == nil {
+		e = mux.match(path)
 	}
-	if h == nil {
-		h, pattern = HandlerFunc(NotFound), ""
+	if e != nil {
+		return e.handler, e.chained, e.pattern
 	}
-	return h, pattern
+	return mux.notFoundLocked()
 }
 
-func (mux *ServeMux) match(path string) (h Handler, pattern string) {
-	if e, ok := mux.exact[path]; ok {
-		return e.handler, e.pattern
+func (mux *ServeMux) notFoundHandler() (h, chained Handler, pattern string) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	return mux.notFoundLocked()
+}
+
+func (mux *ServeMux) notFoundLocked() (h, chained Handler, pattern string) {
+	if mux.notFound == nil {
+		return HandlerFunc(NotFound), nil, ""
 	}
+	return mux.notFound, mux.chained, ""
+}
 
-	// Check for longest valid match. mux.entries contains all patterns
-	// that end in / sorted from longest to shortest.
-	for _, entry := range mux.entries {
-		if strings.HasPrefix(path, entry.pattern) {
-			return entry.handler, entry.pattern
-		}
+func (mux *ServeMux) match(path string) *muxEntry {
+	if e, ok := mux.exact[path]; ok {
+		return &e
 	}
 
-	return nil, ""
+	// Check for longest valid match. mux.prefixes contains all patterns
+	// that end in / so the lookup takes time proportional to the number
+	// of segments in path rather than the number of patterns.
+	return mux.prefixes.lookup(path)
 }
 
 func (mux *ServeMux) shouldRedirect(host, path string) bool {
@@ -213,18 +406,3 @@ func cleanPath(p string) string {
 	}
 	return np
 }
-
-// appendSorted inserts e in es while keeping es alphabetically sorted by pattern.
-func appendSorted(es []muxEntry, e muxEntry) []muxEntry {
-	n := len(es)
-	i := sort.Search(n, func(i int) bool {
-		return len(es[i].pattern) < len(e.pattern)
-	})
-	if i == n {
-		return append(es, e)
-	}
-	es = append(es, muxEntry{})
-	copy(es[i+1:], es[i:])
-	es[i] = e
-	return es
-}
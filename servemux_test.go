@@ -64,3 +64,140 @@ func TestServeMuxMount(t *testing.T) {
 	require.Equal(t, gemproto.StatusOK, w.Code)
 	require.Equal(t, "hello\n", w.Body.String())
 }
+
+func TestServeMuxTryHandle(t *testing.T) {
+	t.Parallel()
+
+	hello := func(msg string) gemproto.HandlerFunc {
+		return func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			fmt.Fprint(w, msg)
+		}
+	}
+
+	mux := gemproto.NewServeMux()
+	require.NoError(t, mux.TryHandle("/a/", hello("a")))
+	require.ErrorIs(t, mux.TryHandle("/a/", hello("b")), gemproto.ErrMultipleRegistrations)
+	require.ErrorIs(t, mux.TryHandle("", hello("b")), gemproto.ErrEmptyPattern)
+	require.ErrorIs(t, mux.TryHandle("/b", nil), gemproto.ErrNilHandler)
+
+	mux.AllowOverride(true)
+	require.NoError(t, mux.TryHandle("/a/", hello("b")))
+
+	w := gemtest.NewRecorder()
+	mux.ServeGemini(w, gemtest.NewRequest("/a/x"))
+	require.Equal(t, "b", w.Body.String())
+}
+
+func TestServeMuxDefaultHeader(t *testing.T) {
+	t.Parallel()
+
+	mux := gemproto.NewServeMux()
+	mux.DefaultHeader(gemproto.StatusOK, "application/json")
+	mux.HandleFunc("/api", func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		fmt.Fprint(w, "{}")
+	})
+	mux.HandleFunc("/text", func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		w.WriteHeader(gemproto.StatusOK, "text/plain")
+		fmt.Fprint(w, "text")
+	})
+
+	w := gemtest.NewRecorder()
+	mux.ServeGemini(w, gemtest.NewRequest("/api"))
+	require.Equal(t, "application/json", w.Meta)
+
+	w = gemtest.NewRecorder()
+	mux.ServeGemini(w, gemtest.NewRequest("/text"))
+	require.Equal(t, "text/plain", w.Meta)
+}
+
+func TestServeMuxUse(t *testing.T) {
+	t.Parallel()
+
+	var wraps int
+	tag := func(s string) func(gemproto.Handler) gemproto.Handler {
+		return func(next gemproto.Handler) gemproto.Handler {
+			wraps++
+			return gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+				fmt.Fprint(w, s)
+				next.ServeGemini(w, r)
+			})
+		}
+	}
+
+	mux := gemproto.NewServeMux()
+	mux.Use(tag("a"))
+	mux.HandleFunc("/index.gmi", func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		fmt.Fprint(w, "index")
+	})
+	mux.Use(tag("b"))
+	n := wraps
+
+	w := gemtest.NewRecorder()
+	mux.ServeGemini(w, gemtest.NewRequest("/index.gmi"))
+	require.Equal(t, "abindex", w.Body.String())
+
+	w = gemtest.NewRecorder()
+	mux.ServeGemini(w, gemtest.NewRequest("/missing"))
+	require.Equal(t, "ab", w.Body.String())
+
+	// handlers are not wrapped again for every request
+	require.Equal(t, n, wraps)
+}
+
+func TestServeMuxLongestPrefix(t *testing.T) {
+	t.Parallel()
+
+	mux := gemproto.NewServeMux()
+	mux.AllowOverride(true)
+	for _, pattern := range []string{
+		"/", "/a/", "/a/b/", "/a/b/c/", "/ab/", "/a/b/c/d", "example.com/a/",
+	} {
+		mux.Handle(pattern, gemproto.NotFoundHandler())
+	}
+	mux.Handle("/a/b/", gemproto.NotFoundHandler())
+
+	for _, testcase := range []struct {
+		URL     string
+		Pattern string
+	}{
+		{"gemini://localhost/", "/"},
+		{"gemini://localhost/x", "/"},
+		{"gemini://localhost/a", "/a/"}, // redirect
+		{"gemini://localhost/a/", "/a/"},
+		{"gemini://localhost/a/x", "/a/"},
+		{"gemini://localhost/ab/x", "/ab/"},
+		{"gemini://localhost/abc/", "/"},
+		{"gemini://localhost/a/b/x/y", "/a/b/"},
+		{"gemini://localhost/a/b/c/", "/a/b/c/"},
+		{"gemini://localhost/a/b/c/d", "/a/b/c/d"},
+		{"gemini://localhost/a/b/c/de", "/a/b/c/"},
+		{"gemini://example.com/a/b/", "example.com/a/"},
+		{"gemini://example.com/b/", "/"},
+	} {
+		_, pattern := mux.Handler(gemtest.NewRequest(testcase.URL))
+		require.Equal(t, testcase.Pattern, pattern, testcase.URL)
+	}
+}
+
+func BenchmarkServeMuxUserDirs(b *testing.B) {
+	for _, n := range []int{10, 1000, 10000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			mux := gemproto.NewServeMux()
+			for i := 0; i < n; i++ {
+				mux.Handle(fmt.Sprintf("/~user%d/", i), gemproto.NotFoundHandler())
+			}
+			mux.Handle("/", gemproto.NotFoundHandler())
+
+			reqs := []*gemproto.Request{
+				gemtest.NewRequest(fmt.Sprintf("/~user%d/posts/2022/hello.gmi", n/2)),
+				gemtest.NewRequest("/index.gmi"),
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = mux.Handler(reqs[i%len(reqs)])
+			}
+		})
+	}
+}
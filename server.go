@@ -8,8 +8,12 @@ import (
 	"io"
 	"net"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/askeladdk/gemproto/gemtext"
 )
@@ -30,6 +34,14 @@ func (f HandlerFunc) ServeGemini(w ResponseWriter, r *Request) {
 	f(w, r)
 }
 
+// chain wraps h in the middleware so that the first one is the outermost.
+func chain(h Handler, middleware []func(Handler) Handler) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
 // ResponseWriter is used to construct the response.
 //
 // WriteHeader sets the response header. It is not actually
@@ -41,32 +53,286 @@ type ResponseWriter interface {
 	WriteHeader(statusCode int, meta string)
 }
 
+// Flusher is implemented by ResponseWriters that allow handlers
+// to send buffered data to the client, analogous to http.Flusher.
+// Flush writes the response header if it has not been written yet,
+// so that clients receive it before a long-lived streaming body.
+//
+// The ResponseWriters passed to handlers by Server implement Flusher.
+// Middleware that wraps the ResponseWriter may hide it,
+// so handlers should test for it with a type assertion:
+//
+//	if f, ok := w.(gemproto.Flusher); ok {
+//	  f.Flush()
+//	}
+type Flusher interface {
+	// Flush sends any buffered data to the client.
+	Flush()
+}
+
+// ErrHijacked is returned by ResponseWriter.Write
+// after the connection has been hijacked.
+var ErrHijacked = errors.New("gemproto: connection has been hijacked")
+
+// Hijacker is implemented by ResponseWriters that allow handlers
+// to take over the connection, analogous to http.Hijacker.
+// It enables custom streaming protocols and tunnels
+// after the Gemini request has been received.
+//
+// The ResponseWriters passed to handlers by Server implement Hijacker.
+// Middleware that wraps the ResponseWriter may hide it.
+type Hijacker interface {
+	// Hijack writes the response header unless the status code is lower
+	// than 10 and returns the connection, which is a *tls.Conn
+	// unless the Server is Insecure. The Server does nothing further
+	// with the connection after Hijack returns, and the caller
+	// becomes responsible for closing it. The deadlines set by
+	// ReadTimeout and WriteTimeout remain in effect until changed.
+	Hijack() (net.Conn, error)
+}
+
+// ResponseStats is implemented by ResponseWriters that account for
+// the response, so that logging and metrics middleware
+// do not have to wrap the ResponseWriter themselves.
+//
+// The ResponseWriters passed to handlers by Server implement ResponseStats.
+//
+//	if rs, ok := w.(gemproto.ResponseStats); ok {
+//	  code, meta := rs.Status()
+//	  log.Println(code, meta, rs.BytesWritten())
+//	}
+type ResponseStats interface {
+	// Status returns the status code and meta of the response.
+	// They are the defaults of the Server until WriteHeader is called.
+	Status() (statusCode int, meta string)
+
+	// BytesWritten returns the number of body bytes written so far,
+	// excluding the header.
+	BytesWritten() int64
+}
+
+// hijack hijacks w if it implements Hijacker.
+func hijack(w io.Writer) (net.Conn, error) {
+	if h, ok := w.(Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, errors.New("gemproto: connection cannot be hijacked")
+}
+
+// flush flushes w if it implements Flusher.
+func flush(w io.Writer) {
+	if f, ok := w.(Flusher); ok {
+		f.Flush()
+	}
+}
+
 type responseWriter struct {
 	w           io.Writer
+	conn        net.Conn
 	statusCode  int
 	metadata    string
+	sanitize    bool
 	wroteHeader bool
+	hijacked    bool
+	written     int64
+	err         error
+
+	// slidingTimeout extends the write deadline on every write if set
+	slidingTimeout time.Duration
+}
+
+// extendDeadline slides the write deadline before writing the header,
+// before flushing and after a successful write.
+func (rw *responseWriter) extendDeadline() {
+	if rw.slidingTimeout > 0 {
+		_ = rw.conn.SetWriteDeadline(time.Now().Add(rw.slidingTimeout))
+	}
 }
 
 func (rw *responseWriter) writeHeader() error {
 	if !rw.wroteHeader {
 		rw.wroteHeader = true
 		if rw.statusCode >= 10 {
-			return reply(rw.w, rw.statusCode, rw.metadata)
+			if rw.sanitize {
+				rw.metadata = SanitizeMeta(rw.metadata)
+			}
+			// the handler may have taken a while before responding
+			rw.extendDeadline()
+			if err := reply(rw.w, rw.statusCode, rw.metadata); err != nil {
+				rw.err = err
+				return err
+			}
 		}
 	}
 	return nil
 }
 
 func (rw *responseWriter) WriteHeader(statusCode int, metadata string) {
-	rw.statusCode, rw.metadata = statusCode, metadata
+	// the header cannot be changed after it has been written
+	if !rw.wroteHeader {
+		rw.statusCode, rw.metadata = statusCode, metadata
+	}
 }
 
 func (rw *responseWriter) Write(p []byte) (int, error) {
-	if err := rw.writeHeader(); err != nil {
+	if rw.hijacked {
+		return 0, ErrHijacked
+	} else if err := rw.writeHeader(); err != nil {
 		return 0, err
 	}
-	return rw.w.Write(p)
+	n, err := rw.w.Write(p)
+	rw.written += int64(n)
+	if err != nil && rw.err == nil {
+		rw.err = err
+	} else if err == nil {
+		rw.extendDeadline()
+	}
+	return n, err
+}
+
+// Status implements ResponseStats.
+func (rw *responseWriter) Status() (int, string) {
+	return rw.statusCode, rw.metadata
+}
+
+// BytesWritten implements ResponseStats.
+func (rw *responseWriter) BytesWritten() int64 {
+	return rw.written
+}
+
+// Hijack implements Hijacker.
+func (rw *responseWriter) Hijack() (net.Conn, error) {
+	if rw.hijacked {
+		return nil, ErrHijacked
+	} else if err := rw.writeHeader(); err != nil {
+		return nil, err
+	}
+	rw.hijacked = true
+	return rw.conn, nil
+}
+
+// Flush implements Flusher.
+func (rw *responseWriter) Flush() {
+	if rw.hijacked {
+		return
+	} else if err := rw.writeHeader(); err != nil {
+		return
+	}
+	rw.extendDeadline()
+	if f, ok := rw.w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil && rw.err == nil {
+			rw.err = err
+		}
+	}
+}
+
+// WriteString implements io.StringWriter.
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	if rw.hijacked {
+		return 0, ErrHijacked
+	} else if err := rw.writeHeader(); err != nil {
+		return 0, err
+	}
+	n, err := io.WriteString(rw.w, s)
+	rw.written += int64(n)
+	if err != nil && rw.err == nil {
+		rw.err = err
+	} else if err == nil {
+		rw.extendDeadline()
+	}
+	return n, err
+}
+
+// ResponseInfo describes a response that has been written by the Server.
+type ResponseInfo struct {
+	// StatusCode is the response status code.
+	StatusCode int
+
+	// Meta is the response meta.
+	Meta string
+
+	// BytesWritten is the number of body bytes written.
+	BytesWritten int64
+
+	// Duration is the time elapsed between receiving the request
+	// and the handler returning.
+	Duration time.Duration
+
+	// Err is the first error that occurred while writing the response.
+	Err error
+}
+
+type responseHooks struct {
+	fns []func(ResponseInfo)
+	mu  sync.Mutex
+}
+
+// AfterResponse registers fn to be called by the Server after the handler
+// has returned and the response has been written.
+// This allows middleware to observe the outcome of a response,
+// including handlers that stream their response directly.
+// Hooks are called in the reverse order of registration.
+// AfterResponse reports false and does nothing if r was not received by a Server.
+func AfterResponse(r *Request, fn func(ResponseInfo)) bool {
+	if r.hooks == nil {
+		return false
+	}
+
+	r.hooks.mu.Lock()
+	defer r.hooks.mu.Unlock()
+	r.hooks.fns = append(r.hooks.fns, fn)
+	return true
+}
+
+func (h *responseHooks) run(info ResponseInfo) {
+	h.mu.Lock()
+	fns := h.fns
+	h.mu.Unlock()
+
+	for i := len(fns) - 1; i >= 0; i-- {
+		fns[i](info)
+	}
+}
+
+// ConnState represents the state of a client connection to a Server.
+// It is used by the Server.ConnState hook.
+type ConnState int
+
+const (
+	// StateNew is a connection that has just been accepted.
+	StateNew ConnState = iota
+
+	// StateHandshake is a connection that is performing the TLS handshake.
+	// Insecure servers skip this state.
+	StateHandshake
+
+	// StateActive is a connection that is reading the request
+	// and serving the response.
+	StateActive
+
+	// StateClosed is a connection that has been closed.
+	// It is the final state of every connection that is not hijacked.
+	StateClosed
+
+	// StateHijacked is a connection that has been taken over by
+	// a handler using Hijacker. It is the final state of the connection.
+	StateHijacked
+)
+
+var connStateNames = [...]string{
+	StateNew:       "new",
+	StateHandshake: "handshake",
+	StateActive:    "active",
+	StateClosed:    "closed",
+	StateHijacked:  "hijacked",
+}
+
+// String implements the fmt.Stringer interface.
+func (c ConnState) String() string {
+	if c >= 0 && int(c) < len(connStateNames) {
+		return connStateNames[c]
+	}
+	return "unknown"
 }
 
 // Logger provides a simple interface for the Server to log to.
@@ -74,6 +340,44 @@ type Logger interface {
 	Printf(format string, v ...any)
 }
 
+// Log event categories.
+const (
+	LogCategoryAccept    = "accept"
+	LogCategoryHandshake = "handshake"
+	LogCategoryRequest   = "request"
+	LogCategoryPanic     = "panic"
+)
+
+// LogEvent is a structured diagnostic event logged by Server.
+type LogEvent struct {
+	// Message describes the event.
+	Message string
+
+	// Category classifies the event, such as LogCategoryHandshake.
+	Category string
+
+	// RemoteAddr is the address of the client, if any.
+	RemoteAddr string
+
+	// ServerName is the SNI sent by the client, if known.
+	ServerName string
+
+	// StatusCode is the status code of the response, if any.
+	StatusCode int
+
+	// Err is the error that caused the event, if any.
+	Err error
+}
+
+// EventLogger is implemented by loggers that accept structured events.
+// If Server.Logger implements EventLogger then LogEvent is called
+// instead of Printf, so that the fields can be ingested by log pipelines
+// without parsing the messages.
+type EventLogger interface {
+	Logger
+	LogEvent(e LogEvent)
+}
+
 // Server defines parameters for running a Gemini server.
 //
 // The zero value for Server is not a valid configuration.
@@ -82,9 +386,19 @@ type Logger interface {
 type Server struct {
 	// Addr is the address to listen on.
 	// Defaults to :1965 if empty.
+	// It is the path of the socket file if Network is unix.
 	Addr string
 
+	// Network is the network to listen on, such as tcp or unix.
+	// Defaults to tcp if empty.
+	// Unix domain sockets allow capsules to run behind a relay
+	// without binding to a privileged port. They are typically
+	// used together with Insecure.
+	Network string
+
 	// Handler is invoked to handle all requests.
+	// It must not be modified after the server has started;
+	// use SetHandler to replace it while the server is running.
 	Handler Handler
 
 	// Logger logs various diagnostics if it is not nil.
@@ -98,20 +412,284 @@ type Server struct {
 
 	// WriteTimeout sets the maximum duration before
 	// timing out on writing an outgoing response.
+	// The request context carries the same deadline, so that work done
+	// on behalf of the handler is cancelled when the connection times out.
 	WriteTimeout time.Duration
 
+	// SlidingWriteTimeout extends the write deadline by WriteTimeout
+	// when the header is written, when the response is flushed and
+	// after every successful write, so that WriteTimeout limits the time
+	// between writes rather than the duration of the whole response.
+	// It allows handlers to stream indefinitely while making progress.
+	SlidingWriteTimeout bool
+
+	// HandshakeTimeout sets the maximum duration of the TLS handshake.
+	// It prevents stalled handshakes from holding a connection
+	// for the full ReadTimeout. If it is set, ReadTimeout and WriteTimeout
+	// start when the handshake completes, so that the handshake is only
+	// limited by HandshakeTimeout. There is no separate limit if it is zero,
+	// in which case the handshake counts against ReadTimeout and WriteTimeout.
+	HandshakeTimeout time.Duration
+
+	// MaxHandshakes limits the number of TLS handshakes that are
+	// performed concurrently, because handshakes are CPU intensive.
+	// Connections in excess of the limit wait in a queue for at most
+	// HandshakeTimeout, or until the server stops if it is zero,
+	// and are closed if they time out. There is no limit if MaxHandshakes is zero.
+	MaxHandshakes int
+
+	// IdleTimeout sets the maximum duration between accepting a connection,
+	// or completing the TLS handshake, and receiving the first byte of the request.
+	// ReadTimeout restarts when the first byte is received if IdleTimeout is set.
+	// It does not affect WriteTimeout.
+	IdleTimeout time.Duration
+
+	// DefaultStatus is the status code of responses
+	// for which the handler never calls WriteHeader.
+	// Defaults to 20 if zero.
+	DefaultStatus int
+
+	// DefaultMeta is the meta of responses
+	// for which the handler never calls WriteHeader.
+	// Defaults to text/gemini if DefaultStatus is zero.
+	DefaultMeta string
+
+	// UnsanitizedMeta disables sanitizing the response meta with SanitizeMeta.
+	// By default, control characters are replaced by spaces so that
+	// handlers that reflect user input cannot inject content into the header.
+	UnsanitizedMeta bool
+
+	// OnListen is optional and called with the address of the listener
+	// right before the server starts accepting connections.
+	// It allows programs that listen on port 0 to discover the bound port.
+	OnListen func(addr net.Addr)
+
+	// Stats is optional and receives instrumentation events
+	// such as accepted connections, failed handshakes and served requests.
+	// See package expvarstats for a ready-made collector.
+	Stats StatsCollector
+
+	// OnRequest is optional and called with every request that was
+	// read successfully, before it is dispatched to the handler.
+	// The request must not be modified.
+	OnRequest func(r *Request)
+
+	// OnResponse is optional and called after the handler has returned
+	// and the response has been written. Together with OnRequest, it allows
+	// audit trails and analytics to be implemented for all requests
+	// without wrapping every handler in middleware.
+	OnResponse func(r *Request, info ResponseInfo)
+
+	// Middleware is optional and wraps Handler, so that cross-cutting
+	// concerns such as logging, recovery and authentication apply to
+	// all requests. The first middleware is the outermost.
+	// The handlers are wrapped once, when the first request is served
+	// or SetHandler is called, so Middleware must not be modified
+	// after the server has started.
+	Middleware []func(Handler) Handler
+
+	// MaxRequestBytes limits the length of the request URL in bytes,
+	// excluding the terminating CRLF. Longer requests are answered
+	// with 59 BAD REQUEST. Defaults to 1024 as required by the specification.
+	MaxRequestBytes int
+
+	// MaxConns limits the number of connections that are served concurrently.
+	// Connections in excess of the limit are answered with
+	// 41 SERVER UNAVAILABLE. At most MaxConns connections are answered
+	// this way at the same time, and any further connections are closed
+	// as soon as they are accepted. There is no limit if MaxConns is zero.
+	MaxConns int
+
+	// SlowDown is optional and makes the server answer connections
+	// in excess of MaxConns with 44 SLOW DOWN and the number of seconds
+	// that clients should wait before retrying, rounded up, instead of
+	// 41 SERVER UNAVAILABLE. Such connections are answered without
+	// dispatching the request to the handler and closed immediately.
+	SlowDown time.Duration
+
+	// DrainTimeout enables drain mode if it is positive. When the context
+	// passed to Serve is cancelled, the listener stays open for DrainTimeout
+	// and new requests are answered with 41 SERVER UNAVAILABLE and DrainMeta,
+	// so that load balancers can take the server out of rotation
+	// before it stops accepting connections. Like the rejections of
+	// MaxConns, at most MaxConns connections are answered this way
+	// at the same time.
+	DrainTimeout time.Duration
+
+	// DrainMeta is the meta of responses in drain mode, which can include
+	// a retry hint such as "Restarting; retry in 30 seconds".
+	// Defaults to "Server Unavailable".
+	DrainMeta string
+
+	// BaseContext is optional and returns the base context of
+	// the requests received on the listener. It defaults to the context
+	// passed to Serve. The returned context should be derived from that
+	// context, otherwise requests are not cancelled when the server stops.
+	BaseContext func(net.Listener) context.Context
+
+	// ConnContext is optional and modifies the context of the requests
+	// received on a new connection. The provided ctx is derived from
+	// the base context and has a ServerContextKey value.
+	// The returned context must be non-nil.
+	ConnContext func(ctx context.Context, conn net.Conn) context.Context
+
+	// RecoverHandler is optional and called when a handler panics
+	// with the value passed to panic. It can respond to the client if
+	// the response header has not been written yet, and can call
+	// debug.Stack to capture the stack of the panic.
+	// If it is nil, the client is answered with 42 CGI ERROR
+	// if the response header has not been written yet.
+	// Panics are logged regardless.
+	RecoverHandler func(w ResponseWriter, r *Request, v any)
+
+	// ConnState is optional and called when a client connection
+	// changes state. See the ConnState type for details.
+	// It is called from the goroutine serving the connection,
+	// except for StateNew which is called from the accept loop.
+	ConnState func(net.Conn, ConnState)
+
+	// ProxyProtocol enables reading a PROXY protocol version 1 or 2 header
+	// at the start of every connection, so that Request.RemoteAddr is the
+	// address of the client rather than the address of the relay.
+	// Connections without a valid header are refused.
+	// It must only be enabled if all connections come from a trusted relay,
+	// because clients can otherwise spoof their address.
+	ProxyProtocol bool
+
+	// Hosts enables strict host checking if it is not empty.
+	// Requests for URLs whose host is not in Hosts, or whose scheme is not
+	// gemini, are served by ProxyHandler. Hostnames are compared case
+	// insensitively, ports are ignored, and hostnames may be wildcards of
+	// the form *.example.com that match exactly one subdomain label.
+	// Requests without a host are always allowed.
+	Hosts []string
+
+	// ProxyHandler is optional and serves the requests refused by
+	// strict host checking. If it is nil, such requests are answered
+	// with 53 PROXY REQUEST REFUSED.
+	ProxyHandler Handler
+
+	// Hostname is optional and is the host of the server when the client
+	// sends no Server Name Indication and the request URL has no host,
+	// so that Request.Host and absolute URLs remain meaningful.
+	// It should be set on Insecure servers, which never receive SNI,
+	// for example to the public host of a relay that terminates TLS.
+	Hostname string
+
 	// Insecure disables TLS.
 	// It should only be set if the server is behind a reverse proxy.
 	// Insecure servers do not support Server Name Indication (SNI).
 	Insecure bool
+
+	conns       int32
+	rejects     int32
+	handler     atomic.Value // *handlerBox
+	defaultBox  *handlerBox
+	defaultOnce sync.Once
+	handshakes  chan struct{}
+	hsOnce      sync.Once
 }
 
-func (srv *Server) logf(format string, v ...any) {
-	if srv.Logger != nil {
+// handlerBox holds a handler together with the handlers
+// that serve requests, which are wrapped in the middleware.
+type handlerBox struct {
+	h       Handler
+	chained Handler // h or NotFoundHandler
+	proxy   Handler // ProxyHandler or refuseProxy
+}
+
+func (srv *Server) newHandlerBox(h Handler) *handlerBox {
+	handler, proxy := h, srv.ProxyHandler
+	if handler == nil {
+		handler = NotFoundHandler()
+	}
+	if proxy == nil {
+		proxy = HandlerFunc(refuseProxy)
+	}
+
+	return &handlerBox{
+		h:       h,
+		chained: chain(handler, srv.Middleware),
+		proxy:   chain(proxy, srv.Middleware),
+	}
+}
+
+// SetHandler atomically replaces the handler of the server, for example
+// after a configuration reload. It is safe to call while the server is running.
+// Requests that are already being served complete with the old handler.
+// It takes precedence over the Handler field.
+func (srv *Server) SetHandler(h Handler) {
+	srv.handler.Store(srv.newHandlerBox(h))
+}
+
+// handlers returns the handlers set by SetHandler or else Handler.
+func (srv *Server) handlers() *handlerBox {
+	if box, ok := srv.handler.Load().(*handlerBox); ok {
+		return box
+	}
+	srv.defaultOnce.Do(func() {
+		srv.defaultBox = srv.newHandlerBox(srv.Handler)
+	})
+	return srv.defaultBox
+}
+
+// currentHandler returns the handler set by SetHandler or else Handler.
+func (srv *Server) currentHandler() Handler {
+	if box, ok := srv.handler.Load().(*handlerBox); ok {
+		return box.h
+	}
+	return srv.Handler
+}
+
+func (srv *Server) drainMeta() string {
+	if srv.DrainMeta != "" {
+		return srv.DrainMeta
+	}
+	return "Server Unavailable"
+}
+
+func (srv *Server) maxRequestLine() int {
+	if srv.MaxRequestBytes > 0 {
+		return srv.MaxRequestBytes + 2
+	}
+	return 1024 + 2
+}
+
+func (srv *Server) setState(conn net.Conn, state ConnState) {
+	if srv.ConnState != nil {
+		srv.ConnState(conn, state)
+	}
+}
+
+// logEvent logs the event if Logger is an EventLogger
+// and the formatted message otherwise.
+func (srv *Server) logEvent(e LogEvent, format string, v ...any) {
+	if el, ok := srv.Logger.(EventLogger); ok {
+		el.LogEvent(e)
+	} else if srv.Logger != nil {
 		srv.Logger.Printf(format, v...)
 	}
 }
 
+// connEvent returns a LogEvent describing the connection.
+func connEvent(conn net.Conn, category, message string, err error) LogEvent {
+	e := LogEvent{
+		Message:  message,
+		Category: category,
+		Err:      err,
+	}
+
+	if addr := conn.RemoteAddr(); addr != nil {
+		e.RemoteAddr = addr.String()
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		e.ServerName = tlsConn.ConnectionState().ServerName
+	}
+
+	return e
+}
+
 // ListenAndServe starts the server loop.
 // The server loop ends when the passed context is cancelled.
 func (srv *Server) ListenAndServe(ctx context.Context) error {
@@ -120,7 +698,12 @@ func (srv *Server) ListenAndServe(ctx context.Context) error {
 		addr = ":1965"
 	}
 
-	l, err := net.Listen("tcp", addr)
+	network := srv.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	l, err := net.Listen(network, addr)
 	if err != nil {
 		return err
 	}
@@ -129,27 +712,124 @@ func (srv *Server) ListenAndServe(ctx context.Context) error {
 	return srv.Serve(ctx, l)
 }
 
+// ListenAndServeAddrs is like ListenAndServe but listens on all addresses
+// simultaneously, for example to bind IPv4 and IPv6 addresses explicitly.
+// An address may be prefixed with tcp:, tcp4:, tcp6: or unix: to select
+// the network, which otherwise defaults to Server.Network.
+// Server.Addr is used if no addresses are given.
+// See ServeListeners for how the listeners are served.
+func (srv *Server) ListenAndServeAddrs(ctx context.Context, addrs ...string) error {
+	if len(addrs) == 0 {
+		return srv.ListenAndServe(ctx)
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	for _, addr := range addrs {
+		network := srv.Network
+		if prefix, rest, ok := strings.Cut(addr, ":"); ok {
+			switch prefix {
+			case "tcp", "tcp4", "tcp6", "unix":
+				network, addr = prefix, rest
+			}
+		}
+
+		if network == "" {
+			network = "tcp"
+		}
+
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+	}
+
+	return srv.ServeListeners(ctx, listeners...)
+}
+
+// ServeListeners starts a server loop for every listener and serves
+// them concurrently with the same Handler.
+// All loops end when the passed context is cancelled,
+// or when any of them fails, in which case that error is returned.
+// Otherwise ServeListeners returns ErrServerClosed.
+func (srv *Server) ServeListeners(ctx context.Context, listeners ...net.Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("gemproto: no listeners")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			err := srv.Serve(ctx, l)
+			if !errors.Is(err, ErrServerClosed) {
+				cancel()
+			}
+			errs <- err
+		}(l)
+	}
+
+	err := ErrServerClosed
+	for range listeners {
+		if e := <-errs; !errors.Is(e, ErrServerClosed) && errors.Is(err, ErrServerClosed) {
+			err = e
+		}
+	}
+
+	return err
+}
+
 // Serve starts the server loop and listens on a custom listener.
 // The server loop ends when the passed context is cancelled.
 func (srv *Server) Serve(ctx context.Context, l net.Listener) error {
+	if srv.ProxyProtocol {
+		l = proxyListener{l}
+	}
+
 	if !srv.Insecure {
-		if srv.TLSConfig == nil {
-			return errors.New("gemproto: nil Server.TLSConfig")
-		} else if len(srv.TLSConfig.Certificates) == 0 && srv.TLSConfig.GetCertificate == nil {
-			return errors.New("gemproto: no Server.TLSConfig certificates")
+		if err := srv.checkTLSConfig(); err != nil {
+			return err
 		}
 
 		l = tls.NewListener(l, srv.TLSConfig)
 	}
 
-	var closed int32
+	var closed, draining int32
 
 	go func() {
 		<-ctx.Done()
+		if srv.DrainTimeout > 0 {
+			atomic.StoreInt32(&draining, 1)
+			time.Sleep(srv.DrainTimeout)
+		}
 		atomic.StoreInt32(&closed, 1)
 		l.Close()
 	}()
 
+	if srv.OnListen != nil {
+		srv.OnListen(l.Addr())
+	}
+
+	baseCtx := ctx
+	if srv.BaseContext != nil {
+		if baseCtx = srv.BaseContext(l); baseCtx == nil {
+			panic("gemproto: BaseContext returned a nil context")
+		}
+	}
+	baseCtx = context.WithValue(baseCtx, ServerContextKey, srv)
+
+	// drained connections are answered after ctx is cancelled,
+	// so they are bounded by the timeouts instead
+	drainCtx := context.WithValue(context.Background(), ServerContextKey, srv)
+
 	const maxBackoff = 1 * time.Second
 	const defBackoff = 5 * time.Millisecond
 	backoff := defBackoff
@@ -160,7 +840,11 @@ func (srv *Server) Serve(ctx context.Context, l net.Listener) error {
 		if err != nil {
 			var ne net.Error
 			if errors.As(err, &ne) && ne.Timeout() {
-				srv.logf("gemproto: accept timeout: %v; retrying in %v", err, backoff)
+				srv.logEvent(LogEvent{
+					Message:  "accept timeout",
+					Category: LogCategoryAccept,
+					Err:      err,
+				}, "gemproto: accept timeout: %v; retrying in %v", err, backoff)
 				time.Sleep(backoff)
 				backoff *= 2
 				if backoff > maxBackoff {
@@ -173,51 +857,334 @@ func (srv *Server) Serve(ctx context.Context, l net.Listener) error {
 				return ErrServerClosed
 			}
 
-			srv.logf("gemproto: server listen error: %s", err)
+			srv.logEvent(LogEvent{
+				Message:  "server listen error",
+				Category: LogCategoryAccept,
+				Err:      err,
+			}, "gemproto: server listen error: %s", err)
 			return err
 		}
 
 		backoff = defBackoff
-		go srv.serve(ctx, conn)
+
+		srv.setState(conn, StateNew)
+
+		if srv.Stats != nil {
+			srv.Stats.ConnAccepted()
+		}
+
+		// connections accepted while draining are rejected,
+		// bounded like the rejections of admit
+		if atomic.LoadInt32(&draining) == 1 {
+			done, ok := srv.admitRejection(conn)
+			if !ok {
+				continue
+			}
+
+			go func() {
+				defer done()
+				srv.serve(drainCtx, conn, rejection{StatusServerUnavailable, srv.drainMeta()})
+			}()
+			continue
+		}
+
+		// admit before spawning a goroutine, so that a burst
+		// of connections cannot spawn unbounded goroutines
+		reject, done, ok := srv.admit(conn)
+		if !ok {
+			continue
+		}
+
+		go func() {
+			defer done()
+			srv.serve(baseCtx, conn, reject)
+		}()
 	}
 }
 
-func (srv *Server) serve(ctx context.Context, conn net.Conn) {
+// checkTLSConfig reports whether TLSConfig can serve TLS connections.
+func (srv *Server) checkTLSConfig() error {
+	if srv.TLSConfig == nil {
+		return errors.New("gemproto: nil Server.TLSConfig")
+	} else if len(srv.TLSConfig.Certificates) == 0 && srv.TLSConfig.GetCertificate == nil {
+		return errors.New("gemproto: no Server.TLSConfig certificates")
+	}
+	return nil
+}
+
+// ServeConn serves a single connection that was accepted elsewhere,
+// so that the server can be embedded into frameworks that manage
+// their own connections, such as inetd-style supervisors.
+// It blocks until the connection has been served and closed.
+//
+// The connection is wrapped in TLS using TLSConfig unless
+// it already is a *tls.Conn or the Server is Insecure.
+// ProxyProtocol, BaseContext and OnListen do not apply.
+// The connection is closed and an error is returned
+// if TLSConfig cannot be used to wrap it.
+func (srv *Server) ServeConn(ctx context.Context, conn net.Conn) error {
+	if _, ok := conn.(*tls.Conn); !ok && !srv.Insecure {
+		if err := srv.checkTLSConfig(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tls.Server(conn, srv.TLSConfig)
+	}
+
+	srv.setState(conn, StateNew)
+
+	if srv.Stats != nil {
+		srv.Stats.ConnAccepted()
+	}
+
+	reject, done, ok := srv.admit(conn)
+	if !ok {
+		return nil
+	}
+	defer done()
+
+	srv.serve(context.WithValue(ctx, ServerContextKey, srv), conn, reject)
+	return nil
+}
+
+// admit reserves one of MaxConns slots for conn and returns the function
+// that releases it. Connections in excess of MaxConns are answered with
+// the rejection, but no more than MaxConns of them at a time. Beyond that,
+// conn is closed without a response and admit returns false, so that
+// a burst of connections cannot exhaust the server with handshakes.
+func (srv *Server) admit(conn net.Conn) (reject rejection, done func(), ok bool) {
+	if srv.MaxConns <= 0 {
+		return rejection{}, func() {}, true
+	}
+
+	limit := int32(srv.MaxConns)
+
+	if atomic.AddInt32(&srv.conns, 1) <= limit {
+		return rejection{}, func() { atomic.AddInt32(&srv.conns, -1) }, true
+	}
+	atomic.AddInt32(&srv.conns, -1)
+
+	reject = srv.overloaded()
+	done, ok = srv.admitRejection(conn)
+	return reject, done, ok
+}
+
+// admitRejection reserves one of MaxConns slots for answering conn with
+// a rejection and returns the function that releases it. If all slots
+// are taken, conn is closed without a response and admitRejection
+// returns false.
+func (srv *Server) admitRejection(conn net.Conn) (done func(), ok bool) {
+	if srv.MaxConns <= 0 {
+		return func() {}, true
+	}
+
+	if atomic.AddInt32(&srv.rejects, 1) <= int32(srv.MaxConns) {
+		return func() { atomic.AddInt32(&srv.rejects, -1) }, true
+	}
+	atomic.AddInt32(&srv.rejects, -1)
+
+	conn.Close()
+	srv.setState(conn, StateClosed)
+	return nil, false
+}
+
+// rejection is the response to a connection that is refused
+// without dispatching the request to the handler.
+type rejection struct {
+	code int
+	meta string
+}
+
+// overloaded returns the rejection of connections in excess of MaxConns.
+func (srv *Server) overloaded() rejection {
+	if srv.SlowDown > 0 {
+		seconds := int((srv.SlowDown + time.Second - 1) / time.Second)
+		return rejection{StatusSlowDown, strconv.Itoa(seconds)}
+	}
+	return rejection{StatusServerUnavailable, "too many connections"}
+}
+
+// serve serves the connection. If reject has a code, the request
+// is answered with the rejection instead of being dispatched.
+func (srv *Server) serve(ctx context.Context, conn net.Conn, reject rejection) {
 	defer func() {
 		if v := recover(); v != nil {
-			srv.logf("gemproto: recover: %v", v)
+			err := fmt.Errorf("%v", v)
+			srv.logEvent(connEvent(conn, LogCategoryPanic, "recover", err), "gemproto: recover: %v", v)
 		}
 	}()
 
-	defer conn.Close()
+	var hijacked bool
+
+	defer func() {
+		if hijacked {
+			srv.setState(conn, StateHijacked)
+			return
+		}
+		conn.Close()
+		srv.setState(conn, StateClosed)
+	}()
+
+	if srv.ConnContext != nil {
+		if ctx = srv.ConnContext(ctx, conn); ctx == nil {
+			panic("gemproto: ConnContext returned a nil context")
+		}
+	}
+
+	// the read and write timeouts start after the handshake
+	// if it is limited by its own timeout
+	tlsConn, isTLS := conn.(*tls.Conn)
+	afterHandshake := isTLS && srv.HandshakeTimeout > 0
 
 	now := time.Now()
-	if srv.ReadTimeout > 0 {
-		_ = conn.SetReadDeadline(now.Add(srv.ReadTimeout))
+	if !afterHandshake {
+		srv.setDeadlines(conn, now)
 	}
 
+	if isTLS {
+		srv.setState(conn, StateHandshake)
+		if err := srv.handshake(ctx, tlsConn); err != nil {
+			if srv.Stats != nil {
+				srv.Stats.HandshakeFailed(err)
+			}
+			srv.logEvent(connEvent(conn, LogCategoryHandshake, "tls handshake failed", err),
+				"gemproto: tls handshake failed: %s", err)
+			return
+		}
+	}
+
+	if afterHandshake {
+		now = time.Now()
+		srv.setDeadlines(conn, now)
+	}
+
+	srv.setState(conn, StateActive)
+
+	if srv.IdleTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(srv.IdleTimeout))
+	}
+
+	start := time.Now()
+
+	if reject.code != 0 {
+		// read the request so that closing the connection does not reset it
+		_, _ = readHeaderLine(conn, srv.maxRequestLine())
+		_ = reply(conn, reject.code, reject.meta)
+		if srv.Stats != nil {
+			srv.Stats.RequestServed(reject.code, time.Since(start))
+		}
+		return
+	}
+
+	// the connection can no longer be written to past the write deadline,
+	// unless it slides, in which case there is no fixed deadline to report
+	if srv.WriteTimeout > 0 && !srv.SlidingWriteTimeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, now.Add(srv.WriteTimeout))
+		defer cancel()
+	}
+
+	statusCode, err := srv.respond(ctx, conn)
+
+	// the status code is zero if no request was received
+	if srv.Stats != nil && statusCode != 0 {
+		srv.Stats.RequestServed(statusCode, time.Since(start))
+	}
+
+	if err == ErrHijacked {
+		hijacked = true
+	} else if err != nil {
+		e := connEvent(conn, LogCategoryRequest, "error", err)
+		e.StatusCode = statusCode
+		srv.logEvent(e, "gemproto: error: %s", err)
+	}
+}
+
+// setDeadlines sets the read and write deadlines of conn
+// to ReadTimeout and WriteTimeout from now, or clears them.
+func (srv *Server) setDeadlines(conn net.Conn, now time.Time) {
+	var readDeadline, writeDeadline time.Time
+	if srv.ReadTimeout > 0 {
+		readDeadline = now.Add(srv.ReadTimeout)
+	}
 	if srv.WriteTimeout > 0 {
-		_ = conn.SetWriteDeadline(now.Add(srv.WriteTimeout))
+		writeDeadline = now.Add(srv.WriteTimeout)
 	}
+	_ = conn.SetReadDeadline(readDeadline)
+	_ = conn.SetWriteDeadline(writeDeadline)
+}
 
-	if tlsConn, ok := conn.(*tls.Conn); ok {
-		if err := tlsConn.HandshakeContext(ctx); err != nil {
-			srv.logf("gemproto: tls handshake failed: %s", err)
-			return
+// ErrHandshakeQueueTimeout is reported for connections that timed out
+// waiting for a handshake slot when Server.MaxHandshakes is set.
+var ErrHandshakeQueueTimeout = errors.New("gemproto: timed out waiting for tls handshake")
+
+func (srv *Server) handshake(ctx context.Context, conn *tls.Conn) error {
+	if srv.MaxHandshakes > 0 {
+		srv.hsOnce.Do(func() {
+			srv.handshakes = make(chan struct{}, srv.MaxHandshakes)
+		})
+
+		var timeout <-chan time.Time
+		if srv.HandshakeTimeout > 0 {
+			timer := time.NewTimer(srv.HandshakeTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case srv.handshakes <- struct{}{}:
+			defer func() { <-srv.handshakes }()
+		case <-timeout:
+			return ErrHandshakeQueueTimeout
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 
-	if err := srv.respond(ctx, conn); err != nil {
-		srv.logf("gemproto: error: %s", err)
+	if srv.HandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, srv.HandshakeTimeout)
+		defer cancel()
+		_ = conn.SetDeadline(time.Now().Add(srv.HandshakeTimeout))
 	}
+
+	return conn.HandshakeContext(ctx)
+}
+
+// idleReader restarts the read deadline when the first byte is received.
+type idleReader struct {
+	conn    net.Conn
+	timeout time.Duration
+	active  bool
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.conn.Read(p)
+	if n > 0 && !r.active {
+		r.active = true
+		var deadline time.Time
+		if r.timeout > 0 {
+			deadline = time.Now().Add(r.timeout)
+		}
+		_ = r.conn.SetReadDeadline(deadline)
+	}
+	return n, err
 }
 
-func (srv *Server) respond(ctx context.Context, conn net.Conn) error {
-	rawURL, err := readHeaderLine(conn, 1026)
-	if errors.Is(err, errHeaderLineTooLong) {
-		return reply(conn, StatusBadRequest, "request line too long")
+// respond serves a single request and returns the status code of the response.
+func (srv *Server) respond(ctx context.Context, conn net.Conn) (int, error) {
+	start := time.Now()
+
+	var r io.Reader = conn
+	if srv.IdleTimeout > 0 {
+		r = &idleReader{conn: conn, timeout: srv.ReadTimeout}
+	}
+
+	rawURL, err := readHeaderLine(r, srv.maxRequestLine())
+	if errors.Is(err, ErrHeaderTooLong) {
+		return StatusBadRequest, reply(conn, StatusBadRequest, "request line too long")
 	} else if err != nil { // i/o error
-		return err
+		return 0, err
 	}
 
 	var connState *tls.ConnectionState
@@ -231,7 +1198,17 @@ func (srv *Server) respond(ctx context.Context, conn net.Conn) error {
 
 	u, err := url.Parse(rawURL)
 	if err != nil {
-		return reply(conn, StatusBadRequest, "invalid url")
+		return StatusBadRequest, reply(conn, StatusBadRequest, "invalid url")
+	}
+
+	// without SNI, such as on Insecure servers, the host is
+	// taken from the request URL or else from Server.Hostname
+	if serverName == "" {
+		if u.Host != "" {
+			serverName = u.Hostname()
+		} else {
+			serverName = srv.Hostname
+		}
 	}
 
 	if u.Scheme == "" && u.Host == "" {
@@ -239,6 +1216,11 @@ func (srv *Server) respond(ctx context.Context, conn net.Conn) error {
 		u.Host = serverName
 	}
 
+	ctx = context.WithValue(ctx, RequestIDKey, newRequestID())
+	if srv.Logger != nil {
+		ctx = context.WithValue(ctx, LoggerKey, srv.Logger)
+	}
+
 	req := Request{
 		URL:        u,
 		RequestURI: rawURL,
@@ -246,24 +1228,114 @@ func (srv *Server) respond(ctx context.Context, conn net.Conn) error {
 		Host:       serverName,
 		TLS:        connState,
 		ctx:        ctx,
+		hooks:      &responseHooks{},
 	}
 
 	rw := responseWriter{
 		w:          conn,
+		conn:       conn,
 		statusCode: StatusOK,
 		metadata:   gemtext.MIMEType,
+		sanitize:   !srv.UnsanitizedMeta,
 	}
 
-	defer func() { _ = rw.writeHeader() }()
+	if srv.SlidingWriteTimeout {
+		rw.slidingTimeout = srv.WriteTimeout
+	}
 
-	handler := srv.Handler
-	if handler == nil {
-		handler = NotFoundHandler()
+	if srv.DefaultStatus != 0 {
+		rw.statusCode, rw.metadata = srv.DefaultStatus, srv.DefaultMeta
 	}
 
-	handler.ServeGemini(&rw, &req)
+	defer func() {
+		_ = rw.writeHeader()
+		info := ResponseInfo{
+			StatusCode:   rw.statusCode,
+			Meta:         rw.metadata,
+			BytesWritten: rw.written,
+			Duration:     time.Since(start),
+			Err:          rw.err,
+		}
+		req.hooks.run(info)
+		if srv.OnResponse != nil {
+			srv.OnResponse(&req, info)
+		}
+	}()
 
-	return nil
+	box := srv.handlers()
+	handler := box.chained
+	if !srv.servesHost(u) {
+		handler = box.proxy
+	}
+
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				srv.recoverPanic(conn, &rw, &req, v)
+			}
+		}()
+		if srv.OnRequest != nil {
+			srv.OnRequest(&req)
+		}
+		handler.ServeGemini(&rw, &req)
+	}()
+
+	if rw.hijacked {
+		return rw.statusCode, ErrHijacked
+	}
+
+	return rw.statusCode, nil
+}
+
+// servesHost reports whether the URL is for one of the hosts of the server.
+func (srv *Server) servesHost(u *url.URL) bool {
+	if len(srv.Hosts) == 0 || u.Host == "" && u.Scheme == "gemini" {
+		return true
+	} else if u.Scheme != "gemini" {
+		return false
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	_, rest, _ := strings.Cut(name, ".")
+
+	for _, host := range srv.Hosts {
+		host = strings.ToLower(host)
+		if host == name || rest != "" && host == "*."+rest {
+			return true
+		}
+	}
+
+	return false
+}
+
+func refuseProxy(w ResponseWriter, r *Request) {
+	w.WriteHeader(StatusProxyRequestRefused, "Proxy Request Refused")
+}
+
+func (srv *Server) recoverPanic(conn net.Conn, rw *responseWriter, r *Request, v any) {
+	err := fmt.Errorf("%v", v)
+	srv.logEvent(connEvent(conn, LogCategoryPanic, "recover", err), "gemproto: recover: %v", v)
+
+	if srv.RecoverHandler != nil {
+		srv.RecoverHandler(rw, r, v)
+	} else if !rw.wroteHeader {
+		rw.WriteHeader(StatusCGIError, "Internal Server Error")
+	}
+}
+
+// WriteResponseHeader writes the response header "<code> <meta>\r\n" to w,
+// so that servers with their own connection handling can reuse
+// the protocol formatting. It returns ErrInvalidResponse without
+// writing anything if the code is not between 10 and 69 or the meta
+// is not valid UTF-8 or contains CR or LF, and ErrHeaderTooLong
+// if the meta exceeds 1024 bytes.
+func WriteResponseHeader(w io.Writer, code int, meta string) error {
+	if code < 10 || code > 69 || !utf8.ValidString(meta) || strings.ContainsAny(meta, "\r\n") {
+		return ErrInvalidResponse
+	} else if len(meta) > 1024 {
+		return ErrHeaderTooLong
+	}
+	return reply(w, code, meta)
 }
 
 func reply(w io.Writer, code int, meta string) error {
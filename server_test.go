@@ -6,9 +6,13 @@ import (
 	"crypto/x509/pkix"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"path/filepath"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -205,3 +209,1200 @@ func TestServerRequestTooLong(t *testing.T) {
 	require.Equal(t, gemproto.StatusBadRequest, res.StatusCode)
 	require.Equal(t, "request line too long", res.Meta)
 }
+
+func TestServerAfterResponse(t *testing.T) {
+	t.Parallel()
+
+	infos := make(chan gemproto.ResponseInfo, 1)
+
+	h := gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		require.True(t, gemproto.AfterResponse(r, func(info gemproto.ResponseInfo) {
+			infos <- info
+		}))
+		w.WriteHeader(gemproto.StatusOK, "text/plain")
+		_, _ = w.Write([]byte("hello "))
+		_, _ = w.Write([]byte("world"))
+	})
+
+	s := gemtest.NewServer(h)
+	defer s.Close()
+
+	c := gemproto.Client{}
+	res, err := c.Get(s.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	info := <-infos
+	require.Equal(t, gemproto.StatusOK, info.StatusCode)
+	require.Equal(t, "text/plain", info.Meta)
+	require.Equal(t, int64(11), info.BytesWritten)
+	require.NoError(t, info.Err)
+	require.True(t, info.Duration > 0)
+
+	require.True(t, !gemproto.AfterResponse(gemtest.NewRequest("/"), func(gemproto.ResponseInfo) {}))
+}
+
+func TestServerDefaultMeta(t *testing.T) {
+	t.Parallel()
+
+	h := gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = w.Write([]byte("hello world"))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := gemproto.Server{
+		Handler:       h,
+		Insecure:      true,
+		DefaultStatus: gemproto.StatusOK,
+		DefaultMeta:   "text/plain",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/plain\r\nhello world", string(body))
+}
+
+func TestServerOnListen(t *testing.T) {
+	t.Parallel()
+
+	addrs := make(chan net.Addr, 1)
+
+	s := gemproto.Server{
+		Addr:     "127.0.0.1:0",
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = w.Write([]byte("hello world"))
+		}),
+		OnListen: func(addr net.Addr) { addrs <- addr },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.ListenAndServe(ctx) }()
+
+	addr := <-addrs
+	require.True(t, addr.(*net.TCPAddr).Port != 0)
+
+	conn, err := net.Dial("tcp", addr.String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nhello world", string(body))
+}
+
+func TestServerMaxConns(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s := gemproto.Server{
+		Insecure: true,
+		MaxConns: 1,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			close(started)
+			<-release
+		}),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	get := func() (net.Conn, error) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+		_, err = conn.Write([]byte("/\r\n"))
+		return conn, err
+	}
+
+	first, err := get()
+	require.NoError(t, err)
+	defer first.Close()
+	<-started
+
+	second, err := get()
+	require.NoError(t, err)
+	defer second.Close()
+	body, err := io.ReadAll(second)
+	require.NoError(t, err)
+	require.Equal(t, "41 too many connections\r\n", string(body))
+
+	// an idle connection occupies the only rejection slot,
+	// so the next connection is closed without a response
+	idle, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer idle.Close()
+
+	third, err := get()
+	require.NoError(t, err)
+	defer third.Close()
+	body, _ = io.ReadAll(third)
+	require.Equal(t, "", string(body))
+
+	close(release)
+	body, err = io.ReadAll(first)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\n", string(body))
+}
+
+func TestServerConnState(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		DNSNames: []string{"localhost"},
+	})
+	require.NoError(t, err)
+
+	var states []gemproto.ConnState
+	closed := make(chan struct{})
+
+	s := gemproto.Server{
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}),
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		},
+		ConnState: func(conn net.Conn, state gemproto.ConnState) {
+			states = append(states, state)
+			if state == gemproto.StateClosed {
+				close(closed)
+			}
+		},
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	client := gemproto.Client{}
+	res, err := client.Get("gemini://" + l.Addr().String() + "/")
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	<-closed
+	require.Equal(t, []gemproto.ConnState{
+		gemproto.StateNew,
+		gemproto.StateHandshake,
+		gemproto.StateActive,
+		gemproto.StateClosed,
+	}, states)
+	require.Equal(t, "handshake", gemproto.StateHandshake.String())
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	ready := make(chan struct{})
+
+	s := gemproto.Server{
+		Network:  "unix",
+		Addr:     filepath.Join(t.TempDir(), "gemini.sock"),
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = w.Write([]byte("hello world"))
+		}),
+		OnListen: func(net.Addr) { close(ready) },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.ListenAndServe(ctx) }()
+	<-ready
+
+	conn, err := net.Dial("unix", s.Addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("gemini://localhost/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nhello world", string(body))
+}
+
+func TestServerBaseContextConnContext(t *testing.T) {
+	t.Parallel()
+
+	tenantKey := gemproto.NewContextKey[string]("tenant")
+	connKey := gemproto.NewContextKey[string]("conn")
+
+	var s gemproto.Server
+	s = gemproto.Server{
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			tenant, _ := gemproto.FromContext(r.Context(), tenantKey)
+			conn, _ := gemproto.FromContext(r.Context(), connKey)
+			srv, _ := gemproto.FromContext(r.Context(), gemproto.ServerContextKey)
+			require.True(t, srv == &s)
+			_, _ = fmt.Fprintf(w, "%s %s", tenant, conn)
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.BaseContext = func(l net.Listener) context.Context {
+		return context.WithValue(ctx, tenantKey, "acme")
+	}
+	s.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		return context.WithValue(ctx, connKey, "conn")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nacme conn", string(body))
+}
+
+func TestServerRecoverHandler(t *testing.T) {
+	t.Parallel()
+
+	roundTrip := func(s *gemproto.Server) string {
+		s.Insecure = true
+		s.Handler = gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			panic("oops")
+		})
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() { _ = s.Serve(ctx, l) }()
+
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("/\r\n"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	logger := mockLogger{}
+	require.Equal(t, "42 Internal Server Error\r\n", roundTrip(&gemproto.Server{Logger: &logger}))
+	require.Equal(t, []string{"gemproto: recover: oops"}, logger.Logs)
+
+	var stack []byte
+	body := roundTrip(&gemproto.Server{
+		RecoverHandler: func(w gemproto.ResponseWriter, r *gemproto.Request, v any) {
+			stack = debug.Stack()
+			w.WriteHeader(gemproto.StatusTemporaryFailure, fmt.Sprint("recovered ", v))
+		},
+	})
+	require.Equal(t, "40 recovered oops\r\n", body)
+	require.True(t, strings.Contains(string(stack), "TestServerRecoverHandler"))
+}
+
+func TestServerMaxRequestBytes(t *testing.T) {
+	t.Parallel()
+
+	s := gemproto.Server{
+		Insecure:        true,
+		MaxRequestBytes: 4096,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = fmt.Fprint(w, len(r.RequestURI))
+		}),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	roundTrip := func(rawURL string) string {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte(rawURL + "\r\n"))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(conn)
+		return string(body)
+	}
+
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\n4096", roundTrip("/"+strings.Repeat("a", 4095)))
+	require.Equal(t, "59 request line too long\r\n", roundTrip("/"+strings.Repeat("a", 4096)))
+}
+
+func TestServerHandshakeTimeout(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		Duration: 1 * time.Hour,
+		DNSNames: []string{"localhost"},
+	})
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := gemproto.Server{
+		ReadTimeout:      10 * time.Second,
+		HandshakeTimeout: 50 * time.Millisecond,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// stall the handshake by never sending the client hello
+	start := time.Now()
+	_ = conn.SetDeadline(start.Add(3 * time.Second))
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	require.True(t, time.Since(start) < time.Second, "handshake did not time out")
+}
+
+func TestServerHandshakeTimeoutReplacesReadTimeout(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		Duration: 1 * time.Hour,
+		DNSNames: []string{"localhost"},
+	})
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := gemproto.Server{
+		ReadTimeout:      100 * time.Millisecond,
+		HandshakeTimeout: 5 * time.Second,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = io.WriteString(w, "ok")
+		}),
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// a handshake slower than ReadTimeout is limited by HandshakeTimeout only
+	time.Sleep(300 * time.Millisecond)
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: "localhost"})
+	_ = tlsConn.SetDeadline(time.Now().Add(3 * time.Second))
+	require.NoError(t, tlsConn.Handshake())
+
+	_, err = tlsConn.Write([]byte("gemini://localhost/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(tlsConn)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nok", string(body))
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	s := gemproto.Server{
+		Insecure:    true,
+		ReadTimeout: 10 * time.Second,
+		IdleTimeout: 50 * time.Millisecond,
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	// idle connections are closed
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	start := time.Now()
+	_ = conn.SetDeadline(start.Add(3 * time.Second))
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "", string(body))
+	require.True(t, time.Since(start) < time.Second, "idle connection was not closed")
+
+	// slow requests are governed by ReadTimeout once the first byte arrived
+	conn2, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	_ = conn2.SetDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn2.Write([]byte("/"))
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = conn2.Write([]byte("\r\n"))
+	require.NoError(t, err)
+	body, err = io.ReadAll(conn2)
+	require.NoError(t, err)
+	require.Equal(t, "51 Not Found\r\n", string(body))
+}
+
+func TestServerFlush(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	s := gemproto.Server{
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			w.WriteHeader(gemproto.StatusOK, "text/plain")
+			f, ok := w.(gemproto.Flusher)
+			require.True(t, ok)
+			f.Flush()
+			<-release
+			_, _ = io.WriteString(w, "done")
+		}),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn.Write([]byte("/\r\n"))
+	require.NoError(t, err)
+
+	// the header arrives while the handler is still running
+	header := make([]byte, len("20 text/plain\r\n"))
+	_, err = io.ReadFull(conn, header)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/plain\r\n", string(header))
+
+	close(release)
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "done", string(body))
+}
+
+func TestServerHijack(t *testing.T) {
+	t.Parallel()
+
+	hijacked := make(chan struct{})
+
+	s := gemproto.Server{
+		Insecure: true,
+		ConnState: func(_ net.Conn, state gemproto.ConnState) {
+			require.True(t, state != gemproto.StateClosed, "hijacked connection was closed")
+			if state == gemproto.StateHijacked {
+				close(hijacked)
+			}
+		},
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			w.WriteHeader(gemproto.StatusOK, "application/x-echo")
+			conn, err := w.(gemproto.Hijacker).Hijack()
+			require.NoError(t, err)
+
+			_, err = w.Write([]byte("x"))
+			require.ErrorIs(t, err, gemproto.ErrHijacked)
+
+			// echo in a separate goroutine to show that the server
+			// does not close the connection when the handler returns
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn.Write([]byte("/\r\n"))
+	require.NoError(t, err)
+
+	header := "20 application/x-echo\r\n"
+	buf := make([]byte, len(header)+len("ping"))
+	_, err = io.ReadFull(conn, buf[:len(header)])
+	require.NoError(t, err)
+	require.Equal(t, header, string(buf[:len(header)]))
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(conn, buf[len(header):])
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[len(header):]))
+
+	select {
+	case <-hijacked:
+	case <-time.After(3 * time.Second):
+		t.Fatal("connection did not enter StateHijacked")
+	}
+}
+
+func TestServerResponseStats(t *testing.T) {
+	t.Parallel()
+
+	type stats struct {
+		code    int
+		meta    string
+		written int64
+	}
+
+	result := make(chan stats, 1)
+
+	s := gemproto.Server{
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			w.WriteHeader(gemproto.StatusOK, "text/plain")
+			_, _ = io.WriteString(w, "hello")
+			// ignored because the header has been written
+			w.WriteHeader(gemproto.StatusNotFound, "Not Found")
+
+			rs := w.(gemproto.ResponseStats)
+			code, meta := rs.Status()
+			result <- stats{code, meta, rs.BytesWritten()}
+		}),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn.Write([]byte("/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/plain\r\nhello", string(body))
+	require.Equal(t, stats{gemproto.StatusOK, "text/plain", 5}, <-result)
+}
+
+func TestServerMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tag := func(s string) func(gemproto.Handler) gemproto.Handler {
+		return func(next gemproto.Handler) gemproto.Handler {
+			return gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+				_, _ = io.WriteString(w, s)
+				next.ServeGemini(w, r)
+			})
+		}
+	}
+
+	s := gemproto.Server{
+		Insecure:   true,
+		Middleware: []func(gemproto.Handler) gemproto.Handler{tag("a"), tag("b")},
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = io.WriteString(w, "c")
+		}),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nabc", string(body))
+}
+
+type handshakeErrors chan error
+
+func (handshakeErrors) ConnAccepted()                    {}
+func (handshakeErrors) RequestServed(int, time.Duration) {}
+func (ch handshakeErrors) HandshakeFailed(err error)     { ch <- err }
+
+func TestServerMaxHandshakes(t *testing.T) {
+	t.Parallel()
+
+	cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+		Duration: 1 * time.Hour,
+		DNSNames: []string{"localhost"},
+	})
+	require.NoError(t, err)
+
+	errs := make(handshakeErrors, 3)
+
+	s := gemproto.Server{
+		MaxHandshakes:    1,
+		HandshakeTimeout: 300 * time.Millisecond,
+		Stats:            errs,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	// the first stalled connection holds the only slot until its handshake
+	// times out, then the second one holds it, so the third one
+	// times out waiting in the queue
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var queued int
+	for i := 0; i < 3; i++ {
+		if err := <-errs; errors.Is(err, gemproto.ErrHandshakeQueueTimeout) {
+			queued++
+		}
+	}
+	require.Equal(t, 1, queued)
+
+	// handshakes succeed again once the slot is free
+	client := gemproto.Client{}
+	res, err := client.Get("gemini://" + l.Addr().String())
+	require.NoError(t, err)
+	res.Body.Close()
+}
+
+// serveLoopback serves s on a loopback listener until the test ends.
+// The returned stop function cancels the server and waits for Serve to return.
+func serveLoopback(t *testing.T, s *gemproto.Server) (addr string, stop func() error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, l) }()
+
+	var once sync.Once
+	var serveErr error
+	stop = func() error {
+		once.Do(func() {
+			cancel()
+			serveErr = <-done
+		})
+		return serveErr
+	}
+	t.Cleanup(func() { _ = stop() })
+
+	return l.Addr().String(), stop
+}
+
+// request sends a request line to addr and returns the raw response.
+// Read errors are ignored because servers may reset the connection.
+func request(t *testing.T, addr, line string) string {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(line + "\r\n"))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(conn)
+	return string(body)
+}
+
+func TestServerHosts(t *testing.T) {
+	t.Parallel()
+
+	s := gemproto.Server{
+		Insecure: true,
+		Hosts:    []string{"example.com", "*.example.org"},
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = io.WriteString(w, "ok")
+		}),
+	}
+
+	addr, _ := serveLoopback(t, &s)
+	get := func(rawURL string) string { return request(t, addr, rawURL) }
+
+	const ok = "20 text/gemini;charset=utf-8\r\nok"
+	const refused = "53 Proxy Request Refused\r\n"
+
+	require.Equal(t, ok, get("/"))
+	require.Equal(t, ok, get("gemini://example.com/"))
+	require.Equal(t, ok, get("gemini://EXAMPLE.com:1965/"))
+	require.Equal(t, ok, get("gemini://www.example.org/"))
+	require.Equal(t, refused, get("gemini://example.org/"))
+	require.Equal(t, refused, get("gemini://a.b.example.org/"))
+	require.Equal(t, refused, get("gemini://example.net/"))
+	require.Equal(t, refused, get("https://example.com/"))
+}
+
+func TestServerListenAndServeAddrs(t *testing.T) {
+	t.Parallel()
+
+	addrs := make(chan net.Addr, 2)
+
+	s := gemproto.Server{
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = io.WriteString(w, "ok")
+		}),
+		OnListen: func(addr net.Addr) { addrs <- addr },
+	}
+
+	sock := filepath.Join(t.TempDir(), "gemini.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAndServeAddrs(ctx, "tcp4:127.0.0.1:0", "unix:"+sock) }()
+
+	for i := 0; i < 2; i++ {
+		addr := <-addrs
+		conn, err := net.Dial(addr.Network(), addr.String())
+		require.NoError(t, err)
+
+		_, err = conn.Write([]byte("/\r\n"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		conn.Close()
+		require.Equal(t, "20 text/gemini;charset=utf-8\r\nok", string(body), addr.Network())
+	}
+
+	cancel()
+	require.ErrorIs(t, <-done, gemproto.ErrServerClosed)
+
+	err := s.ListenAndServeAddrs(context.Background(), "127.0.0.1:0", "unix:"+filepath.Join(t.TempDir(), "missing", "gemini.sock"))
+	require.True(t, err != nil && !errors.Is(err, gemproto.ErrServerClosed))
+}
+
+func TestServerOnRequestOnResponse(t *testing.T) {
+	t.Parallel()
+
+	requests := make(chan string, 1)
+	responses := make(chan gemproto.ResponseInfo, 1)
+
+	s := gemproto.Server{
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = io.WriteString(w, "hello")
+		}),
+		OnRequest: func(r *gemproto.Request) {
+			requests <- r.URL.Path
+		},
+		OnResponse: func(r *gemproto.Request, info gemproto.ResponseInfo) {
+			responses <- info
+		},
+	}
+
+	addr, _ := serveLoopback(t, &s)
+	_ = request(t, addr, "/index.gmi")
+
+	require.Equal(t, "/index.gmi", <-requests)
+
+	info := <-responses
+	require.Equal(t, gemproto.StatusOK, info.StatusCode)
+	require.Equal(t, "text/gemini;charset=utf-8", info.Meta)
+	require.Equal(t, int64(5), info.BytesWritten)
+}
+
+func TestServerSetHandler(t *testing.T) {
+	t.Parallel()
+
+	text := func(s string) gemproto.Handler {
+		return gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = io.WriteString(w, s)
+		})
+	}
+
+	s := gemproto.Server{
+		Insecure: true,
+		Handler:  text("old"),
+	}
+
+	addr, _ := serveLoopback(t, &s)
+	get := func() string { return request(t, addr, "/") }
+
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nold", get())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			s.SetHandler(text("new"))
+		}
+	}()
+	_ = get()
+	<-done
+
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nnew", get())
+
+	s.SetHandler(nil)
+	require.Equal(t, "51 Not Found\r\n", get())
+}
+
+func TestServerDrain(t *testing.T) {
+	t.Parallel()
+
+	s := gemproto.Server{
+		Insecure:     true,
+		DrainTimeout: 200 * time.Millisecond,
+		DrainMeta:    "Restarting; retry in 10 seconds",
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = io.WriteString(w, "ok")
+		}),
+	}
+
+	addr, stop := serveLoopback(t, &s)
+	get := func() string { return request(t, addr, "/") }
+
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nok", get())
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- stop() }()
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, "41 Restarting; retry in 10 seconds\r\n", get())
+
+	require.ErrorIs(t, <-done, gemproto.ErrServerClosed)
+	require.True(t, time.Since(start) >= s.DrainTimeout)
+
+	_, err := net.Dial("tcp", addr)
+	require.True(t, err != nil)
+}
+
+func TestServerDrainMaxConns(t *testing.T) {
+	t.Parallel()
+
+	s := gemproto.Server{
+		Insecure:     true,
+		MaxConns:     1,
+		DrainTimeout: 500 * time.Millisecond,
+		Handler:      gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}),
+	}
+
+	addr, stop := serveLoopback(t, &s)
+
+	done := make(chan error, 1)
+	go func() { done <- stop() }()
+	time.Sleep(10 * time.Millisecond)
+
+	// an idle connection holds the only rejection slot,
+	// so further connections are closed without a response
+	idle, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	require.Equal(t, "", request(t, addr, "/"))
+
+	_, err = idle.Write([]byte("/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(idle)
+	require.NoError(t, err)
+	idle.Close()
+	require.Equal(t, "41 Server Unavailable\r\n", string(body))
+
+	require.ErrorIs(t, <-done, gemproto.ErrServerClosed)
+}
+
+func TestServerSlowDown(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s := gemproto.Server{
+		Insecure: true,
+		MaxConns: 1,
+		SlowDown: 1500 * time.Millisecond,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			close(started)
+			<-release
+		}),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	get := func() (net.Conn, error) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+		_, err = conn.Write([]byte("/\r\n"))
+		return conn, err
+	}
+
+	first, err := get()
+	require.NoError(t, err)
+	defer first.Close()
+	<-started
+
+	second, err := get()
+	require.NoError(t, err)
+	defer second.Close()
+	body, err := io.ReadAll(second)
+	require.NoError(t, err)
+	require.Equal(t, "44 2\r\n", string(body))
+
+	close(release)
+}
+
+func TestServerSlidingWriteTimeout(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 100 * time.Millisecond
+
+	serve := func(sliding bool) string {
+		s := gemproto.Server{
+			Insecure:            true,
+			WriteTimeout:        timeout,
+			SlidingWriteTimeout: sliding,
+			Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+				for i := 0; i < 5; i++ {
+					if _, err := io.WriteString(w, "x"); err != nil {
+						return
+					}
+					time.Sleep(timeout / 2)
+				}
+			}),
+		}
+
+		addr, _ := serveLoopback(t, &s)
+		return request(t, addr, "/")
+	}
+
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nxxxxx", serve(true))
+	require.True(t, serve(false) != "20 text/gemini;charset=utf-8\r\nxxxxx")
+}
+
+func TestServerSlidingWriteTimeoutFlush(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 100 * time.Millisecond
+
+	s := gemproto.Server{
+		Insecure:            true,
+		WriteTimeout:        timeout,
+		SlidingWriteTimeout: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			// a slow start followed by a stream that only flushes
+			time.Sleep(timeout * 3 / 2)
+			for i := 0; i < 4; i++ {
+				w.(gemproto.Flusher).Flush()
+				time.Sleep(timeout / 2)
+			}
+			_, _ = io.WriteString(w, "done")
+		}),
+	}
+
+	addr, _ := serveLoopback(t, &s)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\ndone", request(t, addr, "/"))
+}
+
+func TestServerContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	const timeout = time.Minute
+
+	serve := func(sliding bool) string {
+		s := gemproto.Server{
+			Insecure:            true,
+			WriteTimeout:        timeout,
+			SlidingWriteTimeout: sliding,
+			Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+				deadline, ok := r.Context().Deadline()
+				if !ok {
+					_, _ = io.WriteString(w, "none")
+					return
+				}
+				if left := time.Until(deadline); left > 0 && left <= timeout {
+					_, _ = io.WriteString(w, "ok")
+				}
+			}),
+		}
+
+		addr, _ := serveLoopback(t, &s)
+		return request(t, addr, "/")
+	}
+
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nok", serve(false))
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nnone", serve(true))
+}
+
+func TestWriteResponseHeader(t *testing.T) {
+	t.Parallel()
+
+	var sb strings.Builder
+	require.NoError(t, gemproto.WriteResponseHeader(&sb, gemproto.StatusOK, "text/plain"))
+	require.Equal(t, "20 text/plain\r\n", sb.String())
+
+	for _, tt := range []struct {
+		code int
+		meta string
+		err  error
+	}{
+		{9, "", gemproto.ErrInvalidResponse},
+		{70, "", gemproto.ErrInvalidResponse},
+		{gemproto.StatusOK, "text/plain\r\n\r\n", gemproto.ErrInvalidResponse},
+		{gemproto.StatusOK, "\xff", gemproto.ErrInvalidResponse},
+		{gemproto.StatusOK, strings.Repeat("x", 1025), gemproto.ErrHeaderTooLong},
+	} {
+		sb.Reset()
+		require.ErrorIs(t, gemproto.WriteResponseHeader(&sb, tt.code, tt.meta), tt.err)
+		require.Equal(t, "", sb.String())
+	}
+}
+
+func TestServerServeConn(t *testing.T) {
+	t.Parallel()
+
+	// TLS connections require certificates
+	client, server := net.Pipe()
+	defer client.Close()
+	err := (&gemproto.Server{TLSConfig: &tls.Config{}}).ServeConn(context.Background(), server)
+	require.True(t, err != nil, "expected an error")
+
+	var state gemproto.ConnState
+
+	s := gemproto.Server{
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			srv, _ := gemproto.FromContext(r.Context(), gemproto.ServerContextKey)
+			_, _ = io.WriteString(w, r.URL.String())
+			if srv == nil {
+				_, _ = io.WriteString(w, " without server")
+			}
+		}),
+		ConnState: func(conn net.Conn, s gemproto.ConnState) { state = s },
+	}
+
+	client, server = net.Pipe()
+	defer client.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.ServeConn(context.Background(), server)
+	}()
+
+	_, err = client.Write([]byte("gemini://localhost/\r\n"))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(client)
+	<-done
+
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\ngemini://localhost/", string(body))
+	require.Equal(t, gemproto.StateClosed, state)
+}
+
+func TestServerContextValues(t *testing.T) {
+	t.Parallel()
+
+	var logger mockLogger
+
+	s := gemproto.Server{
+		Insecure: true,
+		Logger:   &logger,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			id, _ := gemproto.FromContext(r.Context(), gemproto.RequestIDKey)
+			l, _ := gemproto.FromContext(r.Context(), gemproto.LoggerKey)
+			l.Printf("%s", id)
+		}),
+	}
+
+	for i := 0; i < 2; i++ {
+		client, server := net.Pipe()
+		go func() { _ = s.ServeConn(context.Background(), server) }()
+		_, err := client.Write([]byte("gemini://localhost/\r\n"))
+		require.NoError(t, err)
+		_, _ = io.ReadAll(client)
+		client.Close()
+	}
+
+	require.Equal(t, 2, len(logger.Logs))
+	require.True(t, logger.Logs[0] != "" && logger.Logs[0] != logger.Logs[1], logger.Logs)
+}
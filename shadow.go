@@ -0,0 +1,216 @@
+package gemproto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/askeladdk/gemproto/gemtext"
+)
+
+// Shadow is a middleware that duplicates requests to a secondary
+// Handler and logs the differences between its response and the
+// response of the primary handler. Only the primary response
+// is sent to the client. It allows a new implementation of a capsule,
+// such as a dynamic application replacing a FileServer,
+// to be tested against real traffic without risking regressions:
+//
+//	shadow := &gemproto.Shadow{Handler: app}
+//	srv.Handler = shadow.Middleware(fileServer)
+//
+// The secondary handler is served in the background after the primary
+// response has been written, so it neither delays the response nor holds
+// the connection open. It receives a copy of the request whose context
+// carries the same values but is never cancelled. Requests are not
+// duplicated while MaxConcurrent secondary requests are in flight.
+// Both bodies are kept in memory for comparison.
+type Shadow struct {
+	// Handler is the secondary handler.
+	Handler Handler
+
+	// Match selects the requests that are duplicated.
+	// All requests are duplicated if it is nil.
+	Match func(*Request) bool
+
+	// Logger receives the differences. Defaults to the Logger stored
+	// under LoggerKey in the request context. Nothing is logged
+	// if neither is set.
+	Logger Logger
+
+	// MaxConcurrent limits the number of secondary requests
+	// that are served at the same time. Defaults to 16.
+	MaxConcurrent int
+
+	sem  chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// detachedContext carries the values of its parent
+// but is never cancelled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (ctx detachedContext) Value(key any) any       { return ctx.parent.Value(key) }
+
+// shadowRecorder records a response.
+type shadowRecorder struct {
+	statusCode  int
+	meta        string
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (w *shadowRecorder) WriteHeader(statusCode int, meta string) {
+	// like the Server, the header can be changed until the first Write
+	if !w.wroteHeader {
+		w.statusCode, w.meta = statusCode, meta
+	}
+}
+
+func (w *shadowRecorder) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(p)
+}
+
+// shadowWriter records the primary response while writing it.
+type shadowWriter struct {
+	ResponseWriter
+	rec      shadowRecorder
+	hijacked bool
+}
+
+func (w *shadowWriter) WriteHeader(statusCode int, meta string) {
+	w.rec.WriteHeader(statusCode, meta)
+	w.ResponseWriter.WriteHeader(statusCode, meta)
+}
+
+func (w *shadowWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	_, _ = w.rec.Write(p[:n])
+	return n, err
+}
+
+// Flush implements Flusher.
+func (w *shadowWriter) Flush() {
+	flush(w.ResponseWriter)
+}
+
+// Hijack implements Hijacker.
+// Hijacked responses cannot be compared.
+func (w *shadowWriter) Hijack() (net.Conn, error) {
+	w.hijacked = true
+	return hijack(w.ResponseWriter)
+}
+
+// diffResponses returns the differences between the primary and secondary responses.
+func diffResponses(primary, secondary *shadowRecorder) []string {
+	var diffs []string
+	if primary.statusCode != secondary.statusCode {
+		diffs = append(diffs, fmt.Sprintf("status %d != %d", primary.statusCode, secondary.statusCode))
+	}
+	if primary.meta != secondary.meta {
+		diffs = append(diffs, fmt.Sprintf("meta %q != %q", primary.meta, secondary.meta))
+	}
+	if !bytes.Equal(primary.body.Bytes(), secondary.body.Bytes()) {
+		diffs = append(diffs, fmt.Sprintf("body differs (%d != %d bytes)", primary.body.Len(), secondary.body.Len()))
+	}
+	return diffs
+}
+
+// serveShadow serves r with the secondary handler and logs
+// the differences with the primary response.
+func (s *Shadow) serveShadow(r *Request, primary *shadowRecorder) {
+	logger := contextLogger(r.Context(), s.Logger)
+
+	defer func() {
+		if v := recover(); v != nil && logger != nil {
+			logger.Printf("gemproto: shadow: %s: panic: %v", r.URL, v)
+		}
+	}()
+
+	secondary := shadowRecorder{
+		statusCode: StatusOK,
+		meta:       gemtext.MIMEType,
+	}
+
+	s.Handler.ServeGemini(&secondary, r)
+
+	if logger == nil {
+		return
+	}
+
+	for _, d := range diffResponses(primary, &secondary) {
+		logger.Printf("gemproto: shadow: %s: %s", r.URL, d)
+	}
+}
+
+// acquire reserves a slot for a secondary request.
+// It reports false if MaxConcurrent requests are in flight.
+func (s *Shadow) acquire() bool {
+	s.once.Do(func() {
+		n := s.MaxConcurrent
+		if n <= 0 {
+			n = 16
+		}
+		s.sem = make(chan struct{}, n)
+	})
+
+	select {
+	case s.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until all secondary requests in flight have been served.
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+// Middleware duplicates the requests served by next to the secondary handler.
+func (s *Shadow) Middleware(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if s.Match != nil && !s.Match(r) {
+			next.ServeGemini(w, r)
+			return
+		}
+
+		sw := shadowWriter{
+			ResponseWriter: w,
+			rec: shadowRecorder{
+				statusCode: StatusOK,
+				meta:       gemtext.MIMEType,
+			},
+		}
+
+		next.ServeGemini(&sw, r)
+
+		if sw.hijacked || !s.acquire() {
+			return
+		}
+
+		// the copy outlives the connection, so it must not
+		// share its cancellation or response hooks
+		u := *r.URL
+		r2 := *r
+		r2.URL = &u
+		r2.ctx = detachedContext{r.Context()}
+		r2.hooks = nil
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() { <-s.sem }()
+			s.serveShadow(&r2, &sw.rec)
+		}()
+	})
+}
@@ -0,0 +1,93 @@
+package gemproto_test
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestShadow(t *testing.T) {
+	t.Parallel()
+
+	primary := gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		_, _ = io.WriteString(w, "# Hello\n")
+	})
+
+	secondary := gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		switch r.URL.Path {
+		case "/same":
+			_, _ = io.WriteString(w, "# Hello\n")
+		case "/panic":
+			panic("oops")
+		default:
+			w.WriteHeader(gemproto.StatusNotFound, "Not Found")
+		}
+	})
+
+	var logger mockLogger
+	shadow := gemproto.Shadow{
+		Handler: secondary,
+		Match:   func(r *gemproto.Request) bool { return r.URL.Path != "/skip" },
+		Logger:  &logger,
+	}
+
+	h := shadow.Middleware(primary)
+
+	for _, path := range []string{"/same", "/skip", "/panic", "/different"} {
+		w := gemtest.NewRecorder()
+		h.ServeGemini(w, gemtest.NewRequest(path))
+		shadow.Wait()
+		require.Equal(t, gemproto.StatusOK, w.Code)
+		require.Equal(t, "# Hello\n", w.Body.String())
+	}
+
+	require.Equal(t, []string{
+		"gemproto: shadow: gemini:///panic: panic: oops",
+		"gemproto: shadow: gemini:///different: status 20 != 51",
+		"gemproto: shadow: gemini:///different: meta \"text/gemini;charset=utf-8\" != \"Not Found\"",
+		"gemproto: shadow: gemini:///different: body differs (8 != 0 bytes)",
+	}, logger.Logs)
+}
+
+func TestShadowDetached(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+
+	release := make(chan struct{})
+	var calls int32
+
+	shadow := gemproto.Shadow{
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			if r.Context().Err() != nil || r.Context().Value(key{}) != "value" {
+				w.WriteHeader(gemproto.StatusTemporaryFailure, "Detached")
+			}
+		}),
+		MaxConcurrent: 1,
+		Logger:        &mockLogger{},
+	}
+
+	h := shadow.Middleware(gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {}))
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+
+	// the primary response does not wait for the secondary handler
+	h.ServeGemini(gemtest.NewRecorder(), gemtest.NewRequest("/").WithContext(ctx))
+	cancel()
+
+	// the second request is not duplicated while the first is in flight
+	h.ServeGemini(gemtest.NewRecorder(), gemtest.NewRequest("/"))
+
+	close(release)
+	shadow.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.Equal(t, 0, len(shadow.Logger.(*mockLogger).Logs))
+}
@@ -0,0 +1,65 @@
+package gemproto
+
+import "github.com/askeladdk/gemproto/gemtext"
+
+func visibleRoutes(mux *ServeMux) []RouteInfo {
+	routes := mux.Routes()
+	visible := routes[:0]
+	for _, route := range routes {
+		if !route.Hidden {
+			visible = append(visible, route)
+		}
+	}
+	return visible
+}
+
+func routeURL(pattern string) string {
+	if pattern[0] == '/' {
+		return pattern
+	}
+	return "gemini://" + pattern
+}
+
+// SitemapHandler returns a Handler that renders a human-readable gemtext
+// page linking to all routes registered with mux that are not hidden.
+// Routes are labelled with their description if one was provided
+// with ServeMux.HandleWithInfo.
+func SitemapHandler(mux *ServeMux, title string) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		b := gemtext.NewBuilder(make([]byte, 0, 1024))
+
+		if title != "" {
+			b.Heading(title)
+		}
+
+		for _, route := range visibleRoutes(mux) {
+			b.Link(routeURL(route.Pattern), route.Description)
+		}
+
+		_, _ = w.Write(b.Bytes())
+	})
+}
+
+// SitemapIndexHandler returns a Handler that renders a machine-readable
+// JSON index of all routes registered with mux that are not hidden.
+func SitemapIndexHandler(mux *ServeMux) Handler {
+	type indexEntry struct {
+		Pattern     string `json:"pattern"`
+		URL         string `json:"url"`
+		Description string `json:"description,omitempty"`
+	}
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		routes := visibleRoutes(mux)
+		index := make([]indexEntry, 0, len(routes))
+		for _, route := range routes {
+			index = append(index, indexEntry{
+				Pattern:     route.Pattern,
+				URL:         routeURL(route.Pattern),
+				Description: route.Description,
+			})
+		}
+
+		_ = JSON(w, index)
+	})
+}
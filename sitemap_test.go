@@ -0,0 +1,40 @@
+package gemproto_test
+
+import (
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestSitemap(t *testing.T) {
+	t.Parallel()
+
+	mux := gemproto.NewServeMux()
+	mux.HandleWithInfo("/about.gmi", gemproto.NotFoundHandler(), gemproto.RouteInfo{
+		Description: "About",
+	})
+	mux.HandleWithInfo("/admin/", gemproto.NotFoundHandler(), gemproto.RouteInfo{
+		Hidden: true,
+	})
+	mux.Handle("example.com/", gemproto.NotFoundHandler())
+	mux.HandleWithInfo("/sitemap.gmi", gemproto.SitemapHandler(mux, "Sitemap"), gemproto.RouteInfo{
+		Hidden: true,
+	})
+	mux.HandleWithInfo("/sitemap.json", gemproto.SitemapIndexHandler(mux), gemproto.RouteInfo{
+		Hidden: true,
+	})
+
+	w := gemtest.NewRecorder()
+	mux.ServeGemini(w, gemtest.NewRequest("/sitemap.gmi"))
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "# Sitemap\n=> /about.gmi About\n=> gemini://example.com/\n", w.Body.String())
+
+	w = gemtest.NewRecorder()
+	mux.ServeGemini(w, gemtest.NewRequest("/sitemap.json"))
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Meta)
+	require.Equal(t, `[{"pattern":"/about.gmi","url":"/about.gmi","description":"About"},`+
+		`{"pattern":"example.com/","url":"gemini://example.com/"}]`, w.Body.String())
+}
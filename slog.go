@@ -0,0 +1,52 @@
+//go:build go1.21
+
+package gemproto
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SlogLogger adapts a slog.Logger to an EventLogger,
+// so that Server diagnostics are logged with structured attributes.
+//
+//	srv := gemproto.Server{
+//	  Logger: gemproto.SlogLogger(slog.Default()),
+//	}
+func SlogLogger(l *slog.Logger) EventLogger {
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Printf(format string, v ...any) {
+	s.l.Info(fmt.Sprintf(format, v...))
+}
+
+func (s slogLogger) LogEvent(e LogEvent) {
+	attrs := make([]slog.Attr, 0, 5)
+	attrs = append(attrs, slog.String("category", e.Category))
+
+	if e.RemoteAddr != "" {
+		attrs = append(attrs, slog.String("remote_addr", e.RemoteAddr))
+	}
+
+	if e.ServerName != "" {
+		attrs = append(attrs, slog.String("server_name", e.ServerName))
+	}
+
+	if e.StatusCode != 0 {
+		attrs = append(attrs, slog.Int("status", e.StatusCode))
+	}
+
+	level := slog.LevelInfo
+	if e.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+
+	s.l.LogAttrs(context.Background(), level, e.Message, attrs...)
+}
@@ -0,0 +1,60 @@
+//go:build go1.21
+
+package gemproto_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestServerSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf syncBuffer
+	logged := make(chan struct{})
+
+	s := gemproto.Server{
+		Insecure: true,
+		Logger:   gemproto.SlogLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		ConnState: func(conn net.Conn, state gemproto.ConnState) {
+			if state == gemproto.StateClosed {
+				close(logged)
+			}
+		},
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	// close the connection before sending a complete request
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	_, _ = conn.Write([]byte("gemini://"))
+	conn.Close()
+
+	select {
+	case <-logged:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	var entry map[string]string
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "ERROR", entry["level"])
+	require.Equal(t, "error", entry["msg"])
+	require.Equal(t, gemproto.LogCategoryRequest, entry["category"])
+	require.Equal(t, conn.LocalAddr().String(), entry["remote_addr"])
+	require.Equal(t, "EOF", entry["error"])
+}
@@ -0,0 +1,20 @@
+package gemproto
+
+import "time"
+
+// StatsCollector receives instrumentation events from a Server,
+// so that operators can monitor a capsule without patching the serve loop.
+// Methods are called concurrently from the goroutines serving connections
+// and must be safe for concurrent use.
+type StatsCollector interface {
+	// ConnAccepted is called when a connection is accepted.
+	ConnAccepted()
+
+	// HandshakeFailed is called when the TLS handshake fails.
+	HandshakeFailed(err error)
+
+	// RequestServed is called when a response has been sent,
+	// with the status code of the response and the time spent
+	// reading the request and serving the response.
+	RequestServed(statusCode int, latency time.Duration)
+}
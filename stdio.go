@@ -0,0 +1,76 @@
+package gemproto
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+)
+
+// stdioAddr is the address of a connection over standard input and output.
+type stdioAddr string
+
+func (a stdioAddr) Network() string { return "stdio" }
+func (a stdioAddr) String() string  { return string(a) }
+
+// stdioConn is a net.Conn that reads from in and writes to out.
+type stdioConn struct {
+	in, out *os.File
+	remote  net.Addr
+}
+
+func (c *stdioConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *stdioConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+func (c *stdioConn) LocalAddr() net.Addr         { return stdioAddr("stdio") }
+func (c *stdioConn) RemoteAddr() net.Addr        { return c.remote }
+
+func (c *stdioConn) Close() error {
+	err := c.in.Close()
+	if err2 := c.out.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+func (c *stdioConn) SetDeadline(t time.Time) error {
+	err := c.SetReadDeadline(t)
+	if err2 := c.SetWriteDeadline(t); err == nil {
+		err = err2
+	}
+	return err
+}
+
+func (c *stdioConn) SetReadDeadline(t time.Time) error  { return c.in.SetReadDeadline(t) }
+func (c *stdioConn) SetWriteDeadline(t time.Time) error { return c.out.SetWriteDeadline(t) }
+
+// StdioConn returns a connection that reads the request from in
+// and writes the response to out.
+//
+// If in is a socket, as is the case with inetd, the connection is
+// the socket itself. Otherwise in and out are treated as pipes,
+// as is the case with ucspi-tcp and s6 TLS terminators, and the remote
+// address is taken from the TCPREMOTEIP and TCPREMOTEPORT environment
+// variables if they are set.
+func StdioConn(in, out *os.File) net.Conn {
+	if conn, err := net.FileConn(in); err == nil {
+		return conn
+	}
+
+	remote := stdioAddr("stdio")
+	if ip := os.Getenv("TCPREMOTEIP"); ip != "" {
+		remote = stdioAddr(net.JoinHostPort(ip, os.Getenv("TCPREMOTEPORT")))
+	}
+
+	return &stdioConn{in: in, out: out, remote: remote}
+}
+
+// ServeStdio serves exactly one request over standard input and output
+// using the same Handler pipeline as Serve, which enables deployments
+// under inetd, tcpserver or s6 supervision. The request is read
+// in plain text if the Server is Insecure, as is the case
+// behind a TLS terminator, and over TLS otherwise.
+// It blocks until the request has been served and returns
+// the same errors as ServeConn.
+func (srv *Server) ServeStdio(ctx context.Context) error {
+	return srv.ServeConn(ctx, StdioConn(os.Stdin, os.Stdout))
+}
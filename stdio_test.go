@@ -0,0 +1,42 @@
+package gemproto_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestStdioConn(t *testing.T) {
+	t.Setenv("TCPREMOTEIP", "192.0.2.1")
+	t.Setenv("TCPREMOTEPORT", "4321")
+
+	inR, inW, err := os.Pipe()
+	require.NoError(t, err)
+	outR, outW, err := os.Pipe()
+	require.NoError(t, err)
+	defer outR.Close()
+
+	s := gemproto.Server{
+		Insecure: true,
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			_, _ = io.WriteString(w, r.RemoteAddr)
+		}),
+	}
+
+	_, err = inW.Write([]byte("gemini://localhost/\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, inW.Close())
+
+	conn := gemproto.StdioConn(inR, outW)
+	require.Equal(t, "192.0.2.1:4321", conn.RemoteAddr().String())
+
+	require.NoError(t, s.ServeConn(context.Background(), conn))
+
+	body, err := io.ReadAll(outR)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\n192.0.2.1:4321", string(body))
+}
@@ -0,0 +1,64 @@
+package gemproto
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+)
+
+// SystemdListeners returns the listeners passed to the process
+// by systemd socket activation, in the order they are configured
+// in the socket unit. It returns no listeners and no error if the
+// process was not socket activated. The environment variables used by
+// the protocol are unset so that they are not inherited by child processes.
+//
+// Each listener can be served with Server.Serve:
+//
+//	listeners, err := gemproto.SystemdListeners()
+//	if err != nil {
+//	  // handle error
+//	} else if len(listeners) == 0 {
+//	  // not socket activated
+//	}
+//	err = srv.Serve(ctx, listeners[0])
+func SystemdListeners() ([]net.Listener, error) {
+	const listenFdsStart = 3
+
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+
+	listeners := make([]net.Listener, 0, nfds)
+	for fd := listenFdsStart; fd < listenFdsStart+nfds; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		if f == nil {
+			return nil, errors.New("gemproto: invalid systemd file descriptor")
+		}
+
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,23 @@
+package gemproto_test
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestSystemdListenersNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := gemproto.SystemdListeners()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(listeners))
+
+	// the variables are unset so they are not inherited
+	_, ok := os.LookupEnv("LISTEN_FDS")
+	require.True(t, !ok)
+}
@@ -0,0 +1,61 @@
+package gemproto
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadOnionHostname returns the onion address that Tor writes to the
+// hostname file in the HiddenServiceDir of an onion service.
+// Tor only forwards the TCP stream to the local address that is
+// configured as the HiddenServicePort, and clients still negotiate TLS
+// end to end, so the capsule must serve TLS with a certificate
+// for the onion address:
+//
+//	onion, err := gemproto.ReadOnionHostname("/var/lib/tor/capsule")
+//	// ...
+//	cert, err := gemcert.LoadOrCreateX509KeyPair("onion.crt", "onion.key", gemcert.CreateOptions{
+//	  Duration: 10 * 365 * 24 * time.Hour,
+//	  DNSNames: []string{onion},
+//	})
+//	// ...
+//	srv := gemproto.Server{
+//	  Addr:     "127.0.0.1:1965",
+//	  Hostname: onion,
+//	  Handler:  mux,
+//	  TLSConfig: &tls.Config{
+//	    MinVersion:   tls.VersionTLS12,
+//	    Certificates: []tls.Certificate{cert},
+//	  },
+//	}
+//
+// Serving the onion service with Insecure requires a relay
+// in front of the server that terminates TLS.
+func ReadOnionHostname(hiddenServiceDir string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(hiddenServiceDir, "hostname"))
+	if err != nil {
+		return "", err
+	}
+
+	hostname := strings.TrimSpace(string(b))
+	if !strings.HasSuffix(hostname, ".onion") {
+		return "", errors.New("gemproto: invalid onion hostname " + hostname)
+	}
+
+	return hostname, nil
+}
+
+// OnionURL returns the URL of the requested resource on the onion host,
+// which lets a clearnet capsule advertise its onion service, for example
+// in a link line. The port is retained if the onion host has none.
+func OnionURL(r *Request, onion string) string {
+	u := *r.URL
+	u.Scheme = "gemini"
+	if port := u.Port(); port != "" && !strings.Contains(onion, ":") {
+		onion += ":" + port
+	}
+	u.Host = onion
+	return u.String()
+}
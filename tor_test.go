@@ -0,0 +1,58 @@
+package gemproto_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestReadOnionHostname(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	_, err := gemproto.ReadOnionHostname(dir)
+	require.True(t, err != nil)
+
+	const onion = "abcdefghijklmnopqrstuvwxyz234567abcdefghijklmnopqrstuvwx.onion"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hostname"), []byte(onion+"\n"), 0o600))
+
+	hostname, err := gemproto.ReadOnionHostname(dir)
+	require.NoError(t, err)
+	require.Equal(t, onion, hostname)
+}
+
+func TestOnionURL(t *testing.T) {
+	t.Parallel()
+
+	r := gemtest.NewRequest("gemini://example.com:1966/a/b?q")
+	require.Equal(t, "gemini://xyz.onion:1966/a/b?q", gemproto.OnionURL(r, "xyz.onion"))
+	require.Equal(t, "gemini://xyz.onion:1965/a/b?q", gemproto.OnionURL(r, "xyz.onion:1965"))
+}
+
+func TestServerHostname(t *testing.T) {
+	t.Parallel()
+
+	s := gemproto.Server{
+		Insecure: true,
+		Hostname: "xyz.onion",
+		Handler: gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			if r.URL.Path == "/old" {
+				gemproto.Redirect(w, r, "new", gemproto.StatusPermanentRedirect)
+				return
+			}
+			_, _ = io.WriteString(w, r.Host)
+		}),
+	}
+
+	addr, _ := serveLoopback(t, &s)
+	get := func(rawURL string) string { return request(t, addr, rawURL) }
+
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nxyz.onion", get("/"))
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\nexample.com", get("gemini://example.com:1966/"))
+	require.Equal(t, "31 gemini://xyz.onion/new\r\n", get("/old"))
+}
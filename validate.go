@@ -0,0 +1,117 @@
+package gemproto
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/askeladdk/gemproto/gemtext"
+)
+
+// ValidateOptions configures ValidateGemtext.
+type ValidateOptions struct {
+	// MaxLineLength is the maximum number of characters per line.
+	// Line lengths are not checked if it is not positive.
+	MaxLineLength int
+
+	// Logger receives the problems. Defaults to the Logger stored
+	// under LoggerKey in the request context. Nothing is logged
+	// if neither is set.
+	Logger Logger
+
+	// Strict buffers gemtext responses and replaces those
+	// with problems by 42 CGI ERROR, so that they fail loudly.
+	// Otherwise the response is sent as is.
+	Strict bool
+}
+
+// gemtextValidator records the body of successful gemtext responses.
+type gemtextValidator struct {
+	ResponseWriter
+	statusCode  int
+	meta        string
+	body        bytes.Buffer
+	strict      bool
+	wroteHeader bool
+}
+
+// checked reports whether the response is validated.
+func (w *gemtextValidator) checked() bool {
+	return w.statusCode == StatusOK && isGemtext(w.meta)
+}
+
+func (w *gemtextValidator) WriteHeader(statusCode int, meta string) {
+	// like the Server, the header can be changed until the first Write
+	if !w.wroteHeader {
+		w.statusCode, w.meta = statusCode, meta
+	}
+	w.ResponseWriter.WriteHeader(statusCode, meta)
+}
+
+func (w *gemtextValidator) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	if !w.checked() {
+		return w.ResponseWriter.Write(p)
+	}
+	w.body.Write(p)
+	if w.strict {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements Flusher.
+// Buffered responses are not flushed in strict mode.
+func (w *gemtextValidator) Flush() {
+	if !w.strict || !w.checked() {
+		flush(w.ResponseWriter)
+	}
+}
+
+// Hijack implements Hijacker.
+func (w *gemtextValidator) Hijack() (net.Conn, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// ValidateGemtext returns a middleware that checks the body of
+// successful text/gemini responses for structural problems
+// with gemtext.Validate and logs them. It is intended for development,
+// to catch rendering bugs before users see them, because it keeps
+// a copy of every gemtext response in memory.
+func ValidateGemtext(opts ValidateOptions) func(Handler) Handler {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			vw := gemtextValidator{
+				ResponseWriter: w,
+				statusCode:     StatusOK,
+				meta:           gemtext.MIMEType,
+				strict:         opts.Strict,
+			}
+
+			next.ServeGemini(&vw, r)
+
+			if !vw.checked() {
+				return
+			}
+
+			problems, err := gemtext.Validate(bytes.NewReader(vw.body.Bytes()), opts.MaxLineLength)
+			if err != nil {
+				problems = append(problems, gemtext.Problem{Message: err.Error()})
+			}
+
+			if logger := contextLogger(r.Context(), opts.Logger); logger != nil {
+				for _, p := range problems {
+					logger.Printf("gemproto: invalid gemtext: %s: %s", r.URL, p)
+				}
+			}
+
+			if !opts.Strict {
+				return
+			} else if len(problems) != 0 {
+				w.WriteHeader(StatusCGIError, "Invalid gemtext")
+				return
+			}
+
+			_, _ = w.Write(vw.body.Bytes())
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package gemproto_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestValidateGemtext(t *testing.T) {
+	t.Parallel()
+
+	h := gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+		switch r.URL.Path {
+		case "/bad":
+			_, _ = io.WriteString(w, "# Title\n```\nunterminated\n")
+		case "/plain":
+			w.WriteHeader(gemproto.StatusOK, "text/plain")
+			_, _ = io.WriteString(w, "```\n")
+		default:
+			_, _ = io.WriteString(w, "# Title\n")
+		}
+	})
+
+	serve := func(strict bool, path string) (*gemtest.ResponseRecorder, []string) {
+		var logger mockLogger
+		mw := gemproto.ValidateGemtext(gemproto.ValidateOptions{
+			Logger: &logger,
+			Strict: strict,
+		})
+		w := gemtest.NewRecorder()
+		mw(h).ServeGemini(w, gemtest.NewRequest("/"+path))
+		return w, logger.Logs
+	}
+
+	w, logs := serve(false, "bad")
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "# Title\n```\nunterminated\n", w.Body.String())
+	require.Equal(t, []string{
+		"gemproto: invalid gemtext: gemini:///bad: line 2: unterminated preformatted block",
+	}, logs)
+
+	w, logs = serve(true, "bad")
+	require.Equal(t, gemproto.StatusCGIError, w.Code)
+	require.Equal(t, "", w.Body.String())
+	require.Equal(t, 1, len(logs))
+
+	w, logs = serve(true, "good")
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, "# Title\n", w.Body.String())
+	require.Equal(t, 0, len(logs))
+
+	w, logs = serve(true, "plain")
+	require.Equal(t, "```\n", w.Body.String())
+	require.Equal(t, 0, len(logs))
+}
@@ -0,0 +1,77 @@
+package gemproto
+
+import (
+	"io"
+	"runtime/debug"
+	"sync"
+)
+
+const modulePath = "github.com/askeladdk/gemproto"
+
+// Version is the version of gemproto. It can be set at link time:
+//
+//	go build -ldflags "-X github.com/askeladdk/gemproto.Version=v1.2.3"
+//
+// If it is empty, the version is read from the build information
+// embedded in the binary, or "devel" if that is not available.
+var Version string
+
+var (
+	buildVersionOnce sync.Once
+	buildVersion     string
+)
+
+func version() string {
+	if Version != "" {
+		return Version
+	}
+
+	buildVersionOnce.Do(func() {
+		buildVersion = "devel"
+
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+
+		mod := &info.Main
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				mod = dep
+				break
+			}
+		}
+
+		if mod.Path == modulePath && mod.Version != "" && mod.Version != "(devel)" {
+			buildVersion = mod.Version
+		}
+	})
+
+	return buildVersion
+}
+
+// Software identifies gemproto in the form name/version,
+// such as in the footers of generated pages,
+// so that statistics crawlers can identify the server software.
+func Software() string {
+	return "gemproto/" + version()
+}
+
+// SoftwareHandler returns a handler that responds with the software
+// identifier as plain text. It is intended to be mounted at a
+// well-known path:
+//
+//	mux.Handle("/.well-known/software", gemproto.SoftwareHandler())
+func SoftwareHandler() Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(StatusOK, "text/plain;charset=utf-8")
+		_, _ = io.WriteString(w, Software()+"\n")
+	})
+}
+
+// WriteSoftwareFooter writes a gemtext footer line
+// that credits the software identifier to w.
+func WriteSoftwareFooter(w io.Writer) error {
+	_, err := io.WriteString(w, "\nServed by "+Software()+"\n")
+	return err
+}
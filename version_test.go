@@ -0,0 +1,26 @@
+package gemproto_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestSoftware(t *testing.T) {
+	t.Parallel()
+
+	software := gemproto.Software()
+	require.True(t, strings.HasPrefix(software, "gemproto/") && len(software) > len("gemproto/"), software)
+
+	w := gemtest.NewRecorder()
+	gemproto.SoftwareHandler().ServeGemini(w, gemtest.NewRequest("/.well-known/software"))
+	require.Equal(t, gemproto.StatusOK, w.Code)
+	require.Equal(t, gemproto.Software()+"\n", w.Body.String())
+
+	var sb strings.Builder
+	require.NoError(t, gemproto.WriteSoftwareFooter(&sb))
+	require.Equal(t, "\nServed by "+gemproto.Software()+"\n", sb.String())
+}
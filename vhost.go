@@ -0,0 +1,125 @@
+package gemproto
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+)
+
+// HostRouter serves several capsules on the same address
+// by binding hostnames to a handler and a certificate.
+// It selects the certificate during the TLS handshake using SNI
+// and dispatches requests to the handler of the requested host.
+//
+// Requests are refused with 53 PROXY REQUEST REFUSED if the hostname
+// sent with SNI and the host in the URL disagree, or if the host is unknown.
+// Hostnames may be wildcards of the form *.example.com,
+// which match exactly one label, like CertificateStore.
+//
+//	router := &gemproto.HostRouter{}
+//	router.Handle("example.com", exampleMux, exampleCert)
+//	router.Handle("example.org", orgMux, orgCert)
+//	srv := gemproto.Server{
+//	  Handler:   router,
+//	  TLSConfig: router.TLSConfig(),
+//	}
+//
+// The zero HostRouter is empty and ready to use.
+// HostRouter is safe to use concurrently and hosts
+// may be added and removed while the server is running.
+type HostRouter struct {
+	certs    CertificateStore
+	handlers map[string]Handler
+	mu       sync.RWMutex
+}
+
+// Handle binds the hostname to the handler and certificate.
+// Existing bindings for the same hostname are replaced.
+func (hr *HostRouter) Handle(hostname string, h Handler, cert tls.Certificate) error {
+	if h == nil {
+		return ErrNilHandler
+	}
+
+	if err := hr.certs.Add(cert, hostname); err != nil {
+		return err
+	}
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	if hr.handlers == nil {
+		hr.handlers = make(map[string]Handler)
+	}
+
+	hr.handlers[strings.ToLower(hostname)] = h
+	return nil
+}
+
+// Remove unbinds the hostnames.
+func (hr *HostRouter) Remove(hostnames ...string) {
+	hr.certs.Remove(hostnames...)
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	for _, hostname := range hostnames {
+		delete(hr.handlers, strings.ToLower(hostname))
+	}
+}
+
+// Handler returns the handler bound to the hostname.
+func (hr *HostRouter) Handler(hostname string) (Handler, bool) {
+	name := strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	if h, ok := hr.handlers[name]; ok && name != "" {
+		return h, true
+	}
+
+	if _, rest, ok := strings.Cut(name, "."); ok {
+		if h, ok := hr.handlers["*."+rest]; ok {
+			return h, true
+		}
+	}
+
+	return nil, false
+}
+
+// GetCertificate implements the tls.Config.GetCertificate callback.
+func (hr *HostRouter) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return hr.certs.GetCertificate(hello)
+}
+
+// TLSConfig returns a TLS configuration that selects
+// the certificate of the requested host.
+func (hr *HostRouter) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		ClientAuth:     tls.RequestClientCert,
+		GetCertificate: hr.GetCertificate,
+	}
+}
+
+// ServeGemini implements Handler.
+func (hr *HostRouter) ServeGemini(w ResponseWriter, r *Request) {
+	host := r.URL.Hostname()
+
+	if sni := strings.TrimSuffix(r.Host, "."); sni != "" {
+		if host == "" {
+			host = sni
+		} else if !strings.EqualFold(sni, strings.TrimSuffix(host, ".")) {
+			w.WriteHeader(StatusProxyRequestRefused, "Host does not match SNI")
+			return
+		}
+	}
+
+	h, ok := hr.Handler(host)
+	if !ok {
+		w.WriteHeader(StatusProxyRequestRefused, "Proxy Request Refused")
+		return
+	}
+
+	h.ServeGemini(w, r)
+}
@@ -0,0 +1,81 @@
+package gemproto_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/askeladdk/gemproto"
+	"github.com/askeladdk/gemproto/gemcert"
+	"github.com/askeladdk/gemproto/gemtest"
+	"github.com/askeladdk/gemproto/internal/require"
+)
+
+func TestHostRouter(t *testing.T) {
+	t.Parallel()
+
+	var router gemproto.HostRouter
+
+	for _, name := range []string{"a.test", "*.b.test"} {
+		name := name
+		cert, err := gemcert.CreateX509KeyPair(gemcert.CreateOptions{
+			Duration: time.Hour,
+			Subject:  pkix.Name{CommonName: name},
+			DNSNames: []string{name},
+		})
+		require.NoError(t, err)
+		require.NoError(t, router.Handle(name, gemproto.HandlerFunc(func(w gemproto.ResponseWriter, r *gemproto.Request) {
+			fmt.Fprint(w, name)
+		}), cert))
+	}
+
+	serve := func(sni, rawURL string) *gemtest.ResponseRecorder {
+		w := gemtest.NewRecorder()
+		r := gemtest.NewRequest(rawURL)
+		r.Host = sni
+		router.ServeGemini(w, r)
+		return w
+	}
+
+	require.Equal(t, "a.test", serve("a.test", "gemini://a.test/").Body.String())
+	require.Equal(t, "*.b.test", serve("www.b.test", "gemini://WWW.b.test/").Body.String())
+	require.Equal(t, "a.test", serve("", "gemini://a.test/").Body.String())
+	require.Equal(t, gemproto.StatusProxyRequestRefused, serve("a.test", "gemini://www.b.test/").Code)
+	require.Equal(t, gemproto.StatusProxyRequestRefused, serve("c.test", "gemini://c.test/").Code)
+
+	router.Remove("a.test")
+	require.Equal(t, gemproto.StatusProxyRequestRefused, serve("a.test", "gemini://a.test/").Code)
+
+	// the certificate is selected by SNI
+	s := gemproto.Server{
+		Handler:   &router,
+		TLSConfig: router.TLSConfig(),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{
+		ServerName:         "www.b.test",
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, "*.b.test", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	_, err = conn.Write([]byte("gemini://www.b.test/\r\n"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "20 text/gemini;charset=utf-8\r\n*.b.test", string(body))
+}